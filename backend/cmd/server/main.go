@@ -1,16 +1,33 @@
 package main
 
 import (
+	"context"
+	"expvar"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	_ "modernc.org/sqlite"
+	"ecommerce-app/internal/carts"
+	"ecommerce-app/internal/catalog"
 	"ecommerce-app/internal/config"
+	"ecommerce-app/internal/alerts"
 	"ecommerce-app/internal/handlers"
+	"ecommerce-app/internal/i18n"
+	"ecommerce-app/internal/jobs"
 	"ecommerce-app/internal/middleware"
 	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+	"ecommerce-app/internal/notify"
+	"ecommerce-app/internal/pricing"
+	"ecommerce-app/internal/recommend"
+	"ecommerce-app/internal/signing"
+	"ecommerce-app/internal/storage"
 )
 
 func main() {
@@ -24,11 +41,79 @@ func main() {
 	// Auto-migrate the schema
 	migrateDB(db)
 
+	// Background jobs stop when the server exits
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Rendered catalog responses, dropped whenever items change
+	catalogCache := catalog.NewCache(db, jobs.DurationFromEnv("CATALOG_CACHE_TTL", 30*time.Second))
+
+	priceScheduler := catalog.NewPriceScheduler(db)
+	priceScheduler.OnChange = catalogCache.Invalidate
+	go jobs.Every(ctx, "price-scheduler", jobs.DurationFromEnv("PRICE_SCHEDULER_INTERVAL", time.Minute), priceScheduler.Run)
+
+	// More strategies, such as "customers also viewed", are added here
+	recommender := recommend.NewRefresher(db, recommend.NewFrequentlyBoughtTogether())
+	go jobs.Every(ctx, "recommendations", jobs.DurationFromEnv("RECOMMENDATIONS_INTERVAL", time.Hour), recommender.Run)
+
+	// Notifications are only logged until an email or push notifier is
+	// configured
+	notifier := notify.LogNotifier{}
+	alertDispatcher := alerts.NewDispatcher(db, notifier)
+	go jobs.Every(ctx, "alerts", jobs.DurationFromEnv("ALERTS_INTERVAL", time.Minute), alertDispatcher.Run)
+
+	// Guest carts are forgotten sooner than the carts of signed-in users
+	cartExpirer := carts.NewExpirer(db,
+		jobs.DurationFromEnv("GUEST_CART_TTL", 7*24*time.Hour),
+		jobs.DurationFromEnv("USER_CART_TTL", 30*24*time.Hour))
+	go jobs.Every(ctx, "cart-expiry", jobs.DurationFromEnv("CART_EXPIRY_INTERVAL", time.Hour), cartExpirer.Run)
+
+	// Signs links that work without a login: downloads, cart restores and
+	// reminder opt-outs
+	signer := signing.NewSignerFromEnv("SIGNING_SECRET")
+
+	cartReminder := carts.NewReminder(db, notifier, signer)
+	cartReminder.IdleAfter = jobs.DurationFromEnv("CART_REMINDER_IDLE", 24*time.Hour)
+	cartReminder.MaxReminders = jobs.IntFromEnv("CART_REMINDER_MAX", 2)
+	cartReminder.LinkTTL = jobs.DurationFromEnv("CART_REMINDER_LINK_TTL", 7*24*time.Hour)
+	cartReminder.BaseURL = strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	go jobs.Every(ctx, "cart-reminders", jobs.DurationFromEnv("CART_REMINDER_INTERVAL", 15*time.Minute), cartReminder.Run)
+
 	// Initialize handlers
 	userHandler := handlers.NewUserHandler(db)
-	itemHandler := handlers.NewItemHandler(db)
+	userHandler.Signer = signer
+	blobs, err := storage.NewLocalBlobStore(uploadDir(), "/uploads")
+	if err != nil {
+		log.Fatalf("Failed to initialize blob store: %v", err)
+	}
+	// The first of LOCALES is the default, in which items are created
+	locales := i18n.ParseLocales(os.Getenv("LOCALES"))
+	itemHandler := handlers.NewItemHandler(db, blobs, catalogCache)
+	itemHandler.Locales = locales
+	// Carts and orders share one pricing engine, so that customers are
+	// charged what their cart showed
+	pricingEngine := newPricingEngine()
 	cartHandler := handlers.NewCartHandler(db)
-	orderHandler := handlers.NewOrderHandler(db)
+	cartHandler.Signer = signer
+	cartHandler.Pricing = pricingEngine
+	orderHandler := handlers.NewOrderHandler(db, catalogCache)
+	orderHandler.Pricing = pricingEngine
+	reviewHandler := handlers.NewReviewHandler(db, catalogCache)
+	attributeHandler := handlers.NewAttributeHandler(db, catalogCache)
+	recommendationHandler := handlers.NewRecommendationHandler(db)
+	wishlistHandler := handlers.NewWishlistHandler(db)
+	alertHandler := handlers.NewAlertHandler(db)
+	bundleHandler := handlers.NewBundleHandler(db)
+	couponHandler := handlers.NewCouponHandler(db)
+
+	// Files of digital items are kept apart from the public uploads and
+	// only served through signed links
+	downloads, err := storage.NewLocalBlobStore(downloadDir(), "")
+	if err != nil {
+		log.Fatalf("Failed to initialize download store: %v", err)
+	}
+	digitalHandler := handlers.NewDigitalHandler(db, downloads, signer)
+	digitalHandler.LinkTTL = jobs.DurationFromEnv("DOWNLOAD_LINK_TTL", time.Hour)
 
 	// Create Gin router
 	r := gin.Default()
@@ -48,6 +133,9 @@ func main() {
 		c.Next()
 	})
 
+	// Uploaded files
+	r.Static("/uploads", blobs.Root)
+
 	// API routes
 	api := r.Group("/api")
 	{
@@ -55,6 +143,10 @@ func main() {
 		api.POST("/users", userHandler.Signup)
 		api.POST("/users/login", userHandler.Login)
 		api.GET("/users", userHandler.ListUsers)
+		api.GET("/shared/wishlists/:token", wishlistHandler.GetSharedWishlist)
+		api.GET("/downloads/:id", digitalHandler.Download)
+		api.GET("/carts/:id/restore", cartHandler.RestoreCart)
+		api.GET("/users/:id/cart-reminders/opt-out", userHandler.OptOutCartReminders)
 
 		// Protected routes
 		auth := api.Group("/")
@@ -62,18 +154,111 @@ func main() {
 		{
 			// User routes
 			auth.GET("/users/me", userHandler.GetCurrentUser)
+			auth.PUT("/users/me/preferences", userHandler.UpdatePreferences)
 
-			// Items
-			auth.POST("/items", itemHandler.CreateItem)
-			auth.GET("/items", itemHandler.ListItems)
+			// Items. Catalog reads are served conditionally and from cache,
+			// per locale for localized content
+			cached := middleware.CatalogCache(catalogCache)
+			localized := middleware.Locale(locales)
+			auth.GET("/items", localized, cached, itemHandler.ListItems)
+			auth.GET("/items/facets", cached, attributeHandler.Facets)
+			auth.GET("/items/:id", localized, cached, itemHandler.GetItem)
+			auth.GET("/items/:id/recommendations", recommendationHandler.GetRecommendations)
+			auth.POST("/items/:id/alerts", alertHandler.CreateAlert)
+
+			// Bundles
+			auth.GET("/bundles", bundleHandler.ListBundles)
+			auth.GET("/bundles/:id", bundleHandler.GetBundle)
+
+			// Attributes
+			auth.GET("/attributes", attributeHandler.ListAttributes)
+
+			// Reviews
+			auth.GET("/items/:id/reviews", reviewHandler.ListReviews)
+			auth.POST("/items/:id/reviews", reviewHandler.CreateReview)
+			auth.GET("/items/:id/rating", reviewHandler.GetRating)
 
 			// Carts
 			auth.POST("/carts", cartHandler.AddToCart)
 			auth.GET("/carts", cartHandler.GetCart)
+			auth.DELETE("/carts", cartHandler.ClearCart)
+			auth.PUT("/carts/items/:itemID", cartHandler.UpdateCartItem)
+			auth.DELETE("/carts/items/:itemID", cartHandler.RemoveCartItem)
+			auth.PUT("/carts/bundles/:bundleID", cartHandler.UpdateCartBundle)
+			auth.DELETE("/carts/bundles/:bundleID", cartHandler.RemoveCartBundle)
+			auth.POST("/carts/coupon", cartHandler.ApplyCoupon)
+			auth.DELETE("/carts/coupon", cartHandler.RemoveCoupon)
+			auth.GET("/users/me/carts", cartHandler.ListCarts)
+			auth.POST("/users/me/carts", cartHandler.CreateCart)
+			auth.PUT("/users/me/carts/:id", cartHandler.RenameCart)
+			auth.POST("/users/me/carts/:id/switch", cartHandler.SwitchCart)
+			auth.DELETE("/users/me/carts/:id", cartHandler.DeleteCart)
+
+			// Wishlists
+			auth.GET("/wishlists", wishlistHandler.ListWishlists)
+			auth.POST("/wishlists", wishlistHandler.CreateWishlist)
+			auth.GET("/wishlists/:id", wishlistHandler.GetWishlist)
+			auth.DELETE("/wishlists/:id", wishlistHandler.DeleteWishlist)
+			auth.POST("/wishlists/:id/items", wishlistHandler.AddWishlistItem)
+			auth.DELETE("/wishlists/:id/items/:itemID", wishlistHandler.RemoveWishlistItem)
+			auth.POST("/wishlists/:id/items/:itemID/move-to-cart", wishlistHandler.MoveToCart)
+			auth.POST("/wishlists/:id/share-token", wishlistHandler.RotateShareToken)
+
+			// Alerts
+			auth.GET("/alerts", alertHandler.ListAlerts)
+			auth.DELETE("/alerts/:id", alertHandler.CancelAlert)
 
 			// Orders
 			auth.POST("/orders", orderHandler.CreateOrder)
 			auth.GET("/orders", orderHandler.ListOrders)
+			auth.GET("/orders/:id/downloads", digitalHandler.OrderDownloads)
+		}
+
+		// Admin routes
+		admin := api.Group("/admin")
+		admin.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware(db))
+		{
+			// Catalog
+			admin.POST("/items", itemHandler.CreateItem)
+			admin.POST("/items/import", itemHandler.ImportItems)
+			admin.GET("/items/export", itemHandler.ExportItems)
+			admin.GET("/items", middleware.Locale(locales), itemHandler.AdminListItems)
+			admin.PUT("/items/:id/visibility", itemHandler.SetVisibility)
+			admin.PUT("/items/:id/stock", itemHandler.UpdateStock)
+			admin.POST("/items/:id/images", itemHandler.UploadImages)
+			admin.DELETE("/items/:id/images/:imageID", itemHandler.DeleteImage)
+			admin.PUT("/items/:id/limits", itemHandler.UpdateLimits)
+			admin.PUT("/items/:id/price", itemHandler.UpdatePrice)
+			admin.PUT("/items/:id/attributes", attributeHandler.SetItemAttributes)
+			admin.GET("/items/:id/translations", itemHandler.ListTranslations)
+			admin.PUT("/items/:id/translations/:locale", itemHandler.SetTranslation)
+			admin.DELETE("/items/:id/translations/:locale", itemHandler.DeleteTranslation)
+			admin.POST("/attributes", attributeHandler.CreateAttribute)
+			admin.DELETE("/attributes/:id", attributeHandler.DeleteAttribute)
+			admin.POST("/items/:id/files", digitalHandler.UploadFiles)
+			admin.GET("/items/:id/files", digitalHandler.ListFiles)
+			admin.DELETE("/items/:id/files/:fileID", digitalHandler.DeleteFile)
+			admin.POST("/items/:id/license-keys", digitalHandler.AddLicenseKeys)
+			admin.GET("/items/:id/license-keys", digitalHandler.GetLicenseKeyPool)
+			admin.POST("/bundles", bundleHandler.CreateBundle)
+			admin.PUT("/bundles/:id", bundleHandler.UpdateBundle)
+			admin.DELETE("/bundles/:id", bundleHandler.DeleteBundle)
+			admin.GET("/items/:id/prices", itemHandler.ListPrices)
+			admin.POST("/items/:id/prices/schedule", itemHandler.SchedulePrice)
+			admin.DELETE("/items/:id/prices/schedule/:scheduleID", itemHandler.CancelScheduledPrice)
+
+			// Coupons
+			admin.GET("/coupons", couponHandler.ListCoupons)
+			admin.POST("/coupons", couponHandler.CreateCoupon)
+			admin.PUT("/coupons/:id", couponHandler.UpdateCoupon)
+			admin.DELETE("/coupons/:id", couponHandler.DeleteCoupon)
+
+			// Review moderation
+			admin.GET("/reviews", reviewHandler.ListModerationQueue)
+			admin.PUT("/reviews/:id", reviewHandler.ModerateReview)
+
+			// Metrics of the background jobs, in expvar's JSON format
+			admin.GET("/metrics", gin.WrapH(expvar.Handler()))
 		}
 	}
 
@@ -89,10 +274,90 @@ func main() {
 	}
 }
 
+// uploadDir returns where uploaded files are stored, defaulting to ./uploads.
+func uploadDir() string {
+	if dir := os.Getenv("UPLOAD_DIR"); dir != "" {
+		return dir
+	}
+	return "uploads"
+}
+
+// downloadDir returns where files of digital items are stored, defaulting to
+// ./downloads. It must not be served publicly.
+func downloadDir() string {
+	if dir := os.Getenv("DOWNLOAD_DIR"); dir != "" {
+		return dir
+	}
+	return "downloads"
+}
+
+// newPricingEngine builds the cart pricing pipeline from the environment.
+// Coupons always apply; shipping and tax are only charged when
+// configured.
+func newPricingEngine() *pricing.Engine {
+	calculators := []pricing.Calculator{pricing.CouponDiscount{}}
+
+	if v := os.Getenv("QUANTITY_DISCOUNT_RATE"); v != "" {
+		bp, err := pricing.ParsePercent(v)
+		if err != nil {
+			log.Fatalf("Invalid QUANTITY_DISCOUNT_RATE: %v", err)
+		}
+		minQuantity, err := strconv.Atoi(os.Getenv("QUANTITY_DISCOUNT_FROM"))
+		if err != nil || minQuantity <= 0 {
+			log.Fatalf("QUANTITY_DISCOUNT_FROM must be a positive number of units")
+		}
+		calculators = append(calculators, pricing.QuantityDiscount{MinQuantity: minQuantity, BasisPoints: bp})
+	}
+
+	// Runs after coupons, whose minimum spend is checked without it
+	if v := os.Getenv("SPEND_DISCOUNT_RATE"); v != "" {
+		bp, err := pricing.ParsePercent(v)
+		if err != nil {
+			log.Fatalf("Invalid SPEND_DISCOUNT_RATE: %v", err)
+		}
+		discount := pricing.SpendDiscount{BasisPoints: bp}
+		if v := os.Getenv("SPEND_DISCOUNT_FROM"); v != "" {
+			if discount.From, err = money.Parse(v, money.DefaultCurrency); err != nil {
+				log.Fatalf("Invalid SPEND_DISCOUNT_FROM: %v", err)
+			}
+		}
+		calculators = append(calculators, discount)
+	}
+
+	if v := os.Getenv("SHIPPING_RATE"); v != "" {
+		rate, err := money.Parse(v, money.DefaultCurrency)
+		if err != nil {
+			log.Fatalf("Invalid SHIPPING_RATE: %v", err)
+		}
+		shipping := pricing.FlatRateShipping{Rate: rate}
+		if v := os.Getenv("FREE_SHIPPING_FROM"); v != "" {
+			if shipping.FreeFrom, err = money.Parse(v, money.DefaultCurrency); err != nil {
+				log.Fatalf("Invalid FREE_SHIPPING_FROM: %v", err)
+			}
+		}
+		calculators = append(calculators, shipping)
+	}
+
+	if v := os.Getenv("TAX_RATE"); v != "" {
+		bp, err := pricing.ParsePercent(v)
+		if err != nil {
+			log.Fatalf("Invalid TAX_RATE: %v", err)
+		}
+		calculators = append(calculators, pricing.PercentTax{BasisPoints: bp})
+	}
+
+	return pricing.NewEngine(calculators...)
+}
+
 func migrateDB(db *gorm.DB) {
 	// Enable foreign key constraints for SQLite
 	db.Exec("PRAGMA foreign_keys = ON")
 
+	// Before AutoMigrate can add price_amount with its zero default
+	if err := dropLegacyPrice(db); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+
 	// Auto-migrate the models
 	db.AutoMigrate(
 		&models.User{},
@@ -100,42 +365,128 @@ func migrateDB(db *gorm.DB) {
 		&models.Cart{},
 		&models.CartItem{},
 		&models.Order{},
+		&models.OrderItem{},
+		&models.OrderAdjustment{},
+		&models.ItemImage{},
+		&models.PriceChange{},
+		&models.ScheduledPrice{},
+		&models.Review{},
+		&models.AttributeDefinition{},
+		&models.AttributeOption{},
+		&models.ItemAttributeValue{},
+		&models.ItemRecommendation{},
+		&models.Wishlist{},
+		&models.WishlistItem{},
+		&models.ItemAlert{},
+		&models.ItemEvent{},
+		&models.Bundle{},
+		&models.BundleComponent{},
+		&models.CartBundle{},
+		&models.DigitalAsset{},
+		&models.LicenseKey{},
+		&models.DownloadGrant{},
+		&models.ItemTranslation{},
+		&models.Coupon{},
+		&models.CouponItem{},
+		&models.CouponCategory{},
+		&models.CouponRedemption{},
 	)
 
+	// Cart upserts rely on these unique indexes
+	if err := handlers.EnsureCartIndexes(db); err != nil {
+		log.Printf("Failed to create cart indexes: %v", err)
+	}
+
 	// Add any initial data if needed
 	seedInitialData(db)
 }
 
+// dropLegacyPrice drops the REAL items.price column that migration 000008
+// converts to price_amount, but only once every price converted exactly.
+// Until then it fails, as items would be sold at the wrong price.
+func dropLegacyPrice(db *gorm.DB) error {
+	var legacy int
+	if err := db.Raw("SELECT COUNT(*) FROM pragma_table_info('items') WHERE name = 'price'").Row().Scan(&legacy); err != nil {
+		return err
+	}
+	if legacy == 0 {
+		return nil
+	}
+
+	var unconverted int
+	if err := db.Raw("SELECT COUNT(*) FROM items WHERE ABS(price * 100 - price_amount) > 0.000001").Row().Scan(&unconverted); err != nil {
+		return fmt.Errorf("items.price has not been converted, apply migration 000008: %v", err)
+	}
+	if unconverted > 0 {
+		return fmt.Errorf("%d items have a price that did not convert exactly to price_amount, see migration 000008", unconverted)
+	}
+	if err := db.Exec("ALTER TABLE items DROP COLUMN price").Error; err != nil {
+		return err
+	}
+	log.Printf("Dropped items.price, every price converted to price_amount")
+	return nil
+}
+
 func seedInitialData(db *gorm.DB) {
-	// Define our items with proper IDs
 	items := []models.Item{
-		{ID: 1, Name: "Laptop", Price: 999.99, Status: "available"},
-		{ID: 2, Name: "Smartphone", Price: 699.99, Status: "available"},
-		{ID: 3, Name: "Headphones", Price: 199.99, Status: "available"},
-		{ID: 4, Name: "Keyboard", Price: 99.99, Status: "available"},
-		{ID: 5, Name: "Mouse", Price: 49.99, Status: "available"},
+		{SKU: "LAPTOP-001", Name: "Laptop", Price: money.New(99999, "USD"), Status: "available", Stock: 25},
+		{SKU: "PHONE-001", Name: "Smartphone", Price: money.New(69999, "USD"), Status: "available", Stock: 50},
+		{SKU: "AUDIO-001", Name: "Headphones", Price: money.New(19999, "USD"), Status: "available", Stock: 100},
+		{SKU: "KEYB-001", Name: "Keyboard", Price: money.New(9999, "USD"), Status: "available", Stock: 100},
+		{SKU: "MOUSE-001", Name: "Mouse", Price: money.New(4999, "USD"), Status: "available", Stock: 100},
 	}
 
-	// Update or create each item
 	for _, item := range items {
-		// Try to find existing item by name
+		// Names and SKUs are unique, so an item seeded before is found by either
 		var existingItem models.Item
-		if err := db.Where("name = ?", item.Name).First(&existingItem).Error; err == nil {
-			// Item exists, update it with correct ID and status
-			existingItem.ID = item.ID
-			existingItem.Price = item.Price
-			existingItem.Status = item.Status
-			if err := db.Save(&existingItem).Error; err != nil {
-				log.Printf("Failed to update item %s: %v", item.Name, err)
+		err := db.Where("normalized_name = ? OR sku = ?", models.NormalizeItemName(item.Name), item.SKU).
+			First(&existingItem).Error
+		if err == nil {
+			// Prices are managed through the price history and status follows
+			// stock, so only replace a missing or migration-generated SKU,
+			// stock items from before stock was tracked and reset the status
+			// while in stock.
+			updates := map[string]interface{}{}
+			if existingItem.SKU == "" || strings.HasPrefix(existingItem.SKU, "ITEM-") {
+				updates["sku"] = item.SKU
+			}
+			stock := existingItem.Stock
+			if stock == 0 && !existingItem.Digital && !stockTracked(db, existingItem.ID) {
+				updates["stock"] = item.Stock
+				stock = item.Stock
+			}
+			if stock > 0 && existingItem.Status != item.Status {
+				updates["status"] = item.Status
 			}
-		} else {
-			// Item doesn't exist, create it
-			if err := db.Create(&item).Error; err != nil {
-				log.Printf("Failed to create item %s: %v", item.Name, err)
+			if len(updates) > 0 {
+				oldStatus := existingItem.Status
+				if err := db.Model(&existingItem).Updates(updates).Error; err != nil {
+					log.Printf("Failed to update item %s: %v", item.Name, err)
+				} else if err := catalog.RecordStatusChange(db, existingItem.ID, oldStatus, existingItem.Status); err != nil {
+					log.Printf("Failed to record status of item %s: %v", item.Name, err)
+				}
 			}
+			continue
+		}
+		if err != gorm.ErrRecordNotFound {
+			log.Printf("Failed to look up item %s: %v", item.Name, err)
+			continue
+		}
+
+		if err := db.Create(&item).Error; err != nil {
+			log.Printf("Failed to create item %s: %v", item.Name, err)
+		} else if err := catalog.RecordInitialPrice(db, &item, models.PriceSourceManual, nil); err != nil {
+			log.Printf("Failed to record price of item %s: %v", item.Name, err)
 		}
 	}
+}
 
-	// Ensure the sequence is set correctly
-	db.Exec("UPDATE sqlite_sequence SET seq = (SELECT MAX(id) FROM items) WHERE name='items';")
+// stockTracked reports whether the stock of an item has ever moved: it was
+// ordered, or sold out or restocked. Items without stock that never moved
+// predate stock tracking.
+func stockTracked(db *gorm.DB, itemID uint) bool {
+	var ordered, statusChanges int
+	db.Model(&models.OrderItem{}).Where("item_id = ?", itemID).Count(&ordered)
+	db.Model(&models.ItemEvent{}).Where("item_id = ? AND type = ?", itemID, models.ItemEventStatusChanged).Count(&statusChanges)
+	return ordered > 0 || statusChanges > 0
 }
@@ -0,0 +1,153 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/notify"
+)
+
+// maxAttempts is how often an event is retried before it is given up on.
+const maxAttempts = 5
+
+// batchSize is the number of events handled per run.
+const batchSize = 100
+
+// Dispatcher turns item events into notifications for the users with a
+// matching alert. Run is meant to be called periodically.
+type Dispatcher struct {
+	DB       *gorm.DB
+	Notifier notify.Notifier
+	Now      func() time.Time
+}
+
+func NewDispatcher(db *gorm.DB, notifier notify.Notifier) *Dispatcher {
+	return &Dispatcher{DB: db, Notifier: notifier, Now: time.Now}
+}
+
+// Run handles pending events in the order they were recorded. An alert is
+// marked triggered as soon as its notification is sent, so a retried event
+// never notifies the same user twice. An event whose notifications keep
+// failing is dropped after maxAttempts runs.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	var events []models.ItemEvent
+	if err := d.DB.Where("processed_at IS NULL").Order("id").Limit(batchSize).Find(&events).Error; err != nil {
+		return err
+	}
+
+	sent := 0
+	for _, event := range events {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		n, err := d.dispatch(ctx, event)
+		sent += n
+		if err != nil {
+			log.Printf("Failed to dispatch item event %d: %v", event.ID, err)
+			if err := d.fail(event, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.DB.Model(&event).UpdateColumn("processed_at", d.Now()).Error; err != nil {
+			return err
+		}
+	}
+	if sent > 0 {
+		log.Printf("Alerts: sent %d notifications", sent)
+	}
+	return nil
+}
+
+// dispatch notifies every active alert the event fires and returns how many
+// notifications were sent.
+func (d *Dispatcher) dispatch(ctx context.Context, event models.ItemEvent) (int, error) {
+	var item models.Item
+	if err := d.DB.First(&item, event.ItemID).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var kind string
+	switch event.Type {
+	case models.ItemEventStatusChanged:
+		// The item may have sold out again since the event was recorded
+		if event.NewStatus != models.ItemStatusAvailable || !item.Purchasable(1) {
+			return 0, nil
+		}
+		kind = models.AlertKindBackInStock
+	case models.ItemEventPriceChanged:
+		if event.NewPrice.Amount >= event.OldPrice.Amount {
+			return 0, nil
+		}
+		kind = models.AlertKindPriceDrop
+	default:
+		return 0, nil
+	}
+
+	var alerts []models.ItemAlert
+	if err := d.DB.Where("item_id = ? AND kind = ? AND status = ?", item.ID, kind, models.AlertStatusActive).
+		Order("id").
+		Find(&alerts).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, alert := range alerts {
+		if kind == models.AlertKindPriceDrop && !priceDropFires(alert, event.NewPrice.Amount, event.NewPrice.Currency) {
+			continue
+		}
+		if err := d.Notifier.Notify(ctx, message(alert, item, event)); err != nil {
+			return sent, err
+		}
+		now := d.Now()
+		if err := d.DB.Model(&alert).Updates(map[string]interface{}{
+			"status":       models.AlertStatusTriggered,
+			"triggered_at": now,
+		}).Error; err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// priceDropFires reports whether a new price satisfies a price drop alert.
+func priceDropFires(alert models.ItemAlert, amount int64, currency string) bool {
+	if currency != alert.BasePrice.Currency || amount >= alert.BasePrice.Amount {
+		return false
+	}
+	return alert.TargetPrice.IsZero() || amount <= alert.TargetPrice.Amount
+}
+
+func message(alert models.ItemAlert, item models.Item, event models.ItemEvent) notify.Message {
+	msg := notify.Message{UserID: alert.UserID, Kind: alert.Kind, ItemID: item.ID}
+	switch alert.Kind {
+	case models.AlertKindBackInStock:
+		msg.Subject = fmt.Sprintf("%s is back in stock", item.Name)
+		msg.Body = fmt.Sprintf("%s is available again for %s.", item.Name, item.Price)
+	case models.AlertKindPriceDrop:
+		msg.Subject = fmt.Sprintf("%s dropped to %s", item.Name, event.NewPrice)
+		msg.Body = fmt.Sprintf("The price of %s went down from %s to %s.", item.Name, alert.BasePrice, event.NewPrice)
+	}
+	return msg
+}
+
+// fail records a failed attempt at event and gives up on it once it has
+// failed maxAttempts times.
+func (d *Dispatcher) fail(event models.ItemEvent, cause error) error {
+	updates := map[string]interface{}{
+		"attempts":   event.Attempts + 1,
+		"last_error": cause.Error(),
+	}
+	if event.Attempts+1 >= maxAttempts {
+		updates["processed_at"] = d.Now()
+	}
+	return d.DB.Model(&event).Updates(updates).Error
+}
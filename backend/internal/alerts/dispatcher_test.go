@@ -0,0 +1,254 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "modernc.org/sqlite"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+	"ecommerce-app/internal/notify"
+)
+
+// flakyNotifier fails the calls listed in fail, counting from 1, and
+// delivers the others to its outbox.
+type flakyNotifier struct {
+	*notify.Outbox
+	calls int
+	fail  map[int]bool
+}
+
+func (n *flakyNotifier) Notify(ctx context.Context, msg notify.Message) error {
+	n.calls++
+	if n.fail[n.calls] {
+		return errors.New("mail server down")
+	}
+	return n.Outbox.Notify(ctx, msg)
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "alerts.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	db, err := gorm.Open("sqlite3", sqlDB)
+	if err != nil {
+		t.Fatalf("open gorm: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.AutoMigrate(&models.Item{}, &models.ItemAlert{}, &models.ItemEvent{}).Error; err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func usd(amount int64) money.Money { return money.New(amount, "USD") }
+
+// create inserts each of records or fails the test.
+func create(t *testing.T, db *gorm.DB, records ...interface{}) {
+	t.Helper()
+	for _, r := range records {
+		if err := db.Create(r).Error; err != nil {
+			t.Fatalf("create %T: %v", r, err)
+		}
+	}
+}
+
+func run(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	if err := d.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+// alertStatuses returns the status of each alert, in order.
+func alertStatuses(t *testing.T, db *gorm.DB, alerts ...*models.ItemAlert) []string {
+	t.Helper()
+	statuses := make([]string, len(alerts))
+	for i, alert := range alerts {
+		var got models.ItemAlert
+		if err := db.First(&got, alert.ID).Error; err != nil {
+			t.Fatalf("find alert: %v", err)
+		}
+		statuses[i] = got.Status
+	}
+	return statuses
+}
+
+func TestDispatchBackInStock(t *testing.T) {
+	db := newTestDB(t)
+	item := models.Item{SKU: "SKU-1", Name: "Widget", Price: usd(1000), Status: models.ItemStatusAvailable, Stock: 5}
+	create(t, db, &item)
+	waiting := models.ItemAlert{UserID: 1, ItemID: item.ID, Kind: models.AlertKindBackInStock}
+	told := models.ItemAlert{UserID: 2, ItemID: item.ID, Kind: models.AlertKindBackInStock, Status: models.AlertStatusTriggered}
+	priceDrop := models.ItemAlert{UserID: 3, ItemID: item.ID, Kind: models.AlertKindPriceDrop, BasePrice: usd(2000)}
+	event := models.ItemEvent{ItemID: item.ID, Type: models.ItemEventStatusChanged,
+		OldStatus: models.ItemStatusOutOfStock, NewStatus: models.ItemStatusAvailable}
+	create(t, db, &waiting, &told, &priceDrop, &event)
+
+	outbox := notify.NewOutbox()
+	d := NewDispatcher(db, outbox)
+	run(t, d)
+	run(t, d)
+
+	messages := outbox.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d notifications, want 1: %+v", len(messages), messages)
+	}
+	msg := messages[0]
+	if msg.UserID != 1 || msg.Kind != models.AlertKindBackInStock || msg.ItemID != item.ID || msg.Subject != "Widget is back in stock" {
+		t.Errorf("notification %+v, want user 1 told Widget is back in stock", msg)
+	}
+	want := []string{models.AlertStatusTriggered, models.AlertStatusTriggered, models.AlertStatusActive}
+	if got := alertStatuses(t, db, &waiting, &told, &priceDrop); !slices.Equal(got, want) {
+		t.Errorf("alert statuses %v, want %v", got, want)
+	}
+	if err := db.First(&event, event.ID).Error; err != nil {
+		t.Fatalf("find event: %v", err)
+	}
+	if event.ProcessedAt == nil {
+		t.Error("event not processed")
+	}
+}
+
+func TestDispatchSoldOutAgain(t *testing.T) {
+	db := newTestDB(t)
+	// Restocked and sold out again before the event was handled
+	item := models.Item{SKU: "SKU-1", Name: "Widget", Price: usd(1000), Status: models.ItemStatusOutOfStock}
+	create(t, db, &item)
+	alert := models.ItemAlert{UserID: 1, ItemID: item.ID, Kind: models.AlertKindBackInStock}
+	create(t, db, &alert, &models.ItemEvent{ItemID: item.ID, Type: models.ItemEventStatusChanged,
+		OldStatus: models.ItemStatusOutOfStock, NewStatus: models.ItemStatusAvailable})
+
+	outbox := notify.NewOutbox()
+	run(t, NewDispatcher(db, outbox))
+	if got := len(outbox.Messages()); got != 0 {
+		t.Errorf("got %d notifications, want none", got)
+	}
+	// The alert waits for the next restock
+	if got := alertStatuses(t, db, &alert); got[0] != models.AlertStatusActive {
+		t.Errorf("alert %s, want active", got[0])
+	}
+}
+
+func TestDispatchPriceDrop(t *testing.T) {
+	db := newTestDB(t)
+	item := models.Item{SKU: "SKU-1", Name: "Widget", Price: usd(900), Status: models.ItemStatusAvailable, Stock: 5}
+	create(t, db, &item)
+
+	tests := []struct {
+		name  string
+		alert models.ItemAlert
+		fires bool
+	}{
+		{"any drop", models.ItemAlert{BasePrice: usd(1000)}, true},
+		{"target reached", models.ItemAlert{BasePrice: usd(1000), TargetPrice: usd(900)}, true},
+		{"target not reached", models.ItemAlert{BasePrice: usd(1000), TargetPrice: usd(899)}, false},
+		{"subscribed at the new price", models.ItemAlert{BasePrice: usd(900)}, false},
+		{"other currency", models.ItemAlert{BasePrice: money.New(1000, "EUR")}, false},
+	}
+	for i := range tests {
+		alert := &tests[i].alert
+		alert.UserID, alert.ItemID, alert.Kind = uint(i+1), item.ID, models.AlertKindPriceDrop
+		create(t, db, alert)
+	}
+	// A rise notifies nobody; the drop after it is compared with what each
+	// user subscribed at
+	create(t, db,
+		&models.ItemEvent{ItemID: item.ID, Type: models.ItemEventPriceChanged, OldPrice: usd(1000), NewPrice: usd(1100)},
+		&models.ItemEvent{ItemID: item.ID, Type: models.ItemEventPriceChanged, OldPrice: usd(1100), NewPrice: usd(900)})
+
+	outbox := notify.NewOutbox()
+	run(t, NewDispatcher(db, outbox))
+
+	notified := make(map[uint]notify.Message)
+	for _, msg := range outbox.Messages() {
+		notified[msg.UserID] = msg
+	}
+	for i, tt := range tests {
+		msg, ok := notified[uint(i+1)]
+		if ok != tt.fires {
+			t.Errorf("%s: notified %v, want %v", tt.name, ok, tt.fires)
+			continue
+		}
+		if ok && msg.Subject != "Widget dropped to 9.00 USD" {
+			t.Errorf("%s: subject %q", tt.name, msg.Subject)
+		}
+	}
+	if len(outbox.Messages()) != len(notified) {
+		t.Errorf("got %d notifications for %d users", len(outbox.Messages()), len(notified))
+	}
+}
+
+func TestDispatchRetries(t *testing.T) {
+	db := newTestDB(t)
+	item := models.Item{SKU: "SKU-1", Name: "Widget", Price: usd(1000), Status: models.ItemStatusAvailable, Stock: 5}
+	create(t, db, &item)
+	first := models.ItemAlert{UserID: 1, ItemID: item.ID, Kind: models.AlertKindBackInStock}
+	second := models.ItemAlert{UserID: 2, ItemID: item.ID, Kind: models.AlertKindBackInStock}
+	event := models.ItemEvent{ItemID: item.ID, Type: models.ItemEventStatusChanged,
+		OldStatus: models.ItemStatusOutOfStock, NewStatus: models.ItemStatusAvailable}
+	create(t, db, &first, &second, &event)
+
+	// The second user's notification fails once; the retry does not notify
+	// the first user again
+	notifier := &flakyNotifier{Outbox: notify.NewOutbox(), fail: map[int]bool{2: true}}
+	d := NewDispatcher(db, notifier)
+	run(t, d)
+	if err := db.First(&event, event.ID).Error; err != nil {
+		t.Fatalf("find event: %v", err)
+	}
+	if event.ProcessedAt != nil || event.Attempts != 1 || event.LastError != "mail server down" {
+		t.Errorf("after a failure: processed %v, %d attempts, error %q", event.ProcessedAt, event.Attempts, event.LastError)
+	}
+	run(t, d)
+
+	var users []uint
+	for _, msg := range notifier.Messages() {
+		users = append(users, msg.UserID)
+	}
+	if !slices.Equal(users, []uint{1, 2}) {
+		t.Errorf("notified users %v, want [1 2]", users)
+	}
+	if err := db.First(&event, event.ID).Error; err != nil {
+		t.Fatalf("find event: %v", err)
+	}
+	if event.ProcessedAt == nil {
+		t.Error("event not processed after the retry")
+	}
+}
+
+func TestDispatchGivesUp(t *testing.T) {
+	db := newTestDB(t)
+	item := models.Item{SKU: "SKU-1", Name: "Widget", Price: usd(1000), Status: models.ItemStatusAvailable, Stock: 5}
+	create(t, db, &item)
+	alert := models.ItemAlert{UserID: 1, ItemID: item.ID, Kind: models.AlertKindBackInStock}
+	event := models.ItemEvent{ItemID: item.ID, Type: models.ItemEventStatusChanged,
+		OldStatus: models.ItemStatusOutOfStock, NewStatus: models.ItemStatusAvailable}
+	create(t, db, &alert, &event)
+
+	fail := make(map[int]bool)
+	for i := 1; i <= maxAttempts+1; i++ {
+		fail[i] = true
+	}
+	notifier := &flakyNotifier{Outbox: notify.NewOutbox(), fail: fail}
+	d := NewDispatcher(db, notifier)
+	for i := 0; i < maxAttempts+1; i++ {
+		run(t, d)
+	}
+	if notifier.calls != maxAttempts {
+		t.Errorf("tried %d times, want %d", notifier.calls, maxAttempts)
+	}
+	if err := db.First(&event, event.ID).Error; err != nil {
+		t.Fatalf("find event: %v", err)
+	}
+	if event.ProcessedAt == nil || event.Attempts != maxAttempts {
+		t.Errorf("processed %v after %d attempts, want given up after %d", event.ProcessedAt, event.Attempts, maxAttempts)
+	}
+}
@@ -0,0 +1,148 @@
+package carts
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+)
+
+// batchSize is the number of carts expired per statement.
+const batchSize = 500
+
+// Metrics of the expiry job, published under "cart_expiry" in expvar.
+var (
+	metrics        = expvar.NewMap("cart_expiry")
+	runs           = new(expvar.Int)
+	expiredGuest   = new(expvar.Int)
+	expiredUser    = new(expvar.Int)
+	purgedLines    = new(expvar.Int)
+	lastRunExpired = new(expvar.Int)
+	lastRunAt      = new(expvar.String)
+)
+
+func init() {
+	metrics.Set("runs", runs)
+	metrics.Set("expired_guest_carts", expiredGuest)
+	metrics.Set("expired_user_carts", expiredUser)
+	metrics.Set("purged_lines", purgedLines)
+	metrics.Set("last_run_expired", lastRunExpired)
+	metrics.Set("last_run_at", lastRunAt)
+}
+
+// Expirer marks active carts without activity for longer than their TTL as
+// expired and deletes their lines. Guest carts, which belong to a session,
+// and user carts have separate TTLs; a zero TTL never expires them. Run is
+// meant to be called periodically.
+type Expirer struct {
+	DB       *gorm.DB
+	GuestTTL time.Duration
+	UserTTL  time.Duration
+	Now      func() time.Time
+}
+
+func NewExpirer(db *gorm.DB, guestTTL, userTTL time.Duration) *Expirer {
+	return &Expirer{DB: db, GuestTTL: guestTTL, UserTTL: userTTL, Now: time.Now}
+}
+
+// Run expires stale guest carts and then stale user carts, and records
+// how many it expired.
+func (e *Expirer) Run(ctx context.Context) error {
+	now := e.Now()
+	guest, guestLines, err := e.expire(ctx, "carts.user_id IS NULL", e.GuestTTL, now)
+	if err != nil {
+		return err
+	}
+	user, userLines, err := e.expire(ctx, "carts.user_id IS NOT NULL", e.UserTTL, now)
+	if err != nil {
+		return err
+	}
+
+	runs.Add(1)
+	expiredGuest.Add(int64(guest))
+	expiredUser.Add(int64(user))
+	purgedLines.Add(int64(guestLines + userLines))
+	lastRunExpired.Set(int64(guest + user))
+	lastRunAt.Set(now.UTC().Format(time.RFC3339))
+	if guest+user > 0 {
+		log.Printf("Cart expiry: expired %d guest and %d user carts, purged %d lines",
+			guest, user, guestLines+userLines)
+	}
+	return nil
+}
+
+// expire expires the active carts matching owner that saw no activity
+// since now-ttl, in batches. It returns the number of carts expired and of
+// lines deleted.
+func (e *Expirer) expire(ctx context.Context, owner string, ttl time.Duration, now time.Time) (int, int, error) {
+	if ttl <= 0 {
+		return 0, 0, nil
+	}
+	cutoff := now.Add(-ttl)
+
+	carts, lines := 0, 0
+	for {
+		if ctx.Err() != nil {
+			return carts, lines, ctx.Err()
+		}
+		n, l, err := e.expireBatch(owner, cutoff, now)
+		carts += n
+		lines += l
+		if err != nil || n < batchSize {
+			return carts, lines, err
+		}
+	}
+}
+
+// expireBatch expires up to batchSize stale carts in one transaction.
+func (e *Expirer) expireBatch(owner string, cutoff, now time.Time) (int, int, error) {
+	tx := e.DB.Begin()
+	if tx.Error != nil {
+		return 0, 0, tx.Error
+	}
+	defer tx.Rollback()
+
+	var ids []uint
+	if err := tx.Model(&models.Cart{}).
+		Where("carts.status = ? AND "+owner, models.CartStatusActive).
+		Scopes(idleSince(cutoff)).
+		Order("carts.id").
+		Limit(batchSize).
+		Pluck("carts.id", &ids).Error; err != nil {
+		return 0, 0, err
+	}
+	if len(ids) == 0 {
+		return 0, 0, nil
+	}
+
+	if err := tx.Model(&models.Cart{}).Where("id IN (?)", ids).
+		UpdateColumns(map[string]interface{}{"status": models.CartStatusExpired, "updated_at": now}).Error; err != nil {
+		return 0, 0, err
+	}
+	items := tx.Where("cart_id IN (?)", ids).Delete(&models.CartItem{})
+	if items.Error != nil {
+		return 0, 0, items.Error
+	}
+	bundles := tx.Where("cart_id IN (?)", ids).Delete(&models.CartBundle{})
+	if bundles.Error != nil {
+		return 0, 0, bundles.Error
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, 0, err
+	}
+	return len(ids), int(items.RowsAffected + bundles.RowsAffected), nil
+}
+
+// idleSince limits a query on carts to those that did not change since
+// cutoff. A cart counts as active while it or any of its lines changed, as
+// adding to a cart only touches the line.
+func idleSince(cutoff time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("carts.updated_at < ?", cutoff).
+			Where("NOT EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.id AND cart_items.updated_at >= ?)", cutoff).
+			Where("NOT EXISTS (SELECT 1 FROM cart_bundles WHERE cart_bundles.cart_id = carts.id AND cart_bundles.updated_at >= ?)", cutoff)
+	}
+}
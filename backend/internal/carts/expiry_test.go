@@ -0,0 +1,126 @@
+package carts
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "modernc.org/sqlite"
+	"ecommerce-app/internal/models"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "carts.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	db, err := gorm.Open("sqlite3", sqlDB)
+	if err != nil {
+		t.Fatalf("open gorm: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.AutoMigrate(&models.User{}, &models.Cart{}, &models.CartItem{}, &models.CartBundle{}).Error; err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// createCart creates a cart last changed at updated with one item line
+// changed at lineUpdated, and a bundle line when bundle is set.
+func createCart(t *testing.T, db *gorm.DB, cart models.Cart, updated, lineUpdated time.Time, bundle bool) models.Cart {
+	t.Helper()
+	cart.UpdatedAt = updated
+	if err := db.Create(&cart).Error; err != nil {
+		t.Fatalf("create cart: %v", err)
+	}
+	if err := db.Create(&models.CartItem{CartID: cart.ID, ItemID: 1, Quantity: 2, UpdatedAt: lineUpdated}).Error; err != nil {
+		t.Fatalf("create cart item: %v", err)
+	}
+	if bundle {
+		if err := db.Create(&models.CartBundle{CartID: cart.ID, BundleID: 1, Quantity: 1, UpdatedAt: lineUpdated}).Error; err != nil {
+			t.Fatalf("create cart bundle: %v", err)
+		}
+	}
+	return cart
+}
+
+func TestExpirerRun(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now().Truncate(time.Second)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+	userID := uint(7)
+
+	tests := []struct {
+		name       string
+		cart       models.Cart
+		updated    time.Duration
+		line       time.Duration
+		wantStatus string
+	}{
+		{"idle guest", models.Cart{SessionID: "a"}, 2 * time.Hour, 2 * time.Hour, models.CartStatusExpired},
+		{"recent guest", models.Cart{SessionID: "b"}, 30 * time.Minute, 30 * time.Minute, models.CartStatusActive},
+		// Adding to a cart only touches its line
+		{"guest with a recent line", models.Cart{SessionID: "c"}, 2 * time.Hour, 10 * time.Minute, models.CartStatusActive},
+		{"user within its TTL", models.Cart{UserID: &userID}, 2 * time.Hour, 2 * time.Hour, models.CartStatusActive},
+		{"saved user", models.Cart{UserID: &userID, Status: models.CartStatusSaved, Name: "Later"}, 48 * time.Hour, 48 * time.Hour, models.CartStatusSaved},
+		{"idle user", models.Cart{UserID: &userID}, 25 * time.Hour, 25 * time.Hour, models.CartStatusExpired},
+	}
+	ids := make([]uint, len(tests))
+	for i, tt := range tests {
+		ids[i] = createCart(t, db, tt.cart, ago(tt.updated), ago(tt.line), true).ID
+	}
+
+	e := NewExpirer(db, time.Hour, 24*time.Hour)
+	e.Now = func() time.Time { return now }
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for i, tt := range tests {
+		var cart models.Cart
+		if err := db.First(&cart, ids[i]).Error; err != nil {
+			t.Fatalf("find cart: %v", err)
+		}
+		if cart.Status != tt.wantStatus {
+			t.Errorf("%s: status %s, want %s", tt.name, cart.Status, tt.wantStatus)
+		}
+		// Expired carts lose their lines
+		var items, bundles int
+		db.Model(&models.CartItem{}).Where("cart_id = ?", cart.ID).Count(&items)
+		db.Model(&models.CartBundle{}).Where("cart_id = ?", cart.ID).Count(&bundles)
+		if want := tt.wantStatus != models.CartStatusExpired; (items == 1 && bundles == 1) != want {
+			t.Errorf("%s: %d item and %d bundle lines left, want lines kept: %v", tt.name, items, bundles, want)
+		}
+	}
+}
+
+func TestExpirerZeroTTL(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now().Truncate(time.Second)
+	long := now.Add(-365 * 24 * time.Hour)
+	userID := uint(7)
+	guest := createCart(t, db, models.Cart{SessionID: "a"}, long, long, false)
+	user := createCart(t, db, models.Cart{UserID: &userID}, long, long, false)
+
+	e := NewExpirer(db, time.Hour, 0)
+	e.Now = func() time.Time { return now }
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	for _, tt := range []struct {
+		cart models.Cart
+		want string
+	}{{guest, models.CartStatusExpired}, {user, models.CartStatusActive}} {
+		var got models.Cart
+		if err := db.First(&got, tt.cart.ID).Error; err != nil {
+			t.Fatalf("find cart: %v", err)
+		}
+		if got.Status != tt.want {
+			t.Errorf("cart %d: status %s, want %s", got.ID, got.Status, tt.want)
+		}
+	}
+}
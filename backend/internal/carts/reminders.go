@@ -0,0 +1,156 @@
+package carts
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/notify"
+	"ecommerce-app/internal/signing"
+)
+
+// ReminderKind is the notify.Message kind of abandoned cart reminders.
+const ReminderKind = "cart_reminder"
+
+// Metrics of the reminder job, published under "cart_reminders" in expvar.
+var (
+	reminderMetrics = expvar.NewMap("cart_reminders")
+	remindersSent   = new(expvar.Int)
+	remindersFailed = new(expvar.Int)
+)
+
+func init() {
+	reminderMetrics.Set("sent", remindersSent)
+	reminderMetrics.Set("failed", remindersFailed)
+}
+
+// RestoreSubject is what a signed link restoring the cart grants.
+func RestoreSubject(cartID uint) string {
+	return fmt.Sprintf("cart-restore:%d", cartID)
+}
+
+// OptOutSubject is what a signed link stopping the reminders of a user
+// grants.
+func OptOutSubject(userID uint) string {
+	return fmt.Sprintf("cart-reminders-opt-out:%d", userID)
+}
+
+// Reminder reminds signed-in users of the items left in their active cart.
+// A cart is reminded about once it has been idle for IdleAfter, then again
+// every IdleAfter while it stays idle, at most MaxReminders times. Users
+// who opted out are skipped. Run is meant to be called periodically.
+type Reminder struct {
+	DB           *gorm.DB
+	Notifier     notify.Notifier
+	Signer       *signing.Signer
+	IdleAfter    time.Duration
+	MaxReminders int
+	// LinkTTL is how long the links in a reminder work.
+	LinkTTL time.Duration
+	// BaseURL is prepended to the links, e.g. "https://shop.example.com".
+	BaseURL string
+	Now     func() time.Time
+}
+
+func NewReminder(db *gorm.DB, notifier notify.Notifier, signer *signing.Signer) *Reminder {
+	return &Reminder{
+		DB:           db,
+		Notifier:     notifier,
+		Signer:       signer,
+		IdleAfter:    24 * time.Hour,
+		MaxReminders: 2,
+		LinkTTL:      7 * 24 * time.Hour,
+		Now:          time.Now,
+	}
+}
+
+// reminderCart is a cart due for a reminder with its number of units.
+type reminderCart struct {
+	ID            uint
+	UserID        uint
+	ReminderCount int
+	Units         int
+}
+
+// Run sends the reminders that are due, up to batchSize per run. A cart's
+// reminder count goes up as soon as its reminder is sent; a failed
+// reminder is retried on the next run.
+func (r *Reminder) Run(ctx context.Context) error {
+	if r.MaxReminders <= 0 || r.IdleAfter <= 0 {
+		return nil
+	}
+	now := r.Now()
+	cutoff := now.Add(-r.IdleAfter)
+
+	var due []reminderCart
+	if err := r.DB.Table("carts").
+		Select(`carts.id, carts.user_id, carts.reminder_count,
+			COALESCE((SELECT SUM(quantity) FROM cart_items WHERE cart_items.cart_id = carts.id), 0)
+			+ COALESCE((SELECT SUM(quantity) FROM cart_bundles WHERE cart_bundles.cart_id = carts.id), 0) AS units`).
+		Joins("JOIN users ON users.id = carts.user_id").
+		Where("carts.status = ? AND users.cart_reminders_opt_out = ?", models.CartStatusActive, false).
+		Where("carts.reminder_count < ?", r.MaxReminders).
+		Where("carts.reminded_at IS NULL OR carts.reminded_at < ?", cutoff).
+		Scopes(idleSince(cutoff)).
+		Order("carts.id").
+		Limit(batchSize).
+		Scan(&due).Error; err != nil {
+		return err
+	}
+
+	sent := 0
+	for _, cart := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Emptied carts stay idle but have nothing to come back for
+		if cart.Units == 0 {
+			continue
+		}
+		if err := r.Notifier.Notify(ctx, r.message(cart, now)); err != nil {
+			log.Printf("Failed to send reminder for cart %d: %v", cart.ID, err)
+			remindersFailed.Add(1)
+			continue
+		}
+		// UpdateColumns leaves updated_at alone, so reminding does not count
+		// as activity that keeps the cart from expiring
+		if err := r.DB.Model(&models.Cart{}).Where("id = ?", cart.ID).UpdateColumns(map[string]interface{}{
+			"reminder_count": gorm.Expr("reminder_count + 1"),
+			"reminded_at":    now,
+		}).Error; err != nil {
+			return err
+		}
+		remindersSent.Add(1)
+		sent++
+	}
+	if sent > 0 {
+		log.Printf("Cart reminders: sent %d reminders", sent)
+	}
+	return nil
+}
+
+// message is the reminder for cart, with links to restore the cart and to
+// stop further reminders.
+func (r *Reminder) message(cart reminderCart, now time.Time) notify.Message {
+	expires := now.Add(r.LinkTTL).Truncate(time.Second)
+	restore := fmt.Sprintf("%s/api/carts/%d/restore?expires=%d&signature=%s",
+		r.BaseURL, cart.ID, expires.Unix(), r.Signer.Sign(RestoreSubject(cart.ID), expires))
+	optOut := fmt.Sprintf("%s/api/users/%d/cart-reminders/opt-out?expires=%d&signature=%s",
+		r.BaseURL, cart.UserID, expires.Unix(), r.Signer.Sign(OptOutSubject(cart.UserID), expires))
+
+	units := "1 item"
+	if cart.Units != 1 {
+		units = fmt.Sprintf("%d items", cart.Units)
+	}
+	return notify.Message{
+		UserID:  cart.UserID,
+		Kind:    ReminderKind,
+		Subject: "You left items in your cart",
+		Body: fmt.Sprintf("Your cart still holds %s. Pick up where you left off: %s\n\n"+
+			"To stop these reminders: %s", units, restore, optOut),
+	}
+}
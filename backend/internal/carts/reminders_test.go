@@ -0,0 +1,192 @@
+package carts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/notify"
+	"ecommerce-app/internal/signing"
+)
+
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(ctx context.Context, msg notify.Message) error {
+	return errors.New("mail server down")
+}
+
+var linkPattern = regexp.MustCompile(`https://shop\.example\.com/\S+`)
+
+func createUser(t *testing.T, db *gorm.DB, name string, optOut bool) models.User {
+	t.Helper()
+	user := models.User{Username: name, Password: "x", CartRemindersOptOut: optOut}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func TestReminderRun(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now().Truncate(time.Second)
+	idle := now.Add(-25 * time.Hour)
+
+	ann := createUser(t, db, "ann", false)
+	bob := createUser(t, db, "bob", true)
+	cy := createUser(t, db, "cy", false)
+	dee := createUser(t, db, "dee", false)
+	// Two units of an item and a bundle
+	annCart := createCart(t, db, models.Cart{UserID: &ann.ID}, idle, idle, true)
+	// Opted out
+	createCart(t, db, models.Cart{UserID: &bob.ID}, idle, idle, true)
+	// Not idle long enough
+	createCart(t, db, models.Cart{UserID: &dee.ID}, now.Add(-time.Hour), now.Add(-time.Hour), true)
+	// Empty
+	empty := models.Cart{UserID: &cy.ID, UpdatedAt: idle}
+	if err := db.Create(&empty).Error; err != nil {
+		t.Fatalf("create cart: %v", err)
+	}
+	// Guests get no reminders
+	createCart(t, db, models.Cart{SessionID: "guest"}, idle, idle, true)
+
+	outbox := notify.NewOutbox()
+	signer := signing.NewSigner([]byte("secret"))
+	signer.Now = func() time.Time { return now }
+	r := NewReminder(db, outbox, signer)
+	r.BaseURL = "https://shop.example.com"
+	r.Now = func() time.Time { return now }
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	messages := outbox.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d reminders, want 1: %+v", len(messages), messages)
+	}
+	msg := messages[0]
+	if msg.UserID != ann.ID || msg.Kind != ReminderKind {
+		t.Errorf("reminder to user %d of kind %q, want %d %q", msg.UserID, msg.Kind, ann.ID, ReminderKind)
+	}
+	if want := "Your cart still holds 3 items."; !strings.Contains(msg.Body, want) {
+		t.Errorf("body %q does not say %q", msg.Body, want)
+	}
+
+	links := linkPattern.FindAllString(msg.Body, -1)
+	if len(links) != 2 {
+		t.Fatalf("got links %v, want a restore and an opt-out link", links)
+	}
+	expires := now.Add(r.LinkTTL)
+	checkLink(t, signer, links[0], fmt.Sprintf("/api/carts/%d/restore", annCart.ID), RestoreSubject(annCart.ID), expires)
+	checkLink(t, signer, links[1], fmt.Sprintf("/api/users/%d/cart-reminders/opt-out", ann.ID), OptOutSubject(ann.ID), expires)
+
+	// Reminding does not count as activity on the cart
+	var cart models.Cart
+	if err := db.First(&cart, annCart.ID).Error; err != nil {
+		t.Fatalf("find cart: %v", err)
+	}
+	if cart.ReminderCount != 1 || cart.RemindedAt == nil || !cart.UpdatedAt.Equal(idle) {
+		t.Errorf("cart reminded %d times at %v, updated %v; want once at %v, updated %v",
+			cart.ReminderCount, cart.RemindedAt, cart.UpdatedAt, now, idle)
+	}
+}
+
+// checkLink checks that link goes to path and is signed for subject until
+// expires, and only for that subject.
+func checkLink(t *testing.T, signer *signing.Signer, link, path, subject string, expires time.Time) {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parse %q: %v", link, err)
+	}
+	if u.Path != path {
+		t.Errorf("link %q goes to %s, want %s", link, u.Path, path)
+	}
+	exp, err := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+	if err != nil || exp != expires.Unix() {
+		t.Errorf("link %q expires at %q, want %d", link, u.Query().Get("expires"), expires.Unix())
+	}
+	signature := u.Query().Get("signature")
+	if err := signer.Verify(subject, exp, signature); err != nil {
+		t.Errorf("link %q: %v", link, err)
+	}
+	if err := signer.Verify(subject+"0", exp, signature); err != signing.ErrInvalidSignature {
+		t.Errorf("link %q also works for %s: %v", link, subject+"0", err)
+	}
+}
+
+func TestReminderRepeats(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now().Truncate(time.Second)
+	user := createUser(t, db, "ann", false)
+	cart := createCart(t, db, models.Cart{UserID: &user.ID}, now.Add(-25*time.Hour), now.Add(-25*time.Hour), false)
+
+	outbox := notify.NewOutbox()
+	r := NewReminder(db, outbox, signing.NewSigner([]byte("secret")))
+	r.IdleAfter, r.MaxReminders = 24*time.Hour, 2
+
+	// The second reminder waits for another IdleAfter; none come after
+	// MaxReminders
+	steps := []struct {
+		after time.Duration
+		want  int
+	}{
+		{0, 1},
+		{time.Hour, 1},
+		{24*time.Hour + time.Minute, 2},
+		{72 * time.Hour, 2},
+	}
+	for _, step := range steps {
+		r.Now = func() time.Time { return now.Add(step.after) }
+		if err := r.Run(context.Background()); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if got := len(outbox.Messages()); got != step.want {
+			t.Errorf("after %v: %d reminders, want %d", step.after, got, step.want)
+		}
+	}
+
+	var got models.Cart
+	if err := db.First(&got, cart.ID).Error; err != nil {
+		t.Fatalf("find cart: %v", err)
+	}
+	if got.ReminderCount != 2 {
+		t.Errorf("reminder count %d, want 2", got.ReminderCount)
+	}
+}
+
+func TestReminderRetriesFailures(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now().Truncate(time.Second)
+	user := createUser(t, db, "ann", false)
+	cart := createCart(t, db, models.Cart{UserID: &user.ID}, now.Add(-25*time.Hour), now.Add(-25*time.Hour), false)
+
+	r := NewReminder(db, failingNotifier{}, signing.NewSigner([]byte("secret")))
+	r.Now = func() time.Time { return now }
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	var got models.Cart
+	if err := db.First(&got, cart.ID).Error; err != nil {
+		t.Fatalf("find cart: %v", err)
+	}
+	if got.ReminderCount != 0 || got.RemindedAt != nil {
+		t.Errorf("failed reminder counted: %d at %v", got.ReminderCount, got.RemindedAt)
+	}
+
+	outbox := notify.NewOutbox()
+	r.Notifier = outbox
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := len(outbox.Messages()); got != 1 {
+		t.Errorf("%d reminders on retry, want 1", got)
+	}
+}
@@ -0,0 +1,348 @@
+package catalog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+)
+
+// AttributeFilter restricts items to those with a matching value for one
+// attribute. Which fields are used depends on the attribute type: Values for
+// enums, Min and Max for numbers and Bool for booleans.
+type AttributeFilter struct {
+	Attribute models.AttributeDefinition
+	Values    []string
+	Min       *float64
+	Max       *float64
+	Bool      *bool
+}
+
+// Facet is the breakdown of one attribute over the items matching a filter
+// set. Counts ignore the facet's own filter, so shoppers can see how many
+// items they would get by picking another value.
+type Facet struct {
+	Code   string       `json:"code"`
+	Name   string       `json:"name"`
+	Type   string       `json:"type"`
+	Unit   string       `json:"unit,omitempty"`
+	Min    *float64     `json:"min,omitempty"`
+	Max    *float64     `json:"max,omitempty"`
+	Values []FacetValue `json:"values"`
+}
+
+// FacetValue is the number of matching items with one attribute value.
+type FacetValue struct {
+	Value    interface{} `json:"value"`
+	Count    int         `json:"count"`
+	Selected bool        `json:"selected"`
+}
+
+// LoadAttributes returns every attribute definition with its options, in
+// display order.
+func LoadAttributes(db *gorm.DB) ([]models.AttributeDefinition, error) {
+	var defs []models.AttributeDefinition
+	err := db.Preload("Options", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).Order("position, id").Find(&defs).Error
+	return defs, err
+}
+
+// ParseAttributeFilters turns filter[code]=value query parameters into
+// filters. Enums take a comma-separated list of options, numbers an exact
+// value or a min..max range with either end optional, and booleans true or
+// false.
+func ParseAttributeFilters(defs []models.AttributeDefinition, params map[string]string) ([]AttributeFilter, error) {
+	byCode := make(map[string]models.AttributeDefinition, len(defs))
+	for _, def := range defs {
+		byCode[def.Code] = def
+	}
+
+	filters := make([]AttributeFilter, 0, len(params))
+	for code, raw := range params {
+		def, ok := byCode[code]
+		if !ok {
+			return nil, fmt.Errorf("unknown attribute %q", code)
+		}
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		f := AttributeFilter{Attribute: def}
+		switch def.Type {
+		case models.AttributeTypeEnum:
+			for _, v := range strings.Split(raw, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					f.Values = append(f.Values, canonicalOption(def, v))
+				}
+			}
+		case models.AttributeTypeNumber:
+			lo, hi := raw, raw
+			if i := strings.Index(raw, ".."); i >= 0 {
+				lo, hi = raw[:i], raw[i+2:]
+			}
+			var err error
+			if f.Min, err = parseBound(lo); err != nil {
+				return nil, fmt.Errorf("invalid value %q for %s", raw, code)
+			}
+			if f.Max, err = parseBound(hi); err != nil {
+				return nil, fmt.Errorf("invalid value %q for %s", raw, code)
+			}
+		case models.AttributeTypeBoolean:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for %s", raw, code)
+			}
+			f.Bool = &b
+		}
+		filters = append(filters, f)
+	}
+
+	// Map order is random; keep the generated SQL stable
+	sort.Slice(filters, func(i, j int) bool {
+		a, b := filters[i].Attribute, filters[j].Attribute
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		return a.ID < b.ID
+	})
+	return filters, nil
+}
+
+func parseBound(s string) (*float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// canonicalOption returns the option of def that matches value ignoring
+// case, or value itself when there is none.
+func canonicalOption(def models.AttributeDefinition, value string) string {
+	for _, opt := range def.Options {
+		if strings.EqualFold(opt.Value, value) {
+			return opt.Value
+		}
+	}
+	return value
+}
+
+// clause is the SQL condition on items.id for the filter.
+func (f AttributeFilter) clause() (string, []interface{}) {
+	sql := "EXISTS (SELECT 1 FROM item_attribute_values f WHERE f.item_id = items.id AND f.attribute_id = ?"
+	args := []interface{}{f.Attribute.ID}
+	switch {
+	case f.Values != nil:
+		sql += " AND f.text_value IN (?)"
+		args = append(args, f.Values)
+	case f.Bool != nil:
+		sql += " AND f.bool_value = ?"
+		args = append(args, *f.Bool)
+	default:
+		sql += " AND f.number_value IS NOT NULL"
+		if f.Min != nil {
+			sql += " AND f.number_value >= ?"
+			args = append(args, *f.Min)
+		}
+		if f.Max != nil {
+			sql += " AND f.number_value <= ?"
+			args = append(args, *f.Max)
+		}
+	}
+	return sql + ")", args
+}
+
+// ApplyAttributeFilters adds the filters to a query on the items table.
+func ApplyAttributeFilters(db *gorm.DB, filters []AttributeFilter) *gorm.DB {
+	return applyFiltersExcept(db, filters, 0)
+}
+
+func applyFiltersExcept(db *gorm.DB, filters []AttributeFilter, skip uint) *gorm.DB {
+	for _, f := range filters {
+		if f.Attribute.ID == skip {
+			continue
+		}
+		sql, args := f.clause()
+		db = db.Where(sql, args...)
+	}
+	return db
+}
+
+// Facets counts the values of every attribute over the items matched by
+// items and filters. items is the base query and may carry conditions on
+// the items table; it must not select or order anything.
+func Facets(items *gorm.DB, defs []models.AttributeDefinition, filters []AttributeFilter) ([]Facet, error) {
+	selected := make(map[uint]AttributeFilter, len(filters))
+	for _, f := range filters {
+		selected[f.Attribute.ID] = f
+	}
+
+	facets := make([]Facet, 0, len(defs))
+	for _, def := range defs {
+		column := valueColumn(def.Type)
+		rows, err := applyFiltersExcept(items, filters, def.ID).
+			Table("item_attribute_values v").
+			Joins("JOIN items ON items.id = v.item_id").
+			Where("v.attribute_id = ? AND v."+column+" IS NOT NULL", def.ID).
+			Select("v." + column + ", COUNT(*)").
+			Group("v." + column).
+			Order("v." + column).
+			Rows()
+		if err != nil {
+			return nil, err
+		}
+
+		facet := Facet{Code: def.Code, Name: def.Name, Type: def.Type, Unit: def.Unit, Values: []FacetValue{}}
+		counts := make(map[interface{}]int)
+		for rows.Next() {
+			var count int
+			var value interface{}
+			switch def.Type {
+			case models.AttributeTypeEnum:
+				var s string
+				err = rows.Scan(&s, &count)
+				value = s
+			case models.AttributeTypeNumber:
+				var n float64
+				err = rows.Scan(&n, &count)
+				value = n
+				if facet.Min == nil || n < *facet.Min {
+					facet.Min = &n
+				}
+				if facet.Max == nil || n > *facet.Max {
+					facet.Max = &n
+				}
+			case models.AttributeTypeBoolean:
+				var b bool
+				err = rows.Scan(&b, &count)
+				value = b
+			}
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			counts[value] += count
+			if def.Type == models.AttributeTypeNumber {
+				facet.Values = append(facet.Values, FacetValue{Value: value, Count: count})
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		// Enum options and both booleans are listed even without matches, so
+		// the sidebar does not jump around as filters change
+		f, isSelected := selected[def.ID]
+		switch def.Type {
+		case models.AttributeTypeEnum:
+			for _, opt := range def.Options {
+				facet.Values = append(facet.Values, FacetValue{
+					Value:    opt.Value,
+					Count:    counts[opt.Value],
+					Selected: isSelected && containsString(f.Values, opt.Value),
+				})
+			}
+		case models.AttributeTypeNumber:
+			for i := range facet.Values {
+				n := facet.Values[i].Value.(float64)
+				facet.Values[i].Selected = isSelected &&
+					(f.Min == nil || n >= *f.Min) && (f.Max == nil || n <= *f.Max)
+			}
+		case models.AttributeTypeBoolean:
+			for _, b := range []bool{true, false} {
+				facet.Values = append(facet.Values, FacetValue{
+					Value:    b,
+					Count:    counts[b],
+					Selected: isSelected && *f.Bool == b,
+				})
+			}
+		}
+		facets = append(facets, facet)
+	}
+	return facets, nil
+}
+
+// ParseAttributeValue checks a value decoded from JSON against the attribute
+// type and returns it as an item value. Enum values are matched to an
+// option ignoring case.
+func ParseAttributeValue(def models.AttributeDefinition, raw interface{}) (models.ItemAttributeValue, error) {
+	v := models.ItemAttributeValue{AttributeID: def.ID}
+	switch def.Type {
+	case models.AttributeTypeEnum:
+		s, ok := raw.(string)
+		if !ok {
+			return v, fmt.Errorf("%s must be a string", def.Code)
+		}
+		opt := canonicalOption(def, strings.TrimSpace(s))
+		if !hasOption(def, opt) {
+			return v, fmt.Errorf("%q is not an option of %s", s, def.Code)
+		}
+		v.TextValue = &opt
+	case models.AttributeTypeNumber:
+		n, ok := raw.(float64)
+		if !ok {
+			return v, fmt.Errorf("%s must be a number", def.Code)
+		}
+		v.NumberValue = &n
+	case models.AttributeTypeBoolean:
+		b, ok := raw.(bool)
+		if !ok {
+			return v, fmt.Errorf("%s must be true or false", def.Code)
+		}
+		v.BoolValue = &b
+	default:
+		return v, fmt.Errorf("%s has unknown type %q", def.Code, def.Type)
+	}
+	return v, nil
+}
+
+// AttributeValue returns the typed value stored in v.
+func AttributeValue(v models.ItemAttributeValue) interface{} {
+	switch {
+	case v.TextValue != nil:
+		return *v.TextValue
+	case v.NumberValue != nil:
+		return *v.NumberValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	}
+	return nil
+}
+
+func valueColumn(attrType string) string {
+	switch attrType {
+	case models.AttributeTypeNumber:
+		return "number_value"
+	case models.AttributeTypeBoolean:
+		return "bool_value"
+	}
+	return "text_value"
+}
+
+func hasOption(def models.AttributeDefinition, value string) bool {
+	for _, opt := range def.Options {
+		if opt.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package catalog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+)
+
+// maxCacheEntries bounds the memory used by cached responses. The cache is
+// emptied when it fills up, which only happens with many distinct filters.
+const maxCacheEntries = 1000
+
+// Version identifies the state of the catalog. It changes whenever an item
+// is written or enters or leaves its publish window.
+type Version struct {
+	// LastModified is the newest updated_at of any item, or the latest
+	// publish window boundary passed if that is newer.
+	LastModified time.Time
+	// ETag is derived from LastModified, the number of items and the
+	// number of invalidations, so two writes within the same second still
+	// produce a new tag.
+	ETag string
+}
+
+// Cache keeps rendered catalog responses in memory for the catalog version
+// they were rendered at. Handlers that write items call Invalidate after
+// committing. Writes that bypass the handlers are picked up once TTL has
+// passed, when the version is read from the database again; so are items
+// entering or leaving their publish window.
+type Cache struct {
+	DB  *gorm.DB
+	TTL time.Duration
+	Now func() time.Time
+
+	mu         sync.Mutex
+	version    *Version
+	checkedAt  time.Time
+	generation uint64
+	entries    map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	etag        string
+	contentType string
+	body        []byte
+}
+
+func NewCache(db *gorm.DB, ttl time.Duration) *Cache {
+	return &Cache{DB: db, TTL: ttl, Now: time.Now, entries: make(map[string]cacheEntry)}
+}
+
+// Invalidate drops every cached response and forces the next request to
+// read the catalog version again. A nil cache does nothing, so handlers can
+// call it unconditionally.
+func (c *Cache) Invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.version = nil
+	c.entries = make(map[string]cacheEntry)
+}
+
+// Version returns the current catalog version, reading it from the database
+// when it is unknown or older than TTL.
+func (c *Cache) Version() (Version, error) {
+	c.mu.Lock()
+	if c.version != nil && c.Now().Sub(c.checkedAt) < c.TTL {
+		v := *c.version
+		c.mu.Unlock()
+		return v, nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	var count int
+	if err := c.DB.Model(&models.Item{}).Count(&count).Error; err != nil {
+		return Version{}, err
+	}
+	var newest time.Time
+	if count > 0 {
+		if err := c.DB.Model(&models.Item{}).Select("updated_at").Order("updated_at DESC").Limit(1).
+			Row().Scan(&newest); err != nil {
+			return Version{}, err
+		}
+		boundary, err := lastWindowBoundary(c.DB, c.Now())
+		if err != nil {
+			return Version{}, err
+		}
+		if boundary.After(newest) {
+			newest = boundary
+		}
+	}
+	v := Version{
+		LastModified: newest.UTC(),
+		ETag:         fmt.Sprintf("%x-%x-%x", newest.UnixNano(), count, generation),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// An invalidation while reading means v may already be stale
+	if c.generation == generation {
+		if c.version == nil || c.version.ETag != v.ETag {
+			c.entries = make(map[string]cacheEntry)
+		}
+		c.version = &v
+		c.checkedAt = c.Now()
+	}
+	return v, nil
+}
+
+// Get returns the response cached under key for catalog version v.
+func (c *Cache) Get(key string, v Version) (contentType string, body []byte, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || e.etag != v.ETag {
+		return "", nil, false
+	}
+	return e.contentType, e.body, true
+}
+
+// Put caches a response rendered at catalog version v.
+func (c *Cache) Put(key string, v Version, contentType string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version == nil || c.version.ETag != v.ETag {
+		// The catalog changed while the response was rendered
+		return
+	}
+	if len(c.entries) >= maxCacheEntries {
+		c.entries = make(map[string]cacheEntry)
+	}
+	c.entries[key] = cacheEntry{etag: v.ETag, contentType: contentType, body: body}
+}
+
+// TouchItems bumps updated_at of the given items, for writes to related
+// records such as images or reviews that change how the items are shown.
+func TouchItems(db *gorm.DB, ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return db.Model(&models.Item{}).Where("id IN (?)", ids).UpdateColumn("updated_at", time.Now()).Error
+}
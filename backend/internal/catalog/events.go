@@ -0,0 +1,31 @@
+package catalog
+
+import (
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+)
+
+// RecordStatusChange writes a status_changed event for the item inside tx.
+// Nothing is recorded when the status stays the same.
+func RecordStatusChange(tx *gorm.DB, itemID uint, from, to string) error {
+	if from == to {
+		return nil
+	}
+	return tx.Create(&models.ItemEvent{
+		ItemID:    itemID,
+		Type:      models.ItemEventStatusChanged,
+		OldStatus: from,
+		NewStatus: to,
+	}).Error
+}
+
+// recordPriceChange writes a price_changed event for the item inside tx.
+func recordPriceChange(tx *gorm.DB, itemID uint, from, to money.Money) error {
+	return tx.Create(&models.ItemEvent{
+		ItemID:   itemID,
+		Type:     models.ItemEventPriceChanged,
+		OldPrice: from,
+		NewPrice: to,
+	}).Error
+}
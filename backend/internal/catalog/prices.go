@@ -0,0 +1,217 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+)
+
+// ErrScheduleNotActive is returned when cancelling a scheduled price that has
+// already ended or been cancelled.
+var ErrScheduleNotActive = errors.New("scheduled price is no longer pending or active")
+
+// SetPrice changes the price of item inside tx and records the change in the
+// price history. Setting the price an item already has is a no-op. All price
+// writes go through here so the history stays complete and a price_changed
+// event is recorded.
+func SetPrice(tx *gorm.DB, item *models.Item, price money.Money, source string, changedBy *uint) error {
+	price = money.New(price.Amount, price.Currency)
+	if item.Price == price {
+		return nil
+	}
+
+	if err := tx.Model(&models.Item{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+		"price_amount":   price.Amount,
+		"price_currency": price.Currency,
+	}).Error; err != nil {
+		return err
+	}
+
+	change := models.PriceChange{
+		ItemID:    item.ID,
+		OldPrice:  item.Price,
+		NewPrice:  price,
+		Source:    source,
+		ChangedBy: changedBy,
+		ChangedAt: time.Now(),
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		return err
+	}
+	if err := recordPriceChange(tx, item.ID, item.Price, price); err != nil {
+		return err
+	}
+
+	item.Price = price
+	return nil
+}
+
+// RecordInitialPrice writes the first history entry for a newly created item.
+func RecordInitialPrice(tx *gorm.DB, item *models.Item, source string, changedBy *uint) error {
+	return tx.Create(&models.PriceChange{
+		ItemID:    item.ID,
+		OldPrice:  money.Zero(item.Price.Currency),
+		NewPrice:  item.Price,
+		Source:    source,
+		ChangedBy: changedBy,
+		ChangedAt: time.Now(),
+	}).Error
+}
+
+// PriceScheduler applies scheduled prices when they start and restores the
+// base price when they end. Run is meant to be called periodically.
+type PriceScheduler struct {
+	DB  *gorm.DB
+	Now func() time.Time
+	// OnChange, when set, is called after a run that changed prices.
+	OnChange func()
+}
+
+func NewPriceScheduler(db *gorm.DB) *PriceScheduler {
+	return &PriceScheduler{DB: db, Now: time.Now}
+}
+
+// Run ends expired scheduled prices and starts the ones that are due. Each
+// schedule is handled in its own transaction so one bad row does not hold
+// back the others.
+func (s *PriceScheduler) Run(ctx context.Context) error {
+	now := s.Now()
+
+	// End expired prices first so a back-to-back schedule starts from the
+	// restored base price
+	var expired []models.ScheduledPrice
+	if err := s.DB.Where("status = ? AND ends_at IS NOT NULL AND ends_at <= ?", models.ScheduledPriceActive, now).
+		Order("ends_at, id").Find(&expired).Error; err != nil {
+		return err
+	}
+	for _, sp := range expired {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.inTx(func(tx *gorm.DB) error { return endSchedule(tx, sp, models.ScheduledPriceEnded) }); err != nil {
+			log.Printf("Failed to end scheduled price %d: %v", sp.ID, err)
+		}
+	}
+
+	var due []models.ScheduledPrice
+	if err := s.DB.Where("status = ? AND starts_at <= ?", models.ScheduledPricePending, now).
+		Order("starts_at, id").Find(&due).Error; err != nil {
+		return err
+	}
+	for _, sp := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.inTx(func(tx *gorm.DB) error { return startSchedule(tx, sp, now) }); err != nil {
+			log.Printf("Failed to start scheduled price %d: %v", sp.ID, err)
+		}
+	}
+
+	if len(expired) > 0 || len(due) > 0 {
+		log.Printf("Price scheduler: %d ended, %d started", len(expired), len(due))
+		if s.OnChange != nil {
+			s.OnChange()
+		}
+	}
+	return nil
+}
+
+func (s *PriceScheduler) inTx(fn func(tx *gorm.DB) error) error {
+	tx := s.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// startSchedule applies sp to its item. A schedule whose window passed
+// before it could start is ended without touching the price.
+func startSchedule(tx *gorm.DB, sp models.ScheduledPrice, now time.Time) error {
+	if sp.EndsAt != nil && !sp.EndsAt.After(now) {
+		return setScheduleStatus(tx, sp.ID, models.ScheduledPricePending, models.ScheduledPriceEnded, nil)
+	}
+
+	var item models.Item
+	if err := tx.First(&item, sp.ItemID).Error; err != nil {
+		return err
+	}
+
+	// A newer schedule replaces one that is still running; the base price to
+	// restore afterwards is still the one from before the first schedule
+	base := item.Price
+	var running models.ScheduledPrice
+	err := tx.Where("item_id = ? AND status = ? AND id <> ?", sp.ItemID, models.ScheduledPriceActive, sp.ID).
+		First(&running).Error
+	if err == nil {
+		base = running.BasePrice
+		if err := setScheduleStatus(tx, running.ID, models.ScheduledPriceActive, models.ScheduledPriceEnded, nil); err != nil {
+			return err
+		}
+	} else if err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	if err := setScheduleStatus(tx, sp.ID, models.ScheduledPricePending, models.ScheduledPriceActive, &base); err != nil {
+		return err
+	}
+	return SetPrice(tx, &item, sp.Price, models.PriceSourceSchedule, sp.CreatedBy)
+}
+
+// endSchedule moves sp to status and restores the base price of an active
+// schedule, unless the price was changed by hand while it was running.
+func endSchedule(tx *gorm.DB, sp models.ScheduledPrice, status string) error {
+	if sp.Status == models.ScheduledPricePending {
+		return setScheduleStatus(tx, sp.ID, models.ScheduledPricePending, status, nil)
+	}
+	if err := setScheduleStatus(tx, sp.ID, models.ScheduledPriceActive, status, nil); err != nil {
+		return err
+	}
+
+	var item models.Item
+	if err := tx.First(&item, sp.ItemID).Error; err != nil {
+		return err
+	}
+	if item.Price != money.New(sp.Price.Amount, sp.Price.Currency) {
+		log.Printf("Item %d price changed while scheduled price %d was active, keeping %s", item.ID, sp.ID, item.Price)
+		return nil
+	}
+	return SetPrice(tx, &item, sp.BasePrice, models.PriceSourceSchedule, sp.CreatedBy)
+}
+
+// setScheduleStatus moves a schedule from one status to another. The status
+// check in the WHERE clause makes concurrent runs apply each step only once.
+func setScheduleStatus(tx *gorm.DB, id uint, from, to string, base *money.Money) error {
+	updates := map[string]interface{}{"status": to}
+	if base != nil {
+		updates["base_price_amount"] = base.Amount
+		updates["base_price_currency"] = base.Currency
+	}
+	res := tx.Model(&models.ScheduledPrice{}).Where("id = ? AND status = ?", id, from).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrScheduleNotActive
+	}
+	return nil
+}
+
+// CancelScheduledPrice cancels a pending schedule, or stops an active one
+// early and restores the base price.
+func CancelScheduledPrice(tx *gorm.DB, sp models.ScheduledPrice) error {
+	switch sp.Status {
+	case models.ScheduledPricePending, models.ScheduledPriceActive:
+		return endSchedule(tx, sp, models.ScheduledPriceCancelled)
+	default:
+		return ErrScheduleNotActive
+	}
+}
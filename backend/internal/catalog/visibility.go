@@ -0,0 +1,38 @@
+package catalog
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+)
+
+// Visible limits db to the items shoppers can see at now, the query form of
+// models.Item.Visible. Columns are qualified so it can be joined against.
+func Visible(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("items.visibility = ? AND (items.publish_at IS NULL OR items.publish_at <= ?) AND (items.unpublish_at IS NULL OR items.unpublish_at > ?)",
+		models.ItemVisibilityPublished, now, now)
+}
+
+// lastWindowBoundary returns the latest publish_at or unpublish_at that has
+// passed at now, or the zero time if none has. Items entering or leaving
+// their window change what the catalog shows without any write.
+func lastWindowBoundary(db *gorm.DB, now time.Time) (time.Time, error) {
+	var latest time.Time
+	for _, column := range []string{"publish_at", "unpublish_at"} {
+		var t time.Time
+		err := db.Model(&models.Item{}).Select(column).Where(column+" <= ?", now).
+			Order(column + " DESC").Limit(1).Row().Scan(&t)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+)
+
+type AlertHandler struct {
+	DB *gorm.DB
+}
+
+func NewAlertHandler(db *gorm.DB) *AlertHandler {
+	return &AlertHandler{DB: db}
+}
+
+type CreateAlertRequest struct {
+	Kind        string       `json:"kind" binding:"required,oneof=back_in_stock price_drop"`
+	TargetPrice *money.Money `json:"target_price"`
+}
+
+// alertURL is where a user subscribes to alerts for an item, returned with
+// errors about items that cannot be bought right now.
+func alertURL(itemID uint) string {
+	return fmt.Sprintf("/api/items/%d/alerts", itemID)
+}
+
+// CreateAlert subscribes the current user to an item. Back-in-stock alerts
+// are only accepted for items that cannot be bought right now. Price drop
+// alerts fire when the price falls below the current one, or to
+// target_price or lower when it is given.
+func (h *AlertHandler) CreateAlert(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	var item models.Item
+	if err := h.DB.First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+
+	alert := models.ItemAlert{
+		UserID:    userID,
+		ItemID:    item.ID,
+		Kind:      req.Kind,
+		Status:    models.AlertStatusActive,
+		BasePrice: item.Price,
+	}
+	switch req.Kind {
+	case models.AlertKindBackInStock:
+		if item.Purchasable(1) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Item is already available", "status": item.Status})
+			return
+		}
+	case models.AlertKindPriceDrop:
+		if req.TargetPrice != nil {
+			if req.TargetPrice.Currency != item.Price.Currency {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Target price must be in " + item.Price.Currency})
+				return
+			}
+			if req.TargetPrice.Amount <= 0 || req.TargetPrice.Amount >= item.Price.Amount {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Target price must be positive and below the current price",
+					"price": item.Price,
+				})
+				return
+			}
+			alert.TargetPrice = *req.TargetPrice
+		} else {
+			alert.TargetPrice = money.Zero(item.Price.Currency)
+		}
+	}
+
+	var existing int
+	if err := h.DB.Model(&models.ItemAlert{}).
+		Where("user_id = ? AND item_id = ? AND kind = ? AND status = ?", userID, item.ID, req.Kind, models.AlertStatusActive).
+		Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert"})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have an active alert of this kind for this item"})
+		return
+	}
+
+	if err := h.DB.Create(&alert).Error; err != nil {
+		log.Printf("Error creating alert: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert"})
+		return
+	}
+	c.JSON(http.StatusCreated, alert)
+}
+
+// ListAlerts returns the current user's alerts, newest first. ?status=
+// narrows the list to one status.
+func (h *AlertHandler) ListAlerts(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	query := h.DB.Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	alerts := []models.ItemAlert{}
+	if err := query.Order("created_at DESC, id DESC").Find(&alerts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+// CancelAlert stops an active alert of the current user from firing.
+func (h *AlertHandler) CancelAlert(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var alert models.ItemAlert
+	if err := h.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&alert).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alert"})
+		}
+		return
+	}
+	if alert.Status != models.AlertStatusActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Alert is no longer active", "status": alert.Status})
+		return
+	}
+
+	if err := h.DB.Model(&alert).Update("status", models.AlertStatusCancelled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel alert"})
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
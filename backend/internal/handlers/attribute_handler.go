@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/catalog"
+	"ecommerce-app/internal/models"
+)
+
+// attributeCodePattern keeps codes usable as filter[code] query keys.
+var attributeCodePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+type AttributeHandler struct {
+	DB    *gorm.DB
+	Cache *catalog.Cache
+}
+
+func NewAttributeHandler(db *gorm.DB, cache *catalog.Cache) *AttributeHandler {
+	return &AttributeHandler{DB: db, Cache: cache}
+}
+
+type CreateAttributeRequest struct {
+	Code     string   `json:"code" binding:"required"`
+	Name     string   `json:"name" binding:"required"`
+	Type     string   `json:"type" binding:"required,oneof=enum number boolean"`
+	Unit     string   `json:"unit" binding:"max=16"`
+	Position int      `json:"position"`
+	Options  []string `json:"options"`
+}
+
+// SetItemAttributesRequest maps attribute codes to values: a string option
+// for enums, a number or a boolean. Null removes the value.
+type SetItemAttributesRequest struct {
+	Attributes map[string]interface{} `json:"attributes" binding:"required"`
+}
+
+// AttributeValueResponse is an attribute of an item as shown to shoppers.
+type AttributeValueResponse struct {
+	Code  string      `json:"code"`
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Unit  string      `json:"unit,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// loadAttributeValues returns the attribute values of the given items keyed
+// by item ID, in attribute display order.
+func loadAttributeValues(db *gorm.DB, itemIDs []uint) (map[uint][]AttributeValueResponse, error) {
+	result := make(map[uint][]AttributeValueResponse)
+	if len(itemIDs) == 0 {
+		return result, nil
+	}
+
+	var defs []models.AttributeDefinition
+	if err := db.Order("position, id").Find(&defs).Error; err != nil {
+		return nil, err
+	}
+	var values []models.ItemAttributeValue
+	if err := db.Where("item_id IN (?)", itemIDs).Find(&values).Error; err != nil {
+		return nil, err
+	}
+
+	byAttribute := make(map[uint][]models.ItemAttributeValue)
+	for _, v := range values {
+		byAttribute[v.AttributeID] = append(byAttribute[v.AttributeID], v)
+	}
+	for _, def := range defs {
+		for _, v := range byAttribute[def.ID] {
+			result[v.ItemID] = append(result[v.ItemID], AttributeValueResponse{
+				Code:  def.Code,
+				Name:  def.Name,
+				Type:  def.Type,
+				Unit:  def.Unit,
+				Value: catalog.AttributeValue(v),
+			})
+		}
+	}
+	return result, nil
+}
+
+// attributeFilters parses the filter[code]=value parameters of the request.
+// On error it writes a 400 response and returns false.
+func attributeFilters(c *gin.Context, db *gorm.DB) ([]models.AttributeDefinition, []catalog.AttributeFilter, bool) {
+	defs, err := catalog.LoadAttributes(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attributes"})
+		return nil, nil, false
+	}
+	filters, err := catalog.ParseAttributeFilters(defs, c.QueryMap("filter"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter", "details": err.Error()})
+		return nil, nil, false
+	}
+	return defs, filters, true
+}
+
+// ListAttributes returns all attribute definitions with their options.
+func (h *AttributeHandler) ListAttributes(c *gin.Context) {
+	defs, err := catalog.LoadAttributes(h.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attributes"})
+		return
+	}
+	c.JSON(http.StatusOK, defs)
+}
+
+// CreateAttribute adds an attribute definition. Enum attributes need at
+// least one option; other types take none.
+func (h *AttributeHandler) CreateAttribute(c *gin.Context) {
+	var req CreateAttributeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	req.Code = strings.ToLower(strings.TrimSpace(req.Code))
+	if !attributeCodePattern.MatchString(req.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code must be lowercase letters, digits and underscores"})
+		return
+	}
+
+	def := models.AttributeDefinition{
+		Code:     req.Code,
+		Name:     strings.TrimSpace(req.Name),
+		Type:     req.Type,
+		Unit:     strings.TrimSpace(req.Unit),
+		Position: req.Position,
+	}
+	seen := make(map[string]bool)
+	for _, value := range req.Options {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[strings.ToLower(value)] {
+			continue
+		}
+		seen[strings.ToLower(value)] = true
+		def.Options = append(def.Options, models.AttributeOption{Value: value, Position: len(def.Options)})
+	}
+	if req.Type == models.AttributeTypeEnum && len(def.Options) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Enum attributes need at least one option"})
+		return
+	}
+	if req.Type != models.AttributeTypeEnum && len(def.Options) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only enum attributes take options"})
+		return
+	}
+
+	var existing int
+	if err := h.DB.Model(&models.AttributeDefinition{}).Where("code = ?", def.Code).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing attributes"})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "An attribute with this code already exists"})
+		return
+	}
+
+	// Options are created along with the definition
+	if err := h.DB.Create(&def).Error; err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "An attribute with this code already exists"})
+			return
+		}
+		log.Printf("Error creating attribute %s: %v", def.Code, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create attribute"})
+		return
+	}
+	// Facets list every attribute
+	h.Cache.Invalidate()
+
+	c.JSON(http.StatusCreated, def)
+}
+
+// DeleteAttribute removes an attribute definition along with its options
+// and every item value.
+func (h *AttributeHandler) DeleteAttribute(c *gin.Context) {
+	var def models.AttributeDefinition
+	if err := h.DB.First(&def, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attribute not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attribute"})
+		}
+		return
+	}
+
+	tx := h.DB.Begin()
+	var itemIDs []uint
+	if err := tx.Model(&models.ItemAttributeValue{}).Where("attribute_id = ?", def.ID).Pluck("item_id", &itemIDs).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attribute"})
+		return
+	}
+	if err := catalog.TouchItems(tx, itemIDs...); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attribute"})
+		return
+	}
+	if err := tx.Where("attribute_id = ?", def.ID).Delete(&models.ItemAttributeValue{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attribute"})
+		return
+	}
+	if err := tx.Where("attribute_id = ?", def.ID).Delete(&models.AttributeOption{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attribute"})
+		return
+	}
+	if err := tx.Delete(&def).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attribute"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attribute"})
+		return
+	}
+	h.Cache.Invalidate()
+
+	c.Status(http.StatusNoContent)
+}
+
+// SetItemAttributes replaces the attribute values of an item. Attributes
+// left out of the request are removed from the item.
+func (h *AttributeHandler) SetItemAttributes(c *gin.Context) {
+	var req SetItemAttributesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	var item models.Item
+	if err := h.DB.First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+
+	defs, err := catalog.LoadAttributes(h.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attributes"})
+		return
+	}
+	byCode := make(map[string]models.AttributeDefinition, len(defs))
+	for _, def := range defs {
+		byCode[def.Code] = def
+	}
+
+	// Validate everything before writing, so the response lists every problem
+	var values []models.ItemAttributeValue
+	var problems []string
+	for code, raw := range req.Attributes {
+		def, ok := byCode[code]
+		if !ok {
+			problems = append(problems, "unknown attribute "+code)
+			continue
+		}
+		if raw == nil {
+			continue
+		}
+		v, err := catalog.ParseAttributeValue(def, raw)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		v.ItemID = item.ID
+		values = append(values, v)
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attributes", "details": problems})
+		return
+	}
+
+	tx := h.DB.Begin()
+	if err := tx.Where("item_id = ?", item.ID).Delete(&models.ItemAttributeValue{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update attributes"})
+		return
+	}
+	for i := range values {
+		if err := tx.Create(&values[i]).Error; err != nil {
+			tx.Rollback()
+			log.Printf("Error saving attributes of item %d: %v", item.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update attributes"})
+			return
+		}
+	}
+	// Attributes are part of the item as shoppers see it
+	if err := catalog.TouchItems(tx, item.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update attributes"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update attributes"})
+		return
+	}
+	h.Cache.Invalidate()
+
+	attributes, err := loadAttributeValues(h.DB, []uint{item.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attributes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"item_id":    item.ID,
+		"attributes": append([]AttributeValueResponse{}, attributes[item.ID]...),
+	})
+}
+
+// Facets returns value counts of every attribute for the items matching the
+// filter[code]=value parameters, e.g. ?filter[brand]=Acme,Globex&filter[ram]=8..16.
+// Each facet is counted without its own filter, so the other values of a
+// filtered attribute still show how many items picking them would give.
+func (h *AttributeHandler) Facets(c *gin.Context) {
+	defs, filters, ok := attributeFilters(c, h.DB)
+	if !ok {
+		return
+	}
+
+	// Facets only count the items shoppers can see
+	items := catalog.Visible(h.DB, time.Now())
+	var total int
+	if err := catalog.ApplyAttributeFilters(items.Model(&models.Item{}), filters).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count items"})
+		return
+	}
+
+	facets, err := catalog.Facets(items, defs, filters)
+	if err != nil {
+		log.Printf("Error computing facets: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute facets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":  total,
+		"facets": facets,
+	})
+}
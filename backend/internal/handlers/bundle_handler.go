@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+)
+
+type BundleHandler struct {
+	DB *gorm.DB
+}
+
+func NewBundleHandler(db *gorm.DB) *BundleHandler {
+	return &BundleHandler{DB: db}
+}
+
+// BundleRequest defines a bundle. Components listing the same item twice
+// are merged.
+type BundleRequest struct {
+	SKU        string                   `json:"sku"`
+	Name       string                   `json:"name" binding:"required"`
+	Price      money.Money              `json:"price"`
+	Components []BundleComponentRequest `json:"components" binding:"required,min=1,dive"`
+}
+
+type BundleComponentRequest struct {
+	ItemID   uint `json:"item_id" binding:"required"`
+	Quantity int  `json:"quantity" binding:"gte=0"`
+}
+
+// BundleComponentResponse is a component with the state of its item.
+type BundleComponentResponse struct {
+	ItemID   uint        `json:"item_id"`
+	SKU      string      `json:"sku"`
+	Name     string      `json:"name"`
+	Quantity int         `json:"quantity"`
+	Price    money.Money `json:"price"`
+	Status   string      `json:"status"`
+	Stock    int         `json:"stock"`
+}
+
+// BundleResponse is a bundle with its availability, computed from its
+// components, and what it saves over buying them separately.
+type BundleResponse struct {
+	ID          uint                      `json:"id"`
+	SKU         string                    `json:"sku"`
+	Name        string                    `json:"name"`
+	Price       money.Money               `json:"price"`
+	ListPrice   money.Money               `json:"list_price"`
+	Savings     money.Money               `json:"savings"`
+	Status      string                    `json:"status"`
+	Stock       int                       `json:"stock"`
+	Purchasable bool                      `json:"purchasable"`
+	Components  []BundleComponentResponse `json:"components"`
+}
+
+// loadBundleItems returns the component items of the bundles keyed by ID.
+func loadBundleItems(db *gorm.DB, bundles ...models.Bundle) (map[uint]models.Item, error) {
+	var ids []uint
+	for _, b := range bundles {
+		for _, comp := range b.Components {
+			ids = append(ids, comp.ItemID)
+		}
+	}
+	byID := make(map[uint]models.Item, len(ids))
+	if len(ids) == 0 {
+		return byID, nil
+	}
+	var items []models.Item
+	if err := db.Where("id IN (?)", ids).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+	return byID, nil
+}
+
+// bundleListPrice is what the components of one bundle cost on their own.
+func bundleListPrice(b models.Bundle, items map[uint]models.Item) (money.Money, error) {
+	total := money.Zero(b.Price.Currency)
+	for _, comp := range b.Components {
+		price, err := items[comp.ItemID].Price.Mul(int64(comp.Quantity))
+		if err != nil {
+			return money.Money{}, err
+		}
+		if total, err = total.Add(price); err != nil {
+			return money.Money{}, err
+		}
+	}
+	return total, nil
+}
+
+func bundleResponse(b models.Bundle, items map[uint]models.Item) BundleResponse {
+	resp := BundleResponse{
+		ID:          b.ID,
+		SKU:         b.SKU,
+		Name:        b.Name,
+		Price:       b.Price,
+		Status:      b.Status(items),
+		Stock:       b.Stock(items),
+		Purchasable: b.Purchasable(1, items),
+		Components:  make([]BundleComponentResponse, 0, len(b.Components)),
+	}
+	// Savings are left out when a component is priced in another currency
+	if listPrice, err := bundleListPrice(b, items); err == nil {
+		resp.ListPrice = listPrice
+		resp.Savings = money.New(listPrice.Amount-b.Price.Amount, b.Price.Currency)
+	}
+	for _, comp := range b.Components {
+		item := items[comp.ItemID]
+		resp.Components = append(resp.Components, BundleComponentResponse{
+			ItemID:   comp.ItemID,
+			SKU:      item.SKU,
+			Name:     item.Name,
+			Quantity: comp.Quantity,
+			Price:    item.Price,
+			Status:   item.Status,
+			Stock:    availableStock(item),
+		})
+	}
+	return resp
+}
+
+// ListBundles returns all bundles with their availability.
+func (h *BundleHandler) ListBundles(c *gin.Context) {
+	var bundles []models.Bundle
+	if err := h.DB.Preload("Components", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Order("name").Find(&bundles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundles"})
+		return
+	}
+	items, err := loadBundleItems(h.DB, bundles...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundle items"})
+		return
+	}
+
+	response := make([]BundleResponse, 0, len(bundles))
+	for _, b := range bundles {
+		response = append(response, bundleResponse(b, items))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetBundle returns a bundle with its availability.
+func (h *BundleHandler) GetBundle(c *gin.Context) {
+	bundle, ok := h.loadBundle(c)
+	if !ok {
+		return
+	}
+	items, err := loadBundleItems(h.DB, bundle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundle items"})
+		return
+	}
+	c.JSON(http.StatusOK, bundleResponse(bundle, items))
+}
+
+// loadBundle returns the bundle from the :id parameter with its components.
+// On failure it writes the response and returns false.
+func (h *BundleHandler) loadBundle(c *gin.Context) (models.Bundle, bool) {
+	var bundle models.Bundle
+	if err := h.DB.Preload("Components", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&bundle, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bundle not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundle"})
+		}
+		return bundle, false
+	}
+	return bundle, true
+}
+
+// bindBundle validates a bundle request and returns the bundle it describes.
+// On failure it writes the response and returns false.
+func (h *BundleHandler) bindBundle(c *gin.Context) (models.Bundle, bool) {
+	var req BundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return models.Bundle{}, false
+	}
+	if req.Price.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "price must be greater than 0"})
+		return models.Bundle{}, false
+	}
+
+	bundle := models.Bundle{
+		SKU:   strings.TrimSpace(req.SKU),
+		Name:  strings.TrimSpace(req.Name),
+		Price: req.Price,
+	}
+	position := make(map[uint]int)
+	for _, comp := range req.Components {
+		if comp.Quantity == 0 {
+			comp.Quantity = 1
+		}
+		if i, ok := position[comp.ItemID]; ok {
+			bundle.Components[i].Quantity += comp.Quantity
+			continue
+		}
+		position[comp.ItemID] = len(bundle.Components)
+		bundle.Components = append(bundle.Components, models.BundleComponent{ItemID: comp.ItemID, Quantity: comp.Quantity})
+	}
+
+	items, err := loadBundleItems(h.DB, bundle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundle items"})
+		return models.Bundle{}, false
+	}
+	for _, comp := range bundle.Components {
+		item, ok := items[comp.ItemID]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Item not found", "item_id": comp.ItemID})
+			return models.Bundle{}, false
+		}
+		if item.Price.Currency != bundle.Price.Currency {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Components must be priced in the bundle's currency",
+				"item_id": item.ID,
+			})
+			return models.Bundle{}, false
+		}
+	}
+	if listPrice, _ := bundleListPrice(bundle, items); bundle.Price.Amount > listPrice.Amount {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "Bundle price must not exceed the price of its components",
+			"list_price": listPrice,
+		})
+		return models.Bundle{}, false
+	}
+	return bundle, true
+}
+
+// CreateBundle defines a bundle from existing items. Bundles created
+// without a SKU get a generated one.
+func (h *BundleHandler) CreateBundle(c *gin.Context) {
+	bundle, ok := h.bindBundle(c)
+	if !ok {
+		return
+	}
+	if bundle.SKU == "" {
+		suffix, err := randomHex(4)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate SKU"})
+			return
+		}
+		bundle.SKU = "BNDL-" + strings.ToUpper(suffix)
+	}
+
+	// Components are saved by gorm along with the bundle
+	if err := h.DB.Create(&bundle).Error; err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A bundle with this SKU or name already exists"})
+			return
+		}
+		log.Printf("Error creating bundle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create bundle"})
+		return
+	}
+
+	items, err := loadBundleItems(h.DB, bundle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundle items"})
+		return
+	}
+	c.JSON(http.StatusCreated, bundleResponse(bundle, items))
+}
+
+// UpdateBundle replaces the name, price and components of a bundle. Carts
+// holding the bundle get the new contents and price.
+func (h *BundleHandler) UpdateBundle(c *gin.Context) {
+	bundle, ok := h.loadBundle(c)
+	if !ok {
+		return
+	}
+	update, ok := h.bindBundle(c)
+	if !ok {
+		return
+	}
+	if update.SKU == "" {
+		update.SKU = bundle.SKU
+	}
+
+	tx := h.DB.Begin()
+	if err := tx.Model(&bundle).Updates(map[string]interface{}{
+		"sku":            update.SKU,
+		"name":           update.Name,
+		"price_amount":   update.Price.Amount,
+		"price_currency": update.Price.Currency,
+	}).Error; err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A bundle with this SKU or name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+		return
+	}
+	if err := tx.Where("bundle_id = ?", bundle.ID).Delete(&models.BundleComponent{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+		return
+	}
+	for i := range update.Components {
+		update.Components[i].BundleID = bundle.ID
+		if err := tx.Create(&update.Components[i]).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+		return
+	}
+
+	bundle.Components = update.Components
+	items, err := loadBundleItems(h.DB, bundle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundle items"})
+		return
+	}
+	c.JSON(http.StatusOK, bundleResponse(bundle, items))
+}
+
+// DeleteBundle removes a bundle and takes it out of every cart. Orders keep
+// their component lines.
+func (h *BundleHandler) DeleteBundle(c *gin.Context) {
+	bundle, ok := h.loadBundle(c)
+	if !ok {
+		return
+	}
+
+	tx := h.DB.Begin()
+	if err := tx.Where("bundle_id = ?", bundle.ID).Delete(&models.CartBundle{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bundle"})
+		return
+	}
+	if err := tx.Where("bundle_id = ?", bundle.ID).Delete(&models.BundleComponent{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bundle"})
+		return
+	}
+	if err := tx.Delete(&bundle).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bundle"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bundle"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Bundle deleted"})
+}
+
+// expandBundle returns the order lines for quantity units of a bundle: one
+// per component at its current price, with the difference between the list
+// price and the bundle price spread over them in proportion to their list
+// totals. The last line takes the rounding remainder, so the lines add up
+// to exactly quantity times the bundle price.
+func expandBundle(bundle models.Bundle, items map[uint]models.Item, quantity int) ([]models.OrderItem, error) {
+	listPrice, err := bundleListPrice(bundle, items)
+	if err != nil {
+		return nil, err
+	}
+	listTotal := listPrice.Amount * int64(quantity)
+	discount := listTotal - bundle.Price.Amount*int64(quantity)
+
+	lines := make([]models.OrderItem, 0, len(bundle.Components))
+	var allocated int64
+	for i, comp := range bundle.Components {
+		item := items[comp.ItemID]
+		units := comp.Quantity * quantity
+		share := discount - allocated
+		if i < len(bundle.Components)-1 && listTotal > 0 {
+			share = discount * item.Price.Amount * int64(units) / listTotal
+		}
+		allocated += share
+
+		bundleID := bundle.ID
+		lines = append(lines, models.OrderItem{
+			ItemID:    item.ID,
+			BundleID:  &bundleID,
+			Quantity:  units,
+			UnitPrice: item.Price,
+			Discount:  money.New(share, bundle.Price.Currency),
+		})
+	}
+	return lines, nil
+}
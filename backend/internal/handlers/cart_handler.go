@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -13,10 +14,17 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+	"ecommerce-app/internal/pricing"
+	"ecommerce-app/internal/signing"
 )
 
 type CartHandler struct {
 	DB *gorm.DB
+	// Signer verifies the restore links of cart reminders
+	Signer *signing.Signer
+	// Pricing computes cart totals; it must match the one of OrderHandler
+	Pricing *pricing.Engine
 }
 
 // generateSessionID creates a new random session ID
@@ -30,11 +38,14 @@ func generateSessionID() string {
 }
 
 func NewCartHandler(db *gorm.DB) *CartHandler {
-	return &CartHandler{DB: db}
+	return &CartHandler{DB: db, Pricing: pricing.NewEngine(pricing.CouponDiscount{})}
 }
 
+// AddToCartRequest adds either an item or a bundle. Quantity defaults to 1.
 type AddToCartRequest struct {
-	ItemID uint `json:"item_id" binding:"required"`
+	ItemID   uint `json:"item_id"`
+	BundleID uint `json:"bundle_id"`
+	Quantity int  `json:"quantity" binding:"gte=0"`
 	// Note: The JSON tag must match exactly what's sent from the frontend (snake_case)
 }
 
@@ -90,9 +101,16 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
+	if (input.ItemID == 0) == (input.BundleID == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of item_id and bundle_id is required"})
+		return
+	}
+	if input.Quantity == 0 {
+		input.Quantity = 1
+	}
 
-	log.Printf("AddToCart request - UserID: %v, SessionID: %s, ItemID: %d", 
-		userID, sessionID, input.ItemID)
+	log.Printf("AddToCart request - UserID: %v, SessionID: %s, ItemID: %d, BundleID: %d, Quantity: %d", 
+		userID, sessionID, input.ItemID, input.BundleID, input.Quantity)
 
 	// Start transaction
 	tx := h.DB.Begin()
@@ -112,163 +130,31 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 	}()
 
 	// Get or create cart
-	var cart models.Cart
-	var cartErr error
-
-	if isAuthenticated && userIDPtr != nil {
-		// Handle authenticated user cart
-		cartErr = tx.Where("user_id = ? AND status = ?", userID, "active").First(&cart).Error
-
-		if cartErr != nil {
-			if cartErr == gorm.ErrRecordNotFound {
-				// Create new cart for authenticated user
-				cart = models.Cart{
-					UserID:    userIDPtr,
-					SessionID: "",
-					Status:    "active",
-				}
-				if err := tx.Create(&cart).Error; err != nil {
-					tx.Rollback()
-					log.Printf("Error creating user cart: %v", err)
-					c.JSON(http.StatusInternalServerError, gin.H{
-						"error":   "Failed to create cart",
-						"details": err.Error(),
-					})
-					return
-				}
-				log.Printf("Created new cart ID: %d for user ID: %d", cart.ID, userID)
-			} else {
-				tx.Rollback()
-				log.Printf("Error finding user's cart: %v", cartErr)
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":   "Failed to find cart",
-					"details": cartErr.Error(),
-				})
-				return
-			}
-		} else {
-			log.Printf("Using existing cart ID: %d for user ID: %d", cart.ID, userID)
-		}
-	} else if sessionID != "" {
-		// Handle unauthenticated user with session
-		cartErr = tx.Where("session_id = ? AND status = ?", sessionID, "active").First(&cart).Error
-
-		if cartErr != nil {
-			if cartErr == gorm.ErrRecordNotFound {
-				// Create new cart for unauthenticated user
-				cart = models.Cart{
-					SessionID: sessionID,
-					Status:    "active",
-				}
-				if err := tx.Create(&cart).Error; err != nil {
-					tx.Rollback()
-					log.Printf("Error creating session cart: %v", err)
-					c.JSON(http.StatusInternalServerError, gin.H{
-						"error":   "Failed to create cart",
-						"details": err.Error(),
-					})
-					return
-				}
-				log.Printf("Created new cart ID: %d for session ID: %s", cart.ID, sessionID)
-			} else {
-				tx.Rollback()
-				log.Printf("Error finding session cart: %v", cartErr)
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":   "Failed to find cart",
-					"details": cartErr.Error(),
-				})
-				return
-			}
-		} else {
-			log.Printf("Using existing cart ID: %d for session ID: %s", cart.ID, sessionID)
-		}
-	} else {
-		tx.Rollback()
-		log.Println("Neither user ID nor session ID provided")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Authentication or session ID required"})
-		return
-	}
-
-	// Check if item exists and is available
-	var item models.Item
-	if err := tx.First(&item, input.ItemID).Error; err != nil {
+	cart, err := activeCart(tx, userIDPtr, sessionID)
+	if err != nil {
 		tx.Rollback()
-		if err == gorm.ErrRecordNotFound {
-			log.Printf("Item with ID %d not found", input.ItemID)
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "Item not found",
-			})
-		} else {
-			log.Printf("Error checking item: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Error checking item",
-				"details": err.Error(),
-			})
+		if err == errNoCartOwner {
+			log.Println("Neither user ID nor session ID provided")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Authentication or session ID required"})
+			return
 		}
-		return
-	}
-
-	// Check if item is available
-	if item.Status != "available" {
-		tx.Rollback()
-		log.Printf("Item with ID %d is not available for purchase. Status: %s", item.ID, item.Status)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Item is not available for purchase",
-			"status": item.Status,
+		log.Printf("Error finding or creating cart: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to find cart",
+			"details": err.Error(),
 		})
 		return
 	}
 
-	// Add item to cart or update quantity if already exists
-	var cartItem models.CartItem
-	itemErr := tx.
-		Where("cart_id = ? AND item_id = ?", cart.ID, input.ItemID).
-		First(&cartItem).Error
-
-	if itemErr != nil {
-		if itemErr == gorm.ErrRecordNotFound {
-			// Create new cart item
-			log.Printf("Creating new cart item - CartID: %d, ItemID: %d", cart.ID, input.ItemID)
-			cartItem = models.CartItem{
-				CartID:   cart.ID,
-				ItemID:   input.ItemID,
-				Quantity: 1,
-			}
-			if err := tx.Create(&cartItem).Error; err != nil {
-				tx.Rollback()
-				log.Printf("Error creating cart item: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": "Failed to add item to cart",
-					"details": err.Error(),
-				})
-				return
-			}
-			log.Printf("Added new item %d to cart %d", input.ItemID, cart.ID)
-		} else {
-			tx.Rollback()
-			log.Printf("Error checking cart items: %v", itemErr)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to process cart items",
-				"details": itemErr.Error(),
-			})
-			return
-		}
+	if input.BundleID != 0 {
+		err = addBundleToCart(tx, cart.ID, input.BundleID, input.Quantity)
 	} else {
-		// Update quantity
-		cartItem.Quantity++
-		if err := tx.Model(&cartItem).
-			Update("quantity", cartItem.Quantity).
-			Error; err != nil {
-			tx.Rollback()
-			log.Printf("Error updating cart item quantity: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to update cart",
-				"details": err.Error(),
-			})
-			return
-		}
-		log.Printf("Updated quantity for item %d in cart %d to %d", 
-			input.ItemID, cart.ID, cartItem.Quantity)
+		err = addItemToCart(tx, cart.ID, input.ItemID, input.Quantity)
+	}
+	if err != nil {
+		tx.Rollback()
+		respondCartError(c, err)
+		return
 	}
 
 	// Commit transaction
@@ -284,7 +170,7 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 
 	// Get updated cart with items to return
 	var updatedCart models.Cart
-	if err := h.DB.Preload("Items").First(&updatedCart, cart.ID).Error; err != nil {
+	if err := h.DB.Preload("Items").Preload("Bundles").First(&updatedCart, cart.ID).Error; err != nil {
 		log.Printf("Error fetching updated cart: %v", err)
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Item added to cart, but could not fetch updated cart",
@@ -301,6 +187,369 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 	})
 }
 
+// errNoCartOwner is returned by activeCart when the request has neither a
+// user nor a session to own a cart.
+var errNoCartOwner = errors.New("authentication or session ID required")
+
+// cartError is a cart change that was rejected because of the item, along
+// with the response explaining why.
+type cartError struct {
+	status int
+	body   gin.H
+}
+
+func (e *cartError) Error() string {
+	return fmt.Sprintf("%v", e.body["error"])
+}
+
+// respondCartError writes the response for an error from addItemToCart.
+func respondCartError(c *gin.Context, err error) {
+	if ce, ok := err.(*cartError); ok {
+		c.JSON(ce.status, ce.body)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   "Failed to update cart",
+		"details": err.Error(),
+	})
+}
+
+// activeCart returns the active cart of the user, or of the session when
+// there is no user, creating it when there is none yet. Each owner has at
+// most one active cart (see EnsureCartIndexes), so concurrent requests
+// creating it insert one row and the others find it.
+func activeCart(tx *gorm.DB, userID *uint, sessionID string) (models.Cart, error) {
+	var res *gorm.DB
+	now := time.Now()
+	switch {
+	case userID != nil:
+		res = tx.Exec(`INSERT INTO carts (user_id, session_id, status, created_at, updated_at)
+			VALUES (?, '', 'active', ?, ?) ON CONFLICT DO NOTHING`, *userID, now, now)
+	case sessionID != "":
+		res = tx.Exec(`INSERT INTO carts (user_id, session_id, status, created_at, updated_at)
+			VALUES (NULL, ?, 'active', ?, ?) ON CONFLICT DO NOTHING`, sessionID, now, now)
+	default:
+		return models.Cart{}, errNoCartOwner
+	}
+	if res.Error != nil {
+		return models.Cart{}, res.Error
+	}
+
+	cart, err := findActiveCart(tx, userID, sessionID)
+	if err != nil {
+		return cart, err
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("Created new cart ID: %d (user: %v, session: %s)", cart.ID, userID != nil, sessionID)
+	} else {
+		log.Printf("Using existing cart ID: %d (user: %v, session: %s)", cart.ID, userID != nil, sessionID)
+	}
+	return cart, nil
+}
+
+// findActiveCart returns the active cart of the user, or of the session when
+// there is no user, without creating one. It returns gorm.ErrRecordNotFound
+// when there is none.
+func findActiveCart(tx *gorm.DB, userID *uint, sessionID string) (models.Cart, error) {
+	var cart models.Cart
+	switch {
+	case userID != nil:
+		return cart, tx.Where("user_id = ? AND status = ?", *userID, "active").First(&cart).Error
+	case sessionID != "":
+		return cart, tx.Where("user_id IS NULL AND session_id = ? AND status = ?", sessionID, "active").First(&cart).Error
+	}
+	return cart, errNoCartOwner
+}
+
+// cartIndexes make carts and their lines unique, which the upserts in
+// activeCart, addItemToCart and addBundleToCart rely on. Partial indexes
+// cannot be declared in gorm tags.
+var cartIndexes = []string{
+	// Only the cart in use is kept active when an owner has several
+	`UPDATE carts SET status = 'superseded' WHERE status = 'active' AND user_id IS NOT NULL
+		AND id NOT IN (SELECT MIN(id) FROM carts WHERE status = 'active' AND user_id IS NOT NULL GROUP BY user_id)`,
+	`UPDATE carts SET status = 'superseded' WHERE status = 'active' AND user_id IS NULL
+		AND id NOT IN (SELECT MIN(id) FROM carts WHERE status = 'active' AND user_id IS NULL GROUP BY session_id)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_carts_active_user ON carts(user_id)
+		WHERE status = 'active' AND user_id IS NOT NULL`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_carts_active_session ON carts(session_id)
+		WHERE status = 'active' AND user_id IS NULL`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_items_cart_item ON cart_items(cart_id, item_id)`,
+}
+
+// EnsureCartIndexes creates the unique indexes on carts and cart lines,
+// first retiring duplicate active carts that would violate them. It is
+// safe to run on every start.
+func EnsureCartIndexes(db *gorm.DB) error {
+	for _, stmt := range cartIndexes {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+
+// addItemToCart puts quantity units of the item in the cart, after checking
+// that the item exists, is for sale, has the stock for the new quantity and
+// allows it under its purchase limits. Rejections are returned as
+// *cartError.
+func addItemToCart(tx *gorm.DB, cartID, itemID uint, quantity int) error {
+	item, err := loadSellableItem(tx, itemID)
+	if err != nil {
+		return err
+	}
+
+	// One statement adds the line or bumps its quantity, so concurrent adds
+	// neither lose an increment nor collide on (cart_id, item_id). A new
+	// line snapshots the current price; adding to an existing line keeps its
+	// snapshot, so a price change still has to be acknowledged at checkout
+	now := time.Now()
+	if err := tx.Exec(`INSERT INTO cart_items (cart_id, item_id, quantity, unit_price_amount, unit_price_currency, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (cart_id, item_id) DO UPDATE
+		SET quantity = cart_items.quantity + excluded.quantity,
+			updated_at = excluded.updated_at`,
+		cartID, itemID, quantity, item.Price.Amount, item.Price.Currency, now, now).Error; err != nil {
+		log.Printf("Error adding item %d to cart %d: %v", itemID, cartID, err)
+		return err
+	}
+
+	// The checks run on the quantity the upsert produced; the caller rolls
+	// back when they fail
+	var line models.CartItem
+	if err := tx.Where("cart_id = ? AND item_id = ?", cartID, itemID).First(&line).Error; err != nil {
+		return err
+	}
+	if !item.Purchasable(line.Quantity) {
+		return insufficientStockError(item, line.Quantity)
+	}
+	if err := checkQuantityLimits(tx, cartID, item, line.Quantity); err != nil {
+		return err
+	}
+	log.Printf("Quantity for item %d in cart %d is now %d", itemID, cartID, line.Quantity)
+	return nil
+}
+
+// loadSellableItem returns the item if shoppers can see it and it is for
+// sale. Rejections are returned as *cartError.
+func loadSellableItem(tx *gorm.DB, itemID uint) (models.Item, error) {
+	// Check if item exists and is available
+	var item models.Item
+	if err := tx.First(&item, itemID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			log.Printf("Item with ID %d not found", itemID)
+			return item, &cartError{status: http.StatusNotFound, body: gin.H{"error": "Item not found"}}
+		}
+		log.Printf("Error checking item: %v", err)
+		return item, err
+	}
+	// Drafts and items outside their publish window do not exist for shoppers
+	if !item.Visible(time.Now()) {
+		log.Printf("Item with ID %d is not visible", itemID)
+		return item, &cartError{status: http.StatusNotFound, body: gin.H{"error": "Item not found"}}
+	}
+
+	if item.Status != models.ItemStatusAvailable && !item.Purchasable(1) {
+		log.Printf("Item with ID %d is not available for purchase. Status: %s", item.ID, item.Status)
+		return item, &cartError{status: http.StatusBadRequest, body: gin.H{
+			"error":     "Item is not available for purchase",
+			"status":    item.Status,
+			"alert_url": alertURL(item.ID),
+		}}
+	}
+	return item, nil
+}
+
+// addBundleToCart adds quantity units of a bundle to the cart inside tx.
+// The bundle is one cart line; every component must have enough stock for
+// all units of the bundle in the cart, and the components' max_per_order
+// counts the units in bundles too. Rejections are returned as *cartError.
+func addBundleToCart(tx *gorm.DB, cartID, bundleID uint, quantity int) error {
+	bundle, items, err := loadSellableBundle(tx, bundleID)
+	if err != nil {
+		return err
+	}
+
+	// Upserted like item lines in addItemToCart
+	now := time.Now()
+	if err := tx.Exec(`INSERT INTO cart_bundles (cart_id, bundle_id, quantity, unit_price_amount, unit_price_currency, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (cart_id, bundle_id) DO UPDATE
+		SET quantity = cart_bundles.quantity + excluded.quantity,
+			updated_at = excluded.updated_at`,
+		cartID, bundleID, quantity, bundle.Price.Amount, bundle.Price.Currency, now, now).Error; err != nil {
+		return err
+	}
+
+	var line models.CartBundle
+	if err := tx.Where("cart_id = ? AND bundle_id = ?", cartID, bundleID).First(&line).Error; err != nil {
+		return err
+	}
+	if err := checkBundleQuantity(bundle, items, line.Quantity); err != nil {
+		return err
+	}
+	return checkBundleLimits(tx, cartID, items)
+}
+
+// loadSellableBundle returns the bundle and its component items if shoppers
+// can see all of them. Rejections are returned as *cartError.
+func loadSellableBundle(tx *gorm.DB, bundleID uint) (models.Bundle, map[uint]models.Item, error) {
+	var bundle models.Bundle
+	if err := tx.Preload("Components").First(&bundle, bundleID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return bundle, nil, &cartError{status: http.StatusNotFound, body: gin.H{"error": "Bundle not found"}}
+		}
+		return bundle, nil, err
+	}
+	items, err := loadBundleItems(tx, bundle)
+	if err != nil {
+		return bundle, nil, err
+	}
+	now := time.Now()
+	for _, item := range items {
+		if !item.Visible(now) {
+			log.Printf("Bundle %d contains item %d, which is not visible", bundle.ID, item.ID)
+			return bundle, nil, &cartError{status: http.StatusConflict, body: gin.H{
+				"error":     "Bundle is not available",
+				"bundle_id": bundle.ID,
+			}}
+		}
+	}
+	return bundle, items, nil
+}
+
+// checkBundleQuantity rejects quantity units of a bundle unless every
+// component has the stock for them.
+func checkBundleQuantity(bundle models.Bundle, items map[uint]models.Item, quantity int) error {
+	if bundle.Purchasable(quantity, items) {
+		return nil
+	}
+	log.Printf("Bundle %d is not available in quantity %d", bundle.ID, quantity)
+	return &cartError{status: http.StatusConflict, body: gin.H{
+		"error":     "Bundle is not available in this quantity",
+		"bundle_id": bundle.ID,
+		"items":     bundleShortages(bundle, items, quantity),
+	}}
+}
+
+// bundleShortages lists the components that keep quantity units of a bundle
+// from being bought.
+func bundleShortages(bundle models.Bundle, items map[uint]models.Item, quantity int) []stockShortage {
+	var shortages []stockShortage
+	for _, comp := range bundle.Components {
+		item, ok := items[comp.ItemID]
+		requested := comp.Quantity * quantity
+		if ok && item.Purchasable(requested) {
+			continue
+		}
+		shortages = append(shortages, stockShortage{
+			ItemID:    comp.ItemID,
+			Name:      item.Name,
+			Requested: requested,
+			Available: availableStock(item),
+		})
+	}
+	return shortages
+}
+
+// quantityLimitViolation is a purchase limit of an item that a cart change
+// would break. Limit is "max_per_order" or "min_order_quantity".
+type quantityLimitViolation struct {
+	ItemID    uint   `json:"item_id"`
+	Name      string `json:"name"`
+	Limit     string `json:"limit"`
+	Value     int    `json:"value"`
+	Requested int    `json:"requested"`
+	Message   string `json:"message"`
+}
+
+// quantityLimitError reports that the cart cannot hold requested units of
+// item because of one of its purchase limits.
+func quantityLimitError(item models.Item, limit string, value, requested int) *cartError {
+	message := fmt.Sprintf("%s can be bought at most %d per order", item.Name, value)
+	if limit == "min_order_quantity" {
+		message = fmt.Sprintf("%s is sold in quantities of at least %d", item.Name, value)
+	}
+	log.Printf("Quantity %d of item %d breaks its %s of %d", requested, item.ID, limit, value)
+	return &cartError{status: http.StatusBadRequest, body: gin.H{
+		"error": "Quantity is outside the purchase limits of this item",
+		"items": []quantityLimitViolation{{
+			ItemID:    item.ID,
+			Name:      item.Name,
+			Limit:     limit,
+			Value:     value,
+			Requested: requested,
+			Message:   message,
+		}},
+	}}
+}
+
+// itemUnits returns how many units of an item the cart holds, on its own
+// line and inside bundles.
+func itemUnits(tx *gorm.DB, cartID, itemID uint) (int, error) {
+	var units int
+	err := tx.Raw(`SELECT COALESCE((SELECT quantity FROM cart_items WHERE cart_id = ? AND item_id = ?), 0)
+		+ COALESCE((SELECT SUM(cb.quantity * bc.quantity) FROM cart_bundles cb
+			JOIN bundle_components bc ON bc.bundle_id = cb.bundle_id
+			WHERE cb.cart_id = ? AND bc.item_id = ?), 0)`,
+		cartID, itemID, cartID, itemID).Row().Scan(&units)
+	return units, err
+}
+
+// checkQuantityLimits checks the purchase limits of item for a line of
+// quantity units. The minimum applies to the line; the maximum to every
+// unit in the cart, including those in bundles.
+func checkQuantityLimits(tx *gorm.DB, cartID uint, item models.Item, quantity int) error {
+	if item.MinOrderQuantity > 0 && quantity < item.MinOrderQuantity {
+		return quantityLimitError(item, "min_order_quantity", item.MinOrderQuantity, quantity)
+	}
+	if item.MaxPerOrder == 0 {
+		return nil
+	}
+	units, err := itemUnits(tx, cartID, item.ID)
+	if err != nil {
+		return err
+	}
+	if units > item.MaxPerOrder {
+		return quantityLimitError(item, "max_per_order", item.MaxPerOrder, units)
+	}
+	return nil
+}
+
+// checkBundleLimits checks max_per_order of the components of a bundle
+// after its quantity in the cart went up.
+func checkBundleLimits(tx *gorm.DB, cartID uint, items map[uint]models.Item) error {
+	for _, item := range items {
+		if item.MaxPerOrder == 0 {
+			continue
+		}
+		units, err := itemUnits(tx, cartID, item.ID)
+		if err != nil {
+			return err
+		}
+		if units > item.MaxPerOrder {
+			return quantityLimitError(item, "max_per_order", item.MaxPerOrder, units)
+		}
+	}
+	return nil
+}
+
+// insufficientStockError reports that the cart cannot hold requested units of item.
+func insufficientStockError(item models.Item, requested int) *cartError {
+	log.Printf("Not enough stock for item %d: requested %d, available %d", item.ID, requested, item.Stock)
+	return &cartError{status: http.StatusConflict, body: gin.H{
+		"error": "Not enough stock for this item",
+		"items": []stockShortage{{
+			ItemID:    item.ID,
+			Name:      item.Name,
+			Requested: requested,
+			Available: availableStock(item),
+		}},
+		"alert_url": alertURL(item.ID),
+	}}
+}
+
 func (h *CartHandler) GetCart(c *gin.Context) {
 	// Get user ID from context if authenticated
 	userIDVal, isAuthenticated := c.Get("userID")
@@ -473,26 +722,36 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 		log.Printf("Found %d cart items with no matching item in items table: %+v", len(missingItems), missingItems)
 	}
 
+	h.renderCart(c, tx, cart)
+}
+
+// renderCart writes the contents of cart with its price breakdown, as
+// returned by GetCart and by every endpoint that changes the cart.
+func (h *CartHandler) renderCart(c *gin.Context, tx *gorm.DB, cart models.Cart) {
 	// Get cart items with item details
 	type CartItemWithDetails struct {
-		ID        uint    `gorm:"column:id"`
-		CartID    uint    `gorm:"column:cart_id"`
-		ItemID    uint    `gorm:"column:item_id"`
-		Quantity  int     `gorm:"column:quantity"`
-		Name      string  `gorm:"column:name"`
-		Price     float64 `gorm:"column:price"`
+		ID            uint   `gorm:"column:id"`
+		CartID        uint   `gorm:"column:cart_id"`
+		ItemID        uint   `gorm:"column:item_id"`
+		Quantity      int    `gorm:"column:quantity"`
+		Name          string `gorm:"column:name"`
+		PriceAmount   int64  `gorm:"column:price_amount"`
+		PriceCurrency string `gorm:"column:price_currency"`
+		Digital       bool   `gorm:"column:digital"`
+		// Price when the item was added
+		UnitPriceAmount   int64  `gorm:"column:unit_price_amount"`
+		UnitPriceCurrency string `gorm:"column:unit_price_currency"`
 	}
 
 	var cartItems []CartItemWithDetails
-	err = tx.Raw(`
-		SELECT ci.*, i.name, i.price
+	err := tx.Raw(`
+		SELECT ci.*, i.name, i.price_amount, i.price_currency, i.digital
 		FROM cart_items ci
 		INNER JOIN items i ON i.id = ci.item_id
 		WHERE ci.cart_id = ?
 	`, cart.ID).Scan(&cartItems).Error
 
 	if err != nil {
-		tx.Rollback()
 		log.Printf("Error in raw SQL query: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to fetch cart items",
@@ -503,7 +762,17 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 
 	log.Printf("Cart items with details: %+v", cartItems)
 
-	if len(cartItems) == 0 {
+	var cartBundles []models.CartBundle
+	if err := tx.Where("cart_id = ?", cart.ID).Order("id").Find(&cartBundles).Error; err != nil {
+		log.Printf("Error fetching cart bundles: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch cart items",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(cartItems) == 0 && len(cartBundles) == 0 {
 		log.Printf("No items found in cart %d", cart.ID)
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Cart is empty",
@@ -512,23 +781,95 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 		return
 	}
 
-	// Convert to the expected format
-	items := make([]map[string]interface{}, 0, len(cartItems))
-	total := 0.0
+	// Convert to the expected format. Lines priced differently than when
+	// they were added are flagged and listed, as checkout asks to confirm
+	// them
+	items := make([]map[string]interface{}, 0, len(cartItems)+len(cartBundles))
+	lines := make([]pricing.Line, 0, len(cartItems)+len(cartBundles))
+	changes := []priceChange{}
 
 	for _, item := range cartItems {
-		total += float64(item.Quantity) * item.Price
+		price := money.New(item.PriceAmount, item.PriceCurrency)
+		lines = append(lines, itemPricingLine(item.ItemID, item.Name, price, item.Quantity, item.Digital))
+		added := money.New(item.UnitPriceAmount, item.UnitPriceCurrency)
+		changed := priceChanged(added, price)
+		if changed {
+			changes = append(changes, priceChange{ItemID: item.ItemID, Name: item.Name, AddedPrice: added, Price: price})
+		}
 		items = append(items, map[string]interface{}{
-			"id":       item.ItemID,
-			"name":     item.Name,
-			"price":    item.Price,
-			"quantity": item.Quantity,
+			"id":            item.ItemID,
+			"name":          item.Name,
+			"price":         price,
+			"added_price":   added,
+			"price_changed": changed,
+			"quantity":      item.Quantity,
 		})
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"cart_id": cart.ID,
-		"items":   items,
-		"total":   total,
-	})
+	// Bundles are one line each, listing what they contain
+	for _, line := range cartBundles {
+		var bundle models.Bundle
+		if err := tx.Preload("Components").First(&bundle, line.BundleID).Error; err != nil {
+			log.Printf("Error fetching bundle %d: %v", line.BundleID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart items"})
+			return
+		}
+		bundleItems, err := loadBundleItems(tx, bundle)
+		if err != nil {
+			log.Printf("Error fetching items of bundle %d: %v", bundle.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart items"})
+			return
+		}
+		lines = append(lines, bundlePricingLine(bundle, bundleItems, line.Quantity))
+		components := make([]map[string]interface{}, 0, len(bundle.Components))
+		for _, comp := range bundle.Components {
+			components = append(components, map[string]interface{}{
+				"item_id":  comp.ItemID,
+				"name":     bundleItems[comp.ItemID].Name,
+				"quantity": comp.Quantity,
+			})
+		}
+		changed := priceChanged(line.UnitPrice, bundle.Price)
+		if changed {
+			changes = append(changes, priceChange{BundleID: bundle.ID, Name: bundle.Name, AddedPrice: line.UnitPrice, Price: bundle.Price})
+		}
+		items = append(items, map[string]interface{}{
+			"bundle_id":     bundle.ID,
+			"name":          bundle.Name,
+			"price":         bundle.Price,
+			"added_price":   line.UnitPrice,
+			"price_changed": changed,
+			"quantity":      line.Quantity,
+			"purchasable":   bundle.Purchasable(line.Quantity, bundleItems),
+			"components":    components,
+		})
+	}
+
+	_, coupon, err := cartCoupon(tx, cart, lines, time.Now())
+	if err != nil {
+		log.Printf("Error fetching coupon of cart %d: %v", cart.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupon"})
+		return
+	}
+	breakdown, err := h.Pricing.Price(c.Request.Context(), pricing.Cart{ID: cart.ID, UserID: cart.UserID, Lines: lines, Coupon: coupon})
+	if err != nil {
+		log.Printf("Failed to price cart %d: %v", cart.ID, err)
+		if err == pricing.ErrMixedCurrencies {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cart contains items in different currencies"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price cart", "details": err.Error()})
+		}
+		return
+	}
+	// Lines are priced in the order they are listed
+	for i, line := range breakdown.Lines {
+		items[i]["discount"] = line.Discount
+		items[i]["line_total"] = line.Total
+	}
+
+	response := breakdownResponse(breakdown)
+	response["cart_id"] = cart.ID
+	response["items"] = items
+	response["price_changes"] = changes
+	c.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	_ "modernc.org/sqlite"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+)
+
+// newCartTestDB opens a file database, so that concurrent transactions
+// really run on separate connections, with the cart schema and indexes and
+// the tables of extra.
+func newCartTestDB(t *testing.T, extra ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "cart.db") + "?_pragma=busy_timeout(10000)"
+	sqlDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// The sqlite3 dialect makes AutoMigrate declare INTEGER PRIMARY KEY ids
+	db, err := gorm.Open("sqlite3", sqlDB)
+	if err != nil {
+		t.Fatalf("open gorm: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	tables := append([]interface{}{&models.Item{}, &models.Cart{}, &models.CartItem{},
+		&models.Bundle{}, &models.BundleComponent{}, &models.CartBundle{}}, extra...)
+	if err := db.AutoMigrate(tables...).Error; err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := EnsureCartIndexes(db); err != nil {
+		t.Fatalf("cart indexes: %v", err)
+	}
+	return db
+}
+
+func newCartTestRouter(h *CartHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/carts", func(c *gin.Context) {
+		var userID uint
+		if _, err := fmt.Sscan(c.GetHeader("X-Test-User"), &userID); err == nil {
+			c.Set("userID", userID)
+		}
+		h.AddToCart(c)
+	})
+	return r
+}
+
+// addConcurrently posts body to the cart endpoint from n goroutines at once
+// and returns the status codes.
+func addConcurrently(r http.Handler, n int, body gin.H, header map[string]string) []int {
+	payload, _ := json.Marshal(body)
+	codes := make([]int, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/carts", bytes.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			for k, v := range header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			<-start
+			r.ServeHTTP(w, req)
+			codes[i] = w.Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return codes
+}
+
+func TestAddToCartConcurrent(t *testing.T) {
+	const n = 50
+	db := newCartTestDB(t)
+	item := models.Item{SKU: "SKU-1", Name: "Widget", Price: money.New(1000, "USD"),
+		Status: models.ItemStatusAvailable, Stock: 1000}
+	if err := db.Create(&item).Error; err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	r := newCartTestRouter(NewCartHandler(db))
+
+	owners := map[string]map[string]string{
+		"user":    {"X-Test-User": "7"},
+		"session": {"X-Session-ID": "sess_concurrent"},
+	}
+	for name, header := range owners {
+		t.Run(name, func(t *testing.T) {
+			codes := addConcurrently(r, n, gin.H{"item_id": item.ID}, header)
+			for i, code := range codes {
+				if code != http.StatusOK {
+					t.Errorf("request %d: status %d, want 200", i, code)
+				}
+			}
+
+			var carts []models.Cart
+			query := db.Where("status = ?", "active")
+			if name == "user" {
+				query = query.Where("user_id = ?", 7)
+			} else {
+				query = query.Where("user_id IS NULL AND session_id = ?", "sess_concurrent")
+			}
+			if err := query.Find(&carts).Error; err != nil {
+				t.Fatalf("find carts: %v", err)
+			}
+			if len(carts) != 1 {
+				t.Fatalf("got %d active carts, want 1", len(carts))
+			}
+
+			var lines []models.CartItem
+			if err := db.Where("cart_id = ?", carts[0].ID).Find(&lines).Error; err != nil {
+				t.Fatalf("find cart items: %v", err)
+			}
+			if len(lines) != 1 || lines[0].Quantity != n {
+				t.Fatalf("got lines %+v, want one line with quantity %d", lines, n)
+			}
+		})
+	}
+}
+
+func TestAddToCartConcurrentRespectsStock(t *testing.T) {
+	const n, stock = 30, 10
+	db := newCartTestDB(t)
+	item := models.Item{SKU: "SKU-1", Name: "Widget", Price: money.New(1000, "USD"),
+		Status: models.ItemStatusAvailable, Stock: stock}
+	if err := db.Create(&item).Error; err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	r := newCartTestRouter(NewCartHandler(db))
+
+	codes := addConcurrently(r, n, gin.H{"item_id": item.ID}, map[string]string{"X-Test-User": "7"})
+	var added, rejected int
+	for i, code := range codes {
+		switch code {
+		case http.StatusOK:
+			added++
+		case http.StatusConflict:
+			rejected++
+		default:
+			t.Errorf("request %d: status %d, want 200 or 409", i, code)
+		}
+	}
+	if added != stock || rejected != n-stock {
+		t.Errorf("got %d added and %d rejected, want %d and %d", added, rejected, stock, n-stock)
+	}
+
+	var line models.CartItem
+	if err := db.Where("item_id = ?", item.ID).First(&line).Error; err != nil {
+		t.Fatalf("find cart item: %v", err)
+	}
+	if line.Quantity != stock {
+		t.Errorf("quantity %d, want %d", line.Quantity, stock)
+	}
+}
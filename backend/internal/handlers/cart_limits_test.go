@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+)
+
+func TestCheckQuantityLimits(t *testing.T) {
+	tests := []struct {
+		name          string
+		min, max      int
+		line          int
+		bundles       int
+		wantLimit     string
+		wantRequested int
+	}{
+		{"no limits", 0, 0, 100, 0, "", 0},
+		{"at the minimum", 3, 0, 3, 0, "", 0},
+		{"below the minimum", 3, 0, 2, 0, "min_order_quantity", 2},
+		{"at the maximum", 0, 5, 5, 0, "", 0},
+		{"above the maximum", 0, 5, 6, 0, "max_per_order", 6},
+		// Each bundle holds two of the item
+		{"bundles count to the maximum", 0, 5, 1, 2, "", 0},
+		{"bundles break the maximum", 0, 5, 2, 2, "max_per_order", 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newCartTestDB(t)
+			item := models.Item{SKU: "SKU-1", Name: "Widget", Price: money.New(1000, "USD"),
+				Status: models.ItemStatusAvailable, Stock: 100, MinOrderQuantity: tt.min, MaxPerOrder: tt.max}
+			cart := models.Cart{Status: models.CartStatusActive, SessionID: "sess_limits"}
+			bundle := models.Bundle{Name: "Pair", Price: money.New(1800, "USD")}
+			for _, v := range []interface{}{&item, &cart, &bundle} {
+				if err := db.Create(v).Error; err != nil {
+					t.Fatalf("create %T: %v", v, err)
+				}
+			}
+			rows := []interface{}{
+				&models.CartItem{CartID: cart.ID, ItemID: item.ID, Quantity: tt.line},
+				&models.BundleComponent{BundleID: bundle.ID, ItemID: item.ID, Quantity: 2},
+			}
+			if tt.bundles > 0 {
+				rows = append(rows, &models.CartBundle{CartID: cart.ID, BundleID: bundle.ID, Quantity: tt.bundles})
+			}
+			for _, v := range rows {
+				if err := db.Create(v).Error; err != nil {
+					t.Fatalf("create %T: %v", v, err)
+				}
+			}
+
+			err := checkQuantityLimits(db, cart.ID, item, tt.line)
+			if tt.wantLimit == "" {
+				if err != nil {
+					t.Fatalf("checkQuantityLimits: %v, want no error", err)
+				}
+				return
+			}
+			ce, ok := err.(*cartError)
+			if !ok || ce.status != http.StatusBadRequest {
+				t.Fatalf("checkQuantityLimits: %v, want a 400 cartError", err)
+			}
+			violation := ce.body["items"].([]quantityLimitViolation)[0]
+			if violation.Limit != tt.wantLimit || violation.Requested != tt.wantRequested {
+				t.Errorf("violation %+v, want %s with %d requested", violation, tt.wantLimit, tt.wantRequested)
+			}
+		})
+	}
+}
+
+func TestAddToCartQuantityLimits(t *testing.T) {
+	db := newCartTestDB(t)
+	item := models.Item{SKU: "SKU-1", Name: "Widget", Price: money.New(1000, "USD"),
+		Status: models.ItemStatusAvailable, Stock: 100, MinOrderQuantity: 2, MaxPerOrder: 5}
+	if err := db.Create(&item).Error; err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	r := newCartTestRouter(NewCartHandler(db))
+	user := map[string]string{"X-Test-User": "7"}
+
+	steps := []struct {
+		quantity int
+		want     int
+		inCart   int
+	}{
+		{1, http.StatusBadRequest, 0},
+		{2, http.StatusOK, 2},
+		{3, http.StatusOK, 5},
+		// A rejected add leaves the line as it was
+		{1, http.StatusBadRequest, 5},
+	}
+	for i, step := range steps {
+		codes := addConcurrently(r, 1, gin.H{"item_id": item.ID, "quantity": step.quantity}, user)
+		if codes[0] != step.want {
+			t.Errorf("step %d: adding %d gave %d, want %d", i, step.quantity, codes[0], step.want)
+		}
+		var inCart int
+		db.Model(&models.CartItem{}).Where("item_id = ?", item.ID).Select("COALESCE(SUM(quantity), 0)").Row().Scan(&inCart)
+		if inCart != step.inCart {
+			t.Errorf("step %d: %d in the cart, want %d", i, inCart, step.inCart)
+		}
+	}
+}
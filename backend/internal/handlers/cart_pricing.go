@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+	"ecommerce-app/internal/pricing"
+)
+
+// itemPricingLine and bundlePricingLine turn cart lines into pricing lines.
+// GetCart and CreateOrder both build their lines with them, so that the
+// customer is charged what the cart showed.
+func itemPricingLine(itemID uint, name string, price money.Money, quantity int, digital bool) pricing.Line {
+	return pricing.Line{
+		ItemID:    itemID,
+		Name:      name,
+		UnitPrice: price,
+		Quantity:  quantity,
+		Shippable: !digital,
+	}
+}
+
+func bundlePricingLine(bundle models.Bundle, items map[uint]models.Item, quantity int) pricing.Line {
+	shippable := false
+	for _, comp := range bundle.Components {
+		if !items[comp.ItemID].Digital {
+			shippable = true
+		}
+	}
+	return pricing.Line{
+		BundleID:  bundle.ID,
+		Name:      bundle.Name,
+		UnitPrice: bundle.Price,
+		Quantity:  quantity,
+		Shippable: shippable,
+	}
+}
+
+// breakdownResponse holds the cart-level amounts of a breakdown, as
+// returned with carts and orders.
+func breakdownResponse(b pricing.Breakdown) gin.H {
+	response := gin.H{
+		"subtotal":    b.Subtotal,
+		"discount":    b.Discount,
+		"shipping":    b.Shipping,
+		"tax":         b.Tax,
+		"total":       b.Total,
+		"adjustments": b.Adjustments,
+	}
+	if b.Coupon != nil {
+		response["coupon"] = b.Coupon
+	}
+	return response
+}
+
+// spreadDiscount adds discount to the order lines of one cart line in
+// proportion to what is left of each, the last line taking the rounding
+// remainder.
+func spreadDiscount(lines []models.OrderItem, discount money.Money) {
+	if discount.Amount == 0 || len(lines) == 0 {
+		return
+	}
+	var remaining int64
+	for _, line := range lines {
+		remaining += line.UnitPrice.Amount*int64(line.Quantity) - line.Discount.Amount
+	}
+
+	var allocated int64
+	for i := range lines {
+		line := &lines[i]
+		share := discount.Amount - allocated
+		if i < len(lines)-1 && remaining > 0 {
+			share = discount.Amount * (line.UnitPrice.Amount*int64(line.Quantity) - line.Discount.Amount) / remaining
+		}
+		allocated += share
+		line.Discount = money.New(line.Discount.Amount+share, discount.Currency)
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/carts"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/signing"
+)
+
+// UpdatePreferencesRequest changes the notification preferences of the
+// current user.
+type UpdatePreferencesRequest struct {
+	CartRemindersOptOut *bool `json:"cart_reminders_opt_out" binding:"required"`
+}
+
+// verifyLink checks the expires and signature query parameters of a link
+// signed for subject. It writes the error response and returns false when
+// the link is not valid.
+func verifyLink(c *gin.Context, signer *signing.Signer, subject string) bool {
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid link"})
+		return false
+	}
+	if err := signer.Verify(subject, expires, c.Query("signature")); err != nil {
+		if err == signing.ErrExpired {
+			c.JSON(http.StatusGone, gin.H{"error": "Link has expired"})
+		} else {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid link"})
+		}
+		return false
+	}
+	return true
+}
+
+// RestoreCart opens a cart through the signed link of an abandoned cart
+// reminder. It needs no login. The cart counts as used again, which
+// postpones its expiry and further reminders. A cart the user has since
+// switched away from becomes the current one again, like with SwitchCart.
+func (h *CartHandler) RestoreCart(c *gin.Context) {
+	cartID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cart ID"})
+		return
+	}
+	if !verifyLink(c, h.Signer, carts.RestoreSubject(uint(cartID))) {
+		return
+	}
+
+	var cart models.Cart
+	if err := h.DB.First(&cart, cartID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart"})
+		}
+		return
+	}
+	switch {
+	case cart.Status == models.CartStatusActive:
+		if err := h.DB.Model(&cart).UpdateColumn("updated_at", time.Now()).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore cart"})
+			return
+		}
+	case cart.Status == models.CartStatusSaved && cart.UserID != nil:
+		tx := h.DB.Begin()
+		if err := makeCartCurrent(tx, &cart); err != nil {
+			tx.Rollback()
+			if isUniqueViolation(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Carts changed meanwhile, please retry"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore cart"})
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore cart"})
+			return
+		}
+	default:
+		// Ordered and expired carts cannot be brought back
+		c.JSON(http.StatusGone, gin.H{"error": "Cart is no longer available", "status": cart.Status})
+		return
+	}
+	log.Printf("Restored cart %d from a reminder", cart.ID)
+	h.renderCart(c, h.DB, cart)
+}
+
+// OptOutCartReminders stops abandoned cart reminders through the signed
+// link included in every reminder. It needs no login.
+func (h *UserHandler) OptOutCartReminders(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if !verifyLink(c, h.Signer, carts.OptOutSubject(uint(userID))) {
+		return
+	}
+
+	res := h.DB.Model(&models.User{}).Where("id = ?", userID).UpdateColumn("cart_reminders_opt_out", true)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "You will no longer receive cart reminders"})
+}
+
+// UpdatePreferences changes the notification preferences of the current
+// user.
+func (h *UserHandler) UpdatePreferences(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	var req UpdatePreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		}
+		return
+	}
+	if err := h.DB.Model(&user).Update("cart_reminders_opt_out", *req.CartRemindersOptOut).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+		return
+	}
+
+	user.Password = ""
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ecommerce-app/internal/carts"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+	"ecommerce-app/internal/signing"
+)
+
+func get(r http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestRestoreCart(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	signer := signing.NewSigner([]byte("secret"))
+	signer.Now = func() time.Time { return now }
+	link := func(cartID uint, expires time.Time) string {
+		return fmt.Sprintf("/carts/%d/restore?expires=%d&signature=%s",
+			cartID, expires.Unix(), signer.Sign(carts.RestoreSubject(cartID), expires))
+	}
+	expires := now.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		status string
+		// link returns the link to follow to the cart
+		link       func(cartID uint) string
+		wantCode   int
+		wantStatus string
+	}{
+		{"active", models.CartStatusActive, func(id uint) string { return link(id, expires) }, http.StatusOK, models.CartStatusActive},
+		{"saved", models.CartStatusSaved, func(id uint) string { return link(id, expires) }, http.StatusOK, models.CartStatusActive},
+		{"ordered", models.CartStatusOrdered, func(id uint) string { return link(id, expires) }, http.StatusGone, models.CartStatusOrdered},
+		{"expired cart", models.CartStatusExpired, func(id uint) string { return link(id, expires) }, http.StatusGone, models.CartStatusExpired},
+		{"expired link", models.CartStatusSaved, func(id uint) string { return link(id, now.Add(-time.Second)) }, http.StatusGone, models.CartStatusSaved},
+		{"link of another cart", models.CartStatusSaved, func(id uint) string {
+			return fmt.Sprintf("/carts/%d/restore?expires=%d&signature=%s", id, expires.Unix(), signer.Sign(carts.RestoreSubject(id+1), expires))
+		}, http.StatusForbidden, models.CartStatusSaved},
+		{"changed expiry", models.CartStatusSaved, func(id uint) string { return link(id, expires) + "0" }, http.StatusForbidden, models.CartStatusSaved},
+		{"no signature", models.CartStatusSaved, func(id uint) string { return fmt.Sprintf("/carts/%d/restore", id) }, http.StatusForbidden, models.CartStatusSaved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newCartTestDB(t)
+			h := NewCartHandler(db)
+			h.Signer = signer
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.GET("/carts/:id/restore", h.RestoreCart)
+
+			item := models.Item{SKU: "SKU-1", Name: "Widget", Price: money.New(1000, "USD"),
+				Status: models.ItemStatusAvailable, Stock: 10}
+			if err := db.Create(&item).Error; err != nil {
+				t.Fatalf("create item: %v", err)
+			}
+			userID := uint(7)
+			idle := now.Add(-48 * time.Hour)
+			cart := models.Cart{UserID: &userID, Status: tt.status, Name: "Reminded", UpdatedAt: idle}
+			if err := db.Create(&cart).Error; err != nil {
+				t.Fatalf("create cart: %v", err)
+			}
+			if err := db.Create(&models.CartItem{CartID: cart.ID, ItemID: item.ID, Quantity: 2, UnitPrice: item.Price}).Error; err != nil {
+				t.Fatalf("create cart item: %v", err)
+			}
+			// The cart the user is on now, unless it is the reminded one
+			var current models.Cart
+			if tt.status != models.CartStatusActive {
+				current = models.Cart{UserID: &userID, Status: models.CartStatusActive}
+				if err := db.Create(&current).Error; err != nil {
+					t.Fatalf("create cart: %v", err)
+				}
+			}
+
+			w := get(r, tt.link(cart.ID))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+
+			var got models.Cart
+			if err := db.First(&got, cart.ID).Error; err != nil {
+				t.Fatalf("find cart: %v", err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("cart %s, want %s", got.Status, tt.wantStatus)
+			}
+			if restored := got.UpdatedAt.After(idle); restored != (tt.wantCode == http.StatusOK) {
+				t.Errorf("cart updated at %v, want it used again: %v", got.UpdatedAt, !restored)
+			}
+			// Restoring a saved cart saves the one the user was on
+			if current.ID != 0 {
+				wantCurrent := models.CartStatusActive
+				if tt.wantCode == http.StatusOK {
+					wantCurrent = models.CartStatusSaved
+				}
+				var previous models.Cart
+				if err := db.First(&previous, current.ID).Error; err != nil {
+					t.Fatalf("find cart: %v", err)
+				}
+				if previous.Status != wantCurrent {
+					t.Errorf("current cart %s, want %s", previous.Status, wantCurrent)
+				}
+			}
+		})
+	}
+}
+
+func TestOptOutCartReminders(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	signer := signing.NewSigner([]byte("secret"))
+	signer.Now = func() time.Time { return now }
+	expires := now.Add(time.Hour)
+	link := func(userID uint, subjectID uint) string {
+		return fmt.Sprintf("/users/%d/cart-reminders/opt-out?expires=%d&signature=%s",
+			userID, expires.Unix(), signer.Sign(carts.OptOutSubject(subjectID), expires))
+	}
+
+	db := newCartTestDB(t, &models.User{})
+	h := NewUserHandler(db)
+	h.Signer = signer
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/users/:id/cart-reminders/opt-out", h.OptOutCartReminders)
+
+	ann := models.User{Username: "ann", Password: "x"}
+	bob := models.User{Username: "bob", Password: "x"}
+	for _, u := range []*models.User{&ann, &bob} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	// A link only opts out the user it was sent to
+	if w := get(r, link(bob.ID, ann.ID)); w.Code != http.StatusForbidden {
+		t.Errorf("link of another user: status %d, want 403", w.Code)
+	}
+	if w := get(r, link(ann.ID, ann.ID)); w.Code != http.StatusOK {
+		t.Errorf("status %d, want 200: %s", w.Code, w.Body)
+	}
+	if w := get(r, link(999, 999)); w.Code != http.StatusNotFound {
+		t.Errorf("unknown user: status %d, want 404", w.Code)
+	}
+
+	for _, tt := range []struct {
+		user models.User
+		want bool
+	}{{ann, true}, {bob, false}} {
+		var got models.User
+		if err := db.First(&got, tt.user.ID).Error; err != nil {
+			t.Fatalf("find user: %v", err)
+		}
+		if got.CartRemindersOptOut != tt.want {
+			t.Errorf("%s opted out: %v, want %v", got.Username, got.CartRemindersOptOut, tt.want)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+)
+
+// UpdateCartLineRequest sets the quantity of a cart line. Zero removes it.
+type UpdateCartLineRequest struct {
+	Quantity *int `json:"quantity" binding:"required,gte=0"`
+}
+
+// cartOwner returns who owns the cart of the request: the user when
+// authenticated, otherwise the X-Session-ID header.
+func cartOwner(c *gin.Context) (*uint, string) {
+	if userID, ok := userIDFromContext(c); ok {
+		return &userID, ""
+	}
+	return nil, c.GetHeader("X-Session-ID")
+}
+
+// changeCart runs change against the active cart of the request in a
+// transaction and responds with the updated cart like GetCart.
+func (h *CartHandler) changeCart(c *gin.Context, change func(tx *gorm.DB, cart models.Cart) error) {
+	userID, sessionID := cartOwner(c)
+
+	tx := h.DB.Begin()
+	cart, err := findActiveCart(tx, userID, sessionID)
+	if err != nil {
+		tx.Rollback()
+		switch err {
+		case errNoCartOwner:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Authentication or session ID required"})
+		case gorm.ErrRecordNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "No active cart found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find cart", "details": err.Error()})
+		}
+		return
+	}
+
+	if err := change(tx, cart); err != nil {
+		tx.Rollback()
+		respondCartError(c, err)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart", "details": err.Error()})
+		return
+	}
+
+	// The change may have been to the cart itself, such as its coupon
+	if err := h.DB.First(&cart, cart.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart", "details": err.Error()})
+		return
+	}
+	h.renderCart(c, h.DB, cart)
+}
+
+// lineID parses the item or bundle ID of a cart line from the path.
+func lineID(c *gin.Context, param string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(param), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// UpdateCartItem sets the quantity of an item in the cart. Raising it is
+// checked like adding to the cart; lowering it needs no stock but cannot go
+// below the item's min_order_quantity. Zero removes the line.
+func (h *CartHandler) UpdateCartItem(c *gin.Context) {
+	itemID, ok := lineID(c, "itemID")
+	if !ok {
+		return
+	}
+	var req UpdateCartLineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	h.changeCart(c, func(tx *gorm.DB, cart models.Cart) error {
+		return setCartItemQuantity(tx, cart.ID, itemID, *req.Quantity)
+	})
+}
+
+// RemoveCartItem removes an item from the cart.
+func (h *CartHandler) RemoveCartItem(c *gin.Context) {
+	itemID, ok := lineID(c, "itemID")
+	if !ok {
+		return
+	}
+	h.changeCart(c, func(tx *gorm.DB, cart models.Cart) error {
+		return setCartItemQuantity(tx, cart.ID, itemID, 0)
+	})
+}
+
+// UpdateCartBundle sets the quantity of a bundle in the cart, like
+// UpdateCartItem.
+func (h *CartHandler) UpdateCartBundle(c *gin.Context) {
+	bundleID, ok := lineID(c, "bundleID")
+	if !ok {
+		return
+	}
+	var req UpdateCartLineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	h.changeCart(c, func(tx *gorm.DB, cart models.Cart) error {
+		return setCartBundleQuantity(tx, cart.ID, bundleID, *req.Quantity)
+	})
+}
+
+// RemoveCartBundle removes a bundle from the cart.
+func (h *CartHandler) RemoveCartBundle(c *gin.Context) {
+	bundleID, ok := lineID(c, "bundleID")
+	if !ok {
+		return
+	}
+	h.changeCart(c, func(tx *gorm.DB, cart models.Cart) error {
+		return setCartBundleQuantity(tx, cart.ID, bundleID, 0)
+	})
+}
+
+// ClearCart removes every line from the cart. The cart itself stays active.
+func (h *CartHandler) ClearCart(c *gin.Context) {
+	h.changeCart(c, func(tx *gorm.DB, cart models.Cart) error {
+		if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.CartBundle{}).Error; err != nil {
+			return err
+		}
+		log.Printf("Cleared cart %d", cart.ID)
+		return nil
+	})
+}
+
+// setCartItemQuantity sets the quantity of an item already in the cart
+// inside tx. Only raising it needs stock; the purchase limits apply either
+// way. Rejections are returned as *cartError, after which the caller rolls
+// back.
+func setCartItemQuantity(tx *gorm.DB, cartID, itemID uint, quantity int) error {
+	var line models.CartItem
+	if err := tx.Where("cart_id = ? AND item_id = ?", cartID, itemID).First(&line).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return &cartError{status: http.StatusNotFound, body: gin.H{"error": "Item is not in the cart"}}
+		}
+		return err
+	}
+
+	// CartItem has no single primary key, so the line is selected explicitly
+	lineQuery := tx.Model(&models.CartItem{}).Where("cart_id = ? AND item_id = ?", cartID, itemID)
+	if quantity == 0 {
+		log.Printf("Removed item %d from cart %d", itemID, cartID)
+		return lineQuery.Delete(&models.CartItem{}).Error
+	}
+
+	var item models.Item
+	var err error
+	if quantity > line.Quantity {
+		if item, err = loadSellableItem(tx, itemID); err != nil {
+			return err
+		}
+		if !item.Purchasable(quantity) {
+			return insufficientStockError(item, quantity)
+		}
+	} else if err = tx.First(&item, itemID).Error; err != nil {
+		return err
+	}
+
+	if err := lineQuery.Update("quantity", quantity).Error; err != nil {
+		return err
+	}
+	// Limits apply both ways, so lowering below the minimum is rejected too
+	if err := checkQuantityLimits(tx, cartID, item, quantity); err != nil {
+		return err
+	}
+	log.Printf("Updated quantity for item %d in cart %d to %d", itemID, cartID, quantity)
+	return nil
+}
+
+// setCartBundleQuantity sets the quantity of a bundle already in the cart
+// inside tx. Rejections are returned as *cartError, after which the caller
+// rolls back.
+func setCartBundleQuantity(tx *gorm.DB, cartID, bundleID uint, quantity int) error {
+	var line models.CartBundle
+	if err := tx.Where("cart_id = ? AND bundle_id = ?", cartID, bundleID).First(&line).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return &cartError{status: http.StatusNotFound, body: gin.H{"error": "Bundle is not in the cart"}}
+		}
+		return err
+	}
+
+	if quantity == 0 {
+		log.Printf("Removed bundle %d from cart %d", bundleID, cartID)
+		return tx.Delete(&line).Error
+	}
+	if quantity <= line.Quantity {
+		log.Printf("Updated quantity for bundle %d in cart %d to %d", bundleID, cartID, quantity)
+		return tx.Model(&line).Update("quantity", quantity).Error
+	}
+
+	bundle, items, err := loadSellableBundle(tx, bundleID)
+	if err != nil {
+		return err
+	}
+	if err := checkBundleQuantity(bundle, items, quantity); err != nil {
+		return err
+	}
+	if err := tx.Model(&line).Update("quantity", quantity).Error; err != nil {
+		return err
+	}
+	if err := checkBundleLimits(tx, cartID, items); err != nil {
+		return err
+	}
+	log.Printf("Updated quantity for bundle %d in cart %d to %d", bundleID, cartID, quantity)
+	return nil
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// userIDFromContext returns the ID that AuthMiddleware stored for the
+// current request, accepting the numeric types it may have been stored as.
+func userIDFromContext(c *gin.Context) (uint, bool) {
+	v, exists := c.Get("userID")
+	if !exists || v == nil {
+		return 0, false
+	}
+	switch id := v.(type) {
+	case uint:
+		return id, true
+	case float64:
+		return uint(id), true
+	case int:
+		return uint(id), true
+	}
+	return 0, false
+}
@@ -0,0 +1,467 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+	"ecommerce-app/internal/pricing"
+)
+
+type CouponHandler struct {
+	DB *gorm.DB
+}
+
+func NewCouponHandler(db *gorm.DB) *CouponHandler {
+	return &CouponHandler{DB: db}
+}
+
+// CouponRequest creates or replaces a coupon. Percent coupons take
+// PercentOff, fixed coupons AmountOff. ItemIDs and Categories limit the
+// coupon to those items; categories are values of the "category" enum
+// attribute.
+type CouponRequest struct {
+	Code           string      `json:"code" binding:"required,max=64"`
+	Description    string      `json:"description"`
+	Type           string      `json:"type" binding:"required,oneof=percent fixed"`
+	PercentOff     int         `json:"percent_off" binding:"gte=0,lte=100"`
+	AmountOff      money.Money `json:"amount_off"`
+	MinSpend       money.Money `json:"min_spend"`
+	MaxUses        int         `json:"max_uses" binding:"gte=0"`
+	MaxUsesPerUser int         `json:"max_uses_per_user" binding:"gte=0"`
+	StartsAt       *time.Time  `json:"starts_at"`
+	EndsAt         *time.Time  `json:"ends_at"`
+	ItemIDs        []uint      `json:"item_ids"`
+	Categories     []string    `json:"categories"`
+}
+
+// ApplyCouponRequest applies a coupon to the cart by its code.
+type ApplyCouponRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// coupon validates the request and returns the coupon it describes. On
+// error it writes a 400 response and returns false.
+func (req CouponRequest) coupon(c *gin.Context, db *gorm.DB) (models.Coupon, bool) {
+	coupon := models.Coupon{
+		Code:           models.NormalizeCouponCode(req.Code),
+		Description:    strings.TrimSpace(req.Description),
+		Type:           req.Type,
+		MaxUses:        req.MaxUses,
+		MaxUsesPerUser: req.MaxUsesPerUser,
+		StartsAt:       req.StartsAt,
+		EndsAt:         req.EndsAt,
+		MinSpend:       req.MinSpend,
+	}
+	if coupon.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code is required"})
+		return coupon, false
+	}
+	switch req.Type {
+	case models.CouponTypePercent:
+		if req.PercentOff == 0 || !req.AmountOff.IsZero() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Percent coupons need percent_off and no amount_off"})
+			return coupon, false
+		}
+		coupon.PercentOff = req.PercentOff
+		coupon.AmountOff = money.Zero(money.DefaultCurrency)
+	case models.CouponTypeFixed:
+		if req.AmountOff.Amount <= 0 || req.PercentOff != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fixed coupons need a positive amount_off and no percent_off"})
+			return coupon, false
+		}
+		coupon.AmountOff = req.AmountOff
+	}
+	if coupon.MinSpend.Amount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_spend cannot be negative"})
+		return coupon, false
+	}
+	if coupon.MinSpend.Currency == "" {
+		coupon.MinSpend = money.Zero(coupon.AmountOff.Currency)
+	}
+	if coupon.StartsAt != nil && coupon.EndsAt != nil && !coupon.EndsAt.After(*coupon.StartsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at"})
+		return coupon, false
+	}
+
+	seenItems := make(map[uint]bool)
+	for _, id := range req.ItemIDs {
+		if !seenItems[id] {
+			seenItems[id] = true
+			coupon.Items = append(coupon.Items, models.CouponItem{ItemID: id})
+		}
+	}
+	if len(seenItems) > 0 {
+		var found int
+		if err := db.Model(&models.Item{}).Where("id IN (?)", req.ItemIDs).Count(&found).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check items"})
+			return coupon, false
+		}
+		if found != len(seenItems) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Some items do not exist"})
+			return coupon, false
+		}
+	}
+	seenCategories := make(map[string]bool)
+	for _, category := range req.Categories {
+		category = strings.TrimSpace(category)
+		if category == "" || seenCategories[strings.ToLower(category)] {
+			continue
+		}
+		seenCategories[strings.ToLower(category)] = true
+		coupon.Categories = append(coupon.Categories, models.CouponCategory{Category: category})
+	}
+	return coupon, true
+}
+
+// ListCoupons returns every coupon with its eligible items and categories.
+func (h *CouponHandler) ListCoupons(c *gin.Context) {
+	var coupons []models.Coupon
+	if err := h.DB.Preload("Items").Preload("Categories").Order("id").Find(&coupons).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupons"})
+		return
+	}
+	c.JSON(http.StatusOK, coupons)
+}
+
+// CreateCoupon adds a coupon. Codes are unique regardless of case.
+func (h *CouponHandler) CreateCoupon(c *gin.Context) {
+	var req CouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	coupon, ok := req.coupon(c, h.DB)
+	if !ok {
+		return
+	}
+
+	// Eligible items and categories are created along with the coupon
+	if err := h.DB.Create(&coupon).Error; err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A coupon with this code already exists"})
+			return
+		}
+		log.Printf("Error creating coupon %s: %v", coupon.Code, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create coupon"})
+		return
+	}
+	c.JSON(http.StatusCreated, coupon)
+}
+
+// UpdateCoupon replaces a coupon and its eligibility. How often it has been
+// used is kept.
+func (h *CouponHandler) UpdateCoupon(c *gin.Context) {
+	var existing models.Coupon
+	if err := h.DB.First(&existing, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupon"})
+		}
+		return
+	}
+	var req CouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	coupon, ok := req.coupon(c, h.DB)
+	if !ok {
+		return
+	}
+	coupon.ID = existing.ID
+
+	tx := h.DB.Begin()
+	// Uses is left out, as orders may be redeeming the coupon right now
+	if err := tx.Model(&existing).Updates(map[string]interface{}{
+		"code":                coupon.Code,
+		"description":         coupon.Description,
+		"type":                coupon.Type,
+		"percent_off":         coupon.PercentOff,
+		"amount_off_amount":   coupon.AmountOff.Amount,
+		"amount_off_currency": coupon.AmountOff.Currency,
+		"min_spend_amount":    coupon.MinSpend.Amount,
+		"min_spend_currency":  coupon.MinSpend.Currency,
+		"max_uses":            coupon.MaxUses,
+		"max_uses_per_user":   coupon.MaxUsesPerUser,
+		"starts_at":           coupon.StartsAt,
+		"ends_at":             coupon.EndsAt,
+	}).Error; err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A coupon with this code already exists"})
+			return
+		}
+		log.Printf("Error updating coupon %d: %v", existing.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update coupon"})
+		return
+	}
+	if err := replaceCouponEligibility(tx, &coupon); err != nil {
+		tx.Rollback()
+		log.Printf("Error updating eligibility of coupon %d: %v", existing.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update coupon"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update coupon"})
+		return
+	}
+
+	if err := h.DB.Preload("Items").Preload("Categories").First(&coupon, coupon.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupon"})
+		return
+	}
+	c.JSON(http.StatusOK, coupon)
+}
+
+// replaceCouponEligibility replaces the eligible items and categories of
+// coupon with the ones it holds.
+func replaceCouponEligibility(tx *gorm.DB, coupon *models.Coupon) error {
+	if err := tx.Where("coupon_id = ?", coupon.ID).Delete(&models.CouponItem{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("coupon_id = ?", coupon.ID).Delete(&models.CouponCategory{}).Error; err != nil {
+		return err
+	}
+	for i := range coupon.Items {
+		coupon.Items[i].CouponID = coupon.ID
+		if err := tx.Create(&coupon.Items[i]).Error; err != nil {
+			return err
+		}
+	}
+	for i := range coupon.Categories {
+		coupon.Categories[i].CouponID = coupon.ID
+		if err := tx.Create(&coupon.Categories[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteCoupon removes a coupon that was never redeemed and takes it off
+// the carts it was applied to. Redeemed coupons are kept for the orders
+// that used them; end them instead.
+func (h *CouponHandler) DeleteCoupon(c *gin.Context) {
+	var coupon models.Coupon
+	if err := h.DB.First(&coupon, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupon"})
+		}
+		return
+	}
+
+	tx := h.DB.Begin()
+	// Checked in the transaction, so an order redeeming it meanwhile wins
+	res := tx.Where("id = ? AND uses = 0", coupon.ID).Delete(&models.Coupon{})
+	if res.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete coupon"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Coupon has been redeemed; set ends_at to stop it instead"})
+		return
+	}
+	if err := tx.Where("coupon_id = ?", coupon.ID).Delete(&models.CouponItem{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete coupon"})
+		return
+	}
+	if err := tx.Where("coupon_id = ?", coupon.ID).Delete(&models.CouponCategory{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete coupon"})
+		return
+	}
+	if err := tx.Model(&models.Cart{}).Where("coupon_id = ?", coupon.ID).UpdateColumn("coupon_id", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete coupon"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete coupon"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Coupon deleted successfully"})
+}
+
+// ApplyCoupon applies a coupon to the cart, replacing any other. Coupons
+// that cannot be used at all are rejected; whether the cart meets the
+// minimum spend and has eligible items is shown with the cart, as that
+// changes with its contents.
+func (h *CartHandler) ApplyCoupon(c *gin.Context) {
+	var req ApplyCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	h.changeCart(c, func(tx *gorm.DB, cart models.Cart) error {
+		var coupon models.Coupon
+		if err := tx.Where("code = ?", models.NormalizeCouponCode(req.Code)).First(&coupon).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return &cartError{status: http.StatusNotFound, body: gin.H{"error": "Coupon not found"}}
+			}
+			return err
+		}
+		problem, err := couponProblem(tx, coupon, cart.UserID, time.Now())
+		if err != nil {
+			return err
+		}
+		if problem != "" {
+			return &cartError{status: http.StatusUnprocessableEntity, body: gin.H{"error": problem}}
+		}
+		return tx.Model(&cart).UpdateColumns(map[string]interface{}{"coupon_id": coupon.ID, "updated_at": time.Now()}).Error
+	})
+}
+
+// RemoveCoupon takes the coupon off the cart.
+func (h *CartHandler) RemoveCoupon(c *gin.Context) {
+	h.changeCart(c, func(tx *gorm.DB, cart models.Cart) error {
+		return tx.Model(&cart).UpdateColumns(map[string]interface{}{"coupon_id": nil, "updated_at": time.Now()}).Error
+	})
+}
+
+// couponProblem returns why coupon cannot be used by userID at now, or ""
+// when it can. Per-user limits are only checked for signed-in users, and
+// again at checkout.
+func couponProblem(tx *gorm.DB, coupon models.Coupon, userID *uint, now time.Time) (string, error) {
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return "Coupon is not valid yet", nil
+	}
+	if coupon.EndsAt != nil && !now.Before(*coupon.EndsAt) {
+		return "Coupon has expired", nil
+	}
+	if coupon.MaxUses > 0 && coupon.Uses >= coupon.MaxUses {
+		return "Coupon has been used up", nil
+	}
+	if userID != nil && coupon.MaxUsesPerUser > 0 {
+		var used int
+		if err := tx.Model(&models.CouponRedemption{}).Where("coupon_id = ? AND user_id = ?", coupon.ID, *userID).Count(&used).Error; err != nil {
+			return "", err
+		}
+		if used >= coupon.MaxUsesPerUser {
+			return "You have already used this coupon", nil
+		}
+	}
+	return "", nil
+}
+
+// cartCoupon loads the coupon applied to cart for pricing its lines, and
+// sets the categories of the item lines when the coupon is limited to
+// categories. It returns nil when the cart has no coupon.
+func cartCoupon(tx *gorm.DB, cart models.Cart, lines []pricing.Line, now time.Time) (*models.Coupon, *pricing.Coupon, error) {
+	if cart.CouponID == nil {
+		return nil, nil, nil
+	}
+	var coupon models.Coupon
+	if err := tx.Preload("Items").Preload("Categories").First(&coupon, *cart.CouponID).Error; err != nil {
+		return nil, nil, err
+	}
+	problem, err := couponProblem(tx, coupon, cart.UserID, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pc := &pricing.Coupon{
+		Code:       coupon.Code,
+		MinSpend:   coupon.MinSpend,
+		ItemIDs:    make(map[uint]bool),
+		Categories: make(map[string]bool),
+		Problem:    problem,
+	}
+	if coupon.Type == models.CouponTypePercent {
+		pc.PercentOff = int64(coupon.PercentOff) * 100
+	} else {
+		pc.AmountOff = coupon.AmountOff
+	}
+	for _, item := range coupon.Items {
+		pc.ItemIDs[item.ItemID] = true
+	}
+	for _, category := range coupon.Categories {
+		pc.Categories[strings.ToLower(category.Category)] = true
+	}
+
+	if len(pc.Categories) > 0 {
+		var itemIDs []uint
+		for _, line := range lines {
+			if line.ItemID != 0 {
+				itemIDs = append(itemIDs, line.ItemID)
+			}
+		}
+		categories, err := itemCategories(tx, itemIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range lines {
+			lines[i].Categories = categories[lines[i].ItemID]
+		}
+	}
+	return &coupon, pc, nil
+}
+
+// itemCategories returns the lower-cased category of each of the items
+// that has one.
+func itemCategories(tx *gorm.DB, itemIDs []uint) (map[uint][]string, error) {
+	result := make(map[uint][]string)
+	if len(itemIDs) == 0 {
+		return result, nil
+	}
+	var rows []struct {
+		ItemID    uint   `gorm:"column:item_id"`
+		TextValue string `gorm:"column:text_value"`
+	}
+	if err := tx.Table("item_attribute_values").
+		Select("item_attribute_values.item_id, item_attribute_values.text_value").
+		Joins("JOIN attribute_definitions ON attribute_definitions.id = item_attribute_values.attribute_id").
+		Where("attribute_definitions.code = ? AND item_attribute_values.item_id IN (?) AND item_attribute_values.text_value IS NOT NULL", models.CategoryAttribute, itemIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.ItemID] = append(result[row.ItemID], strings.ToLower(row.TextValue))
+	}
+	return result, nil
+}
+
+// redeemCoupon records the use of coupon by order. One conditional update
+// enforces the overall limit, so concurrent orders cannot both take the
+// last use; it also holds the coupon's row until the transaction ends,
+// which keeps the count of the user's redemptions from racing.
+func redeemCoupon(tx *gorm.DB, coupon models.Coupon, order models.Order, discount money.Money) error {
+	res := tx.Model(&models.Coupon{}).
+		Where("id = ? AND (max_uses = 0 OR uses < max_uses)", coupon.ID).
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return &cartError{status: http.StatusConflict, body: gin.H{"error": "Coupon has been used up", "code": coupon.Code}}
+	}
+
+	if coupon.MaxUsesPerUser > 0 {
+		var used int
+		if err := tx.Model(&models.CouponRedemption{}).Where("coupon_id = ? AND user_id = ?", coupon.ID, order.UserID).Count(&used).Error; err != nil {
+			return err
+		}
+		if used >= coupon.MaxUsesPerUser {
+			return &cartError{status: http.StatusConflict, body: gin.H{"error": "You have already used this coupon", "code": coupon.Code}}
+		}
+	}
+
+	redemption := models.CouponRedemption{
+		CouponID: coupon.ID,
+		UserID:   order.UserID,
+		OrderID:  order.ID,
+		Discount: discount,
+	}
+	return tx.Create(&redemption).Error
+}
@@ -0,0 +1,542 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/signing"
+	"ecommerce-app/internal/storage"
+)
+
+const (
+	// maxDigitalFileSize is the largest file accepted for a digital item.
+	maxDigitalFileSize = 512 << 20
+	// maxDownloadsPerFile is how often a buyer may download each file.
+	maxDownloadsPerFile = 5
+)
+
+// DigitalHandler manages the files and license keys of digital items and
+// hands them out to buyers.
+type DigitalHandler struct {
+	DB *gorm.DB
+	// Blobs must not be publicly served; files are only reachable through
+	// signed download links.
+	Blobs  storage.BlobStore
+	Signer *signing.Signer
+	// LinkTTL is how long a download link works once handed out.
+	LinkTTL time.Duration
+}
+
+func NewDigitalHandler(db *gorm.DB, blobs storage.BlobStore, signer *signing.Signer) *DigitalHandler {
+	return &DigitalHandler{DB: db, Blobs: blobs, Signer: signer, LinkTTL: time.Hour}
+}
+
+// AddLicenseKeysRequest is the JSON form of a key upload. Keys can also be
+// sent as text/plain, one per line.
+type AddLicenseKeysRequest struct {
+	Keys []string `json:"keys" binding:"required,min=1"`
+}
+
+// DownloadResponse is a file of an order with a link to fetch it.
+type DownloadResponse struct {
+	ItemID             uint      `json:"item_id"`
+	FileName           string    `json:"file_name"`
+	Size               int64     `json:"size"`
+	URL                string    `json:"url,omitempty"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	DownloadsRemaining int       `json:"downloads_remaining"`
+}
+
+// LicenseKeyResponse is a license key assigned to an order.
+type LicenseKeyResponse struct {
+	ItemID uint   `json:"item_id"`
+	Key    string `json:"key"`
+}
+
+// downloadSubject is what a download link's signature covers.
+func downloadSubject(grantID uint) string {
+	return fmt.Sprintf("download:%d", grantID)
+}
+
+// orderDownloadsURL is where the buyer of an order gets download links.
+func orderDownloadsURL(orderID uint) string {
+	return fmt.Sprintf("/api/orders/%d/downloads", orderID)
+}
+
+// loadDigitalItem returns the item from the :id parameter if it is digital.
+// On failure it writes the response and returns false.
+func (h *DigitalHandler) loadDigitalItem(c *gin.Context) (models.Item, bool) {
+	var item models.Item
+	if err := h.DB.First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return item, false
+	}
+	if !item.Digital {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Item is not digital"})
+		return item, false
+	}
+	return item, true
+}
+
+// UploadFiles accepts one or more multipart files in the "files" field and
+// attaches them to a digital item.
+func (h *DigitalHandler) UploadFiles(c *gin.Context) {
+	item, ok := h.loadDigitalItem(c)
+	if !ok {
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart form", "details": err.Error()})
+		return
+	}
+	files := form.File["files"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No files uploaded in the files field"})
+		return
+	}
+
+	uploaded := make([]models.DigitalAsset, 0, len(files))
+	for _, fh := range files {
+		asset, err := h.storeFile(item.ID, fh)
+		if err != nil {
+			log.Printf("Error storing file %q for item %d: %v", fh.Filename, item.ID, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":    "Failed to store file",
+				"filename": fh.Filename,
+				"details":  err.Error(),
+				"uploaded": uploaded,
+			})
+			return
+		}
+		uploaded = append(uploaded, *asset)
+	}
+	c.JSON(http.StatusCreated, gin.H{"files": uploaded})
+}
+
+// storeFile saves an uploaded file in the blob store and records it.
+func (h *DigitalHandler) storeFile(itemID uint, fh *multipart.FileHeader) (*models.DigitalAsset, error) {
+	if fh.Size > maxDigitalFileSize {
+		return nil, fmt.Errorf("file is larger than %d MB", maxDigitalFileSize>>20)
+	}
+	name := path.Base(strings.ReplaceAll(fh.Filename, "\\", "/"))
+	if name == "." || name == "/" {
+		return nil, fmt.Errorf("file has no name")
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("items/%d/%s/%s", itemID, dir, name)
+	if err := h.Blobs.Put(key, f); err != nil {
+		return nil, err
+	}
+
+	asset := models.DigitalAsset{
+		ItemID:      itemID,
+		FileName:    name,
+		StorageKey:  key,
+		ContentType: contentType,
+		Size:        fh.Size,
+	}
+	if err := h.DB.Create(&asset).Error; err != nil {
+		h.Blobs.Delete(key)
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// ListFiles returns the files of a digital item.
+func (h *DigitalHandler) ListFiles(c *gin.Context) {
+	item, ok := h.loadDigitalItem(c)
+	if !ok {
+		return
+	}
+	assets := []models.DigitalAsset{}
+	if err := h.DB.Where("item_id = ?", item.ID).Order("id").Find(&assets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch files"})
+		return
+	}
+	c.JSON(http.StatusOK, assets)
+}
+
+// DeleteFile removes a file from a digital item. Buyers who were granted the
+// file can no longer download it.
+func (h *DigitalHandler) DeleteFile(c *gin.Context) {
+	var asset models.DigitalAsset
+	if err := h.DB.Where("id = ? AND item_id = ?", c.Param("fileID"), c.Param("id")).First(&asset).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file"})
+		}
+		return
+	}
+
+	tx := h.DB.Begin()
+	if err := tx.Where("asset_id = ?", asset.ID).Delete(&models.DownloadGrant{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
+		return
+	}
+	if err := tx.Delete(&asset).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
+		return
+	}
+
+	// The record is gone, so a leftover blob is only wasted space
+	if err := h.Blobs.Delete(asset.StorageKey); err != nil {
+		log.Printf("Failed to delete blob %s: %v", asset.StorageKey, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "File deleted"})
+}
+
+// AddLicenseKeys adds keys to a digital item's pool. Keys already in the
+// pool are skipped.
+func (h *DigitalHandler) AddLicenseKeys(c *gin.Context) {
+	item, ok := h.loadDigitalItem(c)
+	if !ok {
+		return
+	}
+
+	var keys []string
+	if strings.HasPrefix(c.ContentType(), "text/plain") {
+		scanner := bufio.NewScanner(c.Request.Body)
+		for scanner.Scan() {
+			keys = append(keys, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read keys", "details": err.Error()})
+			return
+		}
+	} else {
+		var req AddLicenseKeysRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+		keys = req.Keys
+	}
+
+	added, duplicates := 0, 0
+	tx := h.DB.Begin()
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		var count int
+		if err := tx.Model(&models.LicenseKey{}).Where("item_id = ? AND license_key = ?", item.ID, key).
+			Count(&count).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add license keys"})
+			return
+		}
+		if count > 0 {
+			duplicates++
+			continue
+		}
+		if err := tx.Create(&models.LicenseKey{ItemID: item.ID, Key: key}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add license keys"})
+			return
+		}
+		added++
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add license keys"})
+		return
+	}
+
+	available, assigned, err := licenseKeyCounts(h.DB, item.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count license keys"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"added":      added,
+		"duplicates": duplicates,
+		"available":  available,
+		"assigned":   assigned,
+	})
+}
+
+// GetLicenseKeyPool returns how many of a digital item's keys are left.
+func (h *DigitalHandler) GetLicenseKeyPool(c *gin.Context) {
+	item, ok := h.loadDigitalItem(c)
+	if !ok {
+		return
+	}
+	available, assigned, err := licenseKeyCounts(h.DB, item.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count license keys"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"item_id": item.ID, "available": available, "assigned": assigned})
+}
+
+func licenseKeyCounts(db *gorm.DB, itemID uint) (available, assigned int, err error) {
+	if err = db.Model(&models.LicenseKey{}).Where("item_id = ? AND order_id IS NULL", itemID).
+		Count(&available).Error; err != nil {
+		return
+	}
+	err = db.Model(&models.LicenseKey{}).Where("item_id = ? AND order_id IS NOT NULL", itemID).
+		Count(&assigned).Error
+	return
+}
+
+// fulfillDigitalItems grants downloads of every file of the digital items
+// among lines and assigns them license keys, one per unit, inside tx. Items
+// without keys in their pool are sold without keys. It reports whether the
+// order contains digital items; running out of keys is returned as
+// *cartError.
+func fulfillDigitalItems(tx *gorm.DB, orderID uint, lines []models.OrderItem) (bool, error) {
+	quantities := make(map[uint]int)
+	var ids []uint
+	for _, line := range lines {
+		if _, ok := quantities[line.ItemID]; !ok {
+			ids = append(ids, line.ItemID)
+		}
+		quantities[line.ItemID] += line.Quantity
+	}
+	if len(ids) == 0 {
+		return false, nil
+	}
+
+	var items []models.Item
+	if err := tx.Where("id IN (?) AND digital = ?", ids, true).Order("id").Find(&items).Error; err != nil {
+		return false, err
+	}
+
+	var shortages []stockShortage
+	for _, item := range items {
+		var assets []models.DigitalAsset
+		if err := tx.Where("item_id = ?", item.ID).Order("id").Find(&assets).Error; err != nil {
+			return false, err
+		}
+		for _, asset := range assets {
+			if err := tx.Create(&models.DownloadGrant{
+				OrderID:      orderID,
+				ItemID:       item.ID,
+				AssetID:      asset.ID,
+				MaxDownloads: maxDownloadsPerFile,
+			}).Error; err != nil {
+				return false, err
+			}
+		}
+
+		var pool int
+		if err := tx.Model(&models.LicenseKey{}).Where("item_id = ?", item.ID).Count(&pool).Error; err != nil {
+			return false, err
+		}
+		if pool == 0 {
+			continue
+		}
+		// Oldest keys first, claimed in one statement so two checkouts
+		// cannot get the same key
+		quantity := quantities[item.ID]
+		res := tx.Exec(`UPDATE license_keys SET order_id = ?, assigned_at = ?
+			WHERE id IN (SELECT id FROM license_keys WHERE item_id = ? AND order_id IS NULL ORDER BY id LIMIT ?)`,
+			orderID, time.Now(), item.ID, quantity)
+		if res.Error != nil {
+			return false, res.Error
+		}
+		if int(res.RowsAffected) < quantity {
+			shortages = append(shortages, stockShortage{
+				ItemID:    item.ID,
+				Name:      item.Name,
+				Requested: quantity,
+				Available: int(res.RowsAffected),
+			})
+		}
+	}
+
+	if len(shortages) > 0 {
+		return false, &cartError{status: http.StatusConflict, body: gin.H{
+			"error": "Not enough license keys for some items",
+			"items": shortages,
+		}}
+	}
+	return len(items) > 0, nil
+}
+
+// OrderDownloads returns fresh download links for the files of an order of
+// the current user, along with its license keys.
+func (h *DigitalHandler) OrderDownloads(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var order models.Order
+	if err := h.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		}
+		return
+	}
+
+	var grants []models.DownloadGrant
+	if err := h.DB.Where("order_id = ?", order.ID).Order("id").Find(&grants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch downloads"})
+		return
+	}
+	assetIDs := make([]uint, 0, len(grants))
+	for _, g := range grants {
+		assetIDs = append(assetIDs, g.AssetID)
+	}
+	var assets []models.DigitalAsset
+	if len(assetIDs) > 0 {
+		if err := h.DB.Where("id IN (?)", assetIDs).Find(&assets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch downloads"})
+			return
+		}
+	}
+	byID := make(map[uint]models.DigitalAsset, len(assets))
+	for _, a := range assets {
+		byID[a.ID] = a
+	}
+
+	expires := h.Signer.Now().Add(h.LinkTTL).Truncate(time.Second)
+	downloads := make([]DownloadResponse, 0, len(grants))
+	for _, g := range grants {
+		asset, ok := byID[g.AssetID]
+		if !ok {
+			continue
+		}
+		d := DownloadResponse{
+			ItemID:             g.ItemID,
+			FileName:           asset.FileName,
+			Size:               asset.Size,
+			DownloadsRemaining: g.MaxDownloads - g.Downloads,
+		}
+		// Links are only handed out while downloads are left
+		if d.DownloadsRemaining > 0 {
+			d.URL = fmt.Sprintf("/api/downloads/%d?expires=%d&signature=%s",
+				g.ID, expires.Unix(), h.Signer.Sign(downloadSubject(g.ID), expires))
+			d.ExpiresAt = &expires
+		} else {
+			d.DownloadsRemaining = 0
+		}
+		downloads = append(downloads, d)
+	}
+
+	var keys []models.LicenseKey
+	if err := h.DB.Where("order_id = ?", order.ID).Order("item_id, id").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch license keys"})
+		return
+	}
+	licenseKeys := make([]LicenseKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		licenseKeys = append(licenseKeys, LicenseKeyResponse{ItemID: k.ItemID, Key: k.Key})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_id":     order.ID,
+		"downloads":    downloads,
+		"license_keys": licenseKeys,
+	})
+}
+
+// Download streams a file through a signed link. It needs no login; the
+// signature proves the link was handed out to the buyer. Every successful
+// download counts against the grant's limit.
+func (h *DigitalHandler) Download(c *gin.Context) {
+	grantID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid download ID"})
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid download link"})
+		return
+	}
+	if err := h.Signer.Verify(downloadSubject(uint(grantID)), expires, c.Query("signature")); err != nil {
+		if err == signing.ErrExpired {
+			c.JSON(http.StatusGone, gin.H{"error": "Download link has expired"})
+		} else {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid download link"})
+		}
+		return
+	}
+
+	var grant models.DownloadGrant
+	if err := h.DB.First(&grant, grantID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Download not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch download"})
+		}
+		return
+	}
+	var asset models.DigitalAsset
+	if err := h.DB.First(&asset, grant.AssetID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File is no longer available"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file"})
+		}
+		return
+	}
+
+	blob, err := h.Blobs.Open(asset.StorageKey)
+	if err != nil {
+		log.Printf("Failed to open blob %s: %v", asset.StorageKey, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "File is no longer available"})
+		return
+	}
+	defer blob.Close()
+
+	// Conditional so concurrent requests cannot exceed the limit
+	res := h.DB.Exec("UPDATE download_grants SET downloads = downloads + 1, updated_at = ? WHERE id = ? AND downloads < max_downloads",
+		time.Now(), grant.ID)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record download"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusGone, gin.H{"error": "Download limit reached", "max_downloads": grant.MaxDownloads})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": asset.FileName}))
+	c.Header("Cache-Control", "private, no-store")
+	c.DataFromReader(http.StatusOK, asset.Size, asset.ContentType, blob, nil)
+}
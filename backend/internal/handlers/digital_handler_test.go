@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+	"ecommerce-app/internal/signing"
+	"ecommerce-app/internal/storage"
+)
+
+// newDigitalTestHandler returns a handler with a digital item of one file
+// and a router serving downloads, the way the server mounts them.
+func newDigitalTestHandler(t *testing.T) (*DigitalHandler, *gin.Engine, models.Item) {
+	t.Helper()
+	db := newCartTestDB(t, &models.Order{}, &models.DigitalAsset{}, &models.DownloadGrant{}, &models.LicenseKey{})
+	blobs, err := storage.NewLocalBlobStore(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("blob store: %v", err)
+	}
+	h := NewDigitalHandler(db, blobs, signing.NewSigner([]byte("secret")))
+
+	item := models.Item{SKU: "SKU-1", Name: "E-book", Price: money.New(1000, "USD"),
+		Status: models.ItemStatusAvailable, Digital: true}
+	if err := db.Create(&item).Error; err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	if err := blobs.Put("items/1/abc/book.pdf", strings.NewReader("%PDF")); err != nil {
+		t.Fatalf("put blob: %v", err)
+	}
+	asset := models.DigitalAsset{ItemID: item.ID, FileName: "book.pdf", StorageKey: "items/1/abc/book.pdf",
+		ContentType: "application/pdf", Size: 4}
+	if err := db.Create(&asset).Error; err != nil {
+		t.Fatalf("create asset: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/downloads/:id", h.Download)
+	r.GET("/api/orders/:id/downloads", func(c *gin.Context) {
+		var userID uint
+		if _, err := fmt.Sscan(c.GetHeader("X-Test-User"), &userID); err == nil {
+			c.Set("userID", userID)
+		}
+		h.OrderDownloads(c)
+	})
+	return h, r, item
+}
+
+// createDigitalOrder orders item for userID and grants its downloads.
+func createDigitalOrder(t *testing.T, db *gorm.DB, userID uint, item models.Item) models.Order {
+	t.Helper()
+	order := models.Order{UserID: userID, CartID: 1, Status: "completed"}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	if _, err := fulfillDigitalItems(db, order.ID, []models.OrderItem{{ItemID: item.ID, Quantity: 1}}); err != nil {
+		t.Fatalf("fulfill order: %v", err)
+	}
+	return order
+}
+
+// orderDownloads returns the downloads of an order as userID sees them.
+func orderDownloads(t *testing.T, r http.Handler, userID uint, orderID uint) []DownloadResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/orders/%d/downloads", orderID), nil)
+	req.Header.Set("X-Test-User", fmt.Sprint(userID))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("order downloads: status %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Downloads []DownloadResponse `json:"downloads"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode downloads: %v", err)
+	}
+	return body.Downloads
+}
+
+func TestDownloadLimit(t *testing.T) {
+	h, r, item := newDigitalTestHandler(t)
+	order := createDigitalOrder(t, h.DB, 7, item)
+
+	downloads := orderDownloads(t, r, 7, order.ID)
+	if len(downloads) != 1 || downloads[0].URL == "" || downloads[0].DownloadsRemaining != maxDownloadsPerFile {
+		t.Fatalf("downloads %+v, want one link with %d downloads", downloads, maxDownloadsPerFile)
+	}
+	link := downloads[0].URL
+
+	for i := 1; i <= maxDownloadsPerFile; i++ {
+		w := get(r, link)
+		if w.Code != http.StatusOK || w.Body.String() != "%PDF" {
+			t.Fatalf("download %d: status %d %q, want the file", i, w.Code, w.Body)
+		}
+		if got := w.Header().Get("Content-Disposition"); got != `attachment; filename=book.pdf` {
+			t.Errorf("download %d: Content-Disposition %q", i, got)
+		}
+	}
+	if w := get(r, link); w.Code != http.StatusGone {
+		t.Errorf("download past the limit: status %d, want 410", w.Code)
+	}
+
+	// Once used up, no new links are handed out
+	downloads = orderDownloads(t, r, 7, order.ID)
+	if len(downloads) != 1 || downloads[0].URL != "" || downloads[0].DownloadsRemaining != 0 {
+		t.Errorf("downloads %+v, want one without a link or downloads left", downloads)
+	}
+}
+
+func TestDownloadLinks(t *testing.T) {
+	h, r, item := newDigitalTestHandler(t)
+	now := time.Now().Truncate(time.Second)
+	h.Signer.Now = func() time.Time { return now }
+	order := createDigitalOrder(t, h.DB, 7, item)
+
+	var grant models.DownloadGrant
+	if err := h.DB.Where("order_id = ?", order.ID).First(&grant).Error; err != nil {
+		t.Fatalf("find grant: %v", err)
+	}
+	link := func(grantID uint, subjectID uint, expires time.Time) string {
+		return fmt.Sprintf("/api/downloads/%d?expires=%d&signature=%s",
+			grantID, expires.Unix(), h.Signer.Sign(downloadSubject(subjectID), expires))
+	}
+	expires := now.Add(time.Hour)
+	tests := []struct {
+		name     string
+		link     string
+		wantCode int
+	}{
+		{"expired", link(grant.ID, grant.ID, now.Add(-time.Second)), http.StatusGone},
+		{"signed for another grant", link(grant.ID, grant.ID+1, expires), http.StatusForbidden},
+		{"changed expiry", link(grant.ID, grant.ID, expires) + "0", http.StatusForbidden},
+		{"no signature", fmt.Sprintf("/api/downloads/%d", grant.ID), http.StatusForbidden},
+		{"valid", link(grant.ID, grant.ID, expires), http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := get(r, tt.link); w.Code != tt.wantCode {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.wantCode)
+		}
+	}
+	// Only the valid link counted
+	if err := h.DB.First(&grant, grant.ID).Error; err != nil {
+		t.Fatalf("find grant: %v", err)
+	}
+	if grant.Downloads != 1 {
+		t.Errorf("%d downloads recorded, want 1", grant.Downloads)
+	}
+
+	// Links are only handed out to the buyer
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/orders/%d/downloads", order.ID), nil)
+	req.Header.Set("X-Test-User", "8")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("downloads of another user's order: status %d, want 404", w.Code)
+	}
+}
+
+func TestDownloadLimitConcurrent(t *testing.T) {
+	const n = 20
+	h, r, item := newDigitalTestHandler(t)
+	order := createDigitalOrder(t, h.DB, 7, item)
+	link := orderDownloads(t, r, 7, order.ID)[0].URL
+
+	codes := make([]int, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			codes[i] = get(r, link).Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	var served, refused int
+	for i, code := range codes {
+		switch code {
+		case http.StatusOK:
+			served++
+		case http.StatusGone:
+			refused++
+		default:
+			t.Errorf("download %d: status %d, want 200 or 410", i, code)
+		}
+	}
+	if served != maxDownloadsPerFile || refused != n-maxDownloadsPerFile {
+		t.Errorf("%d downloads served and %d refused, want %d and %d", served, refused, maxDownloadsPerFile, n-maxDownloadsPerFile)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/catalog"
+	"ecommerce-app/internal/models"
+)
+
+// errInsufficientStock is returned by reserveStock when an item that does not
+// allow backorders has fewer units on hand than requested.
+var errInsufficientStock = errors.New("insufficient stock")
+
+// stockShortage describes a cart line that could not be reserved.
+type stockShortage struct {
+	ItemID    uint   `json:"item_id"`
+	Name      string `json:"name"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// reserveStock takes quantity units of item out of stock inside tx. The
+// decrement is a single conditional UPDATE so concurrent checkouts cannot
+// both take the last unit. When the item allows backorders, whatever is left
+// on hand is reserved and the remainder is returned as backordered. Digital
+// items have no stock to reserve.
+func reserveStock(tx *gorm.DB, item models.Item, quantity int) (backordered int, err error) {
+	if item.Digital {
+		return 0, nil
+	}
+	res := tx.Exec("UPDATE items SET stock = stock - ?, updated_at = ? WHERE id = ? AND stock >= ?",
+		quantity, time.Now(), item.ID, quantity)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		if !item.AllowBackorder {
+			return 0, errInsufficientStock
+		}
+
+		var onHand int
+		if err := tx.Table("items").Where("id = ?", item.ID).Select("stock").Row().Scan(&onHand); err != nil {
+			return 0, err
+		}
+		if onHand < 0 {
+			onHand = 0
+		}
+		if err := tx.Exec("UPDATE items SET stock = 0, updated_at = ? WHERE id = ?", time.Now(), item.ID).Error; err != nil {
+			return 0, err
+		}
+		backordered = quantity - onHand
+	}
+
+	if err := markOutOfStock(tx, item.ID); err != nil {
+		return 0, err
+	}
+	return backordered, nil
+}
+
+// markOutOfStock flips an available item to out_of_stock once its stock is gone.
+func markOutOfStock(tx *gorm.DB, itemID uint) error {
+	res := tx.Exec("UPDATE items SET status = ? WHERE id = ? AND stock <= 0 AND status = ?",
+		models.ItemStatusOutOfStock, itemID, models.ItemStatusAvailable)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+	return catalog.RecordStatusChange(tx, itemID, models.ItemStatusAvailable, models.ItemStatusOutOfStock)
+}
+
+// availableStock returns the units of item that can be reserved right now.
+func availableStock(item models.Item) int {
+	if item.Stock < 0 {
+		return 0
+	}
+	return item.Stock
+}
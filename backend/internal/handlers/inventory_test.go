@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"sync"
+	"testing"
+
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+)
+
+func TestReserveStock(t *testing.T) {
+	tests := []struct {
+		name            string
+		item            models.Item
+		quantity        int
+		wantErr         error
+		wantBackordered int
+		wantStock       int
+		wantStatus      string
+	}{
+		{"in stock", models.Item{Stock: 5}, 3, nil, 0, 2, models.ItemStatusAvailable},
+		{"last units", models.Item{Stock: 2}, 2, nil, 0, 0, models.ItemStatusOutOfStock},
+		{"short", models.Item{Stock: 1}, 3, errInsufficientStock, 0, 1, models.ItemStatusAvailable},
+		{"backordered", models.Item{Stock: 1, AllowBackorder: true}, 3, nil, 2, 0, models.ItemStatusOutOfStock},
+		{"backordered without stock", models.Item{Stock: 0, AllowBackorder: true}, 2, nil, 2, 0, models.ItemStatusOutOfStock},
+		{"digital", models.Item{Digital: true}, 4, nil, 0, 0, models.ItemStatusAvailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newCartTestDB(t, &models.ItemEvent{})
+			item := tt.item
+			item.SKU, item.Name, item.Price, item.Status = "SKU-1", "Widget", money.New(1000, "USD"), models.ItemStatusAvailable
+			if err := db.Create(&item).Error; err != nil {
+				t.Fatalf("create item: %v", err)
+			}
+
+			backordered, err := reserveStock(db, item, tt.quantity)
+			if err != tt.wantErr {
+				t.Fatalf("reserveStock error %v, want %v", err, tt.wantErr)
+			}
+			if backordered != tt.wantBackordered {
+				t.Errorf("backordered %d, want %d", backordered, tt.wantBackordered)
+			}
+
+			var got models.Item
+			if err := db.First(&got, item.ID).Error; err != nil {
+				t.Fatalf("find item: %v", err)
+			}
+			if got.Stock != tt.wantStock || got.Status != tt.wantStatus {
+				t.Errorf("stock %d %s, want %d %s", got.Stock, got.Status, tt.wantStock, tt.wantStatus)
+			}
+
+			// Selling out is an event alerts react to
+			var events int
+			db.Model(&models.ItemEvent{}).Where("item_id = ? AND type = ?", item.ID, models.ItemEventStatusChanged).Count(&events)
+			if want := tt.wantStatus == models.ItemStatusOutOfStock; (events == 1) != want {
+				t.Errorf("%d status_changed events, want one: %v", events, want)
+			}
+		})
+	}
+}
+
+func TestReserveStockConcurrent(t *testing.T) {
+	const n, stock = 30, 10
+	db := newCartTestDB(t, &models.ItemEvent{})
+	item := models.Item{SKU: "SKU-1", Name: "Widget", Price: money.New(1000, "USD"),
+		Status: models.ItemStatusAvailable, Stock: stock}
+	if err := db.Create(&item).Error; err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+
+	errs := make([]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			tx := db.Begin()
+			if _, err := reserveStock(tx, item, 1); err != nil {
+				tx.Rollback()
+				errs[i] = err
+				return
+			}
+			errs[i] = tx.Commit().Error
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	var reserved int
+	for i, err := range errs {
+		switch err {
+		case nil:
+			reserved++
+		case errInsufficientStock:
+		default:
+			t.Errorf("reservation %d: %v", i, err)
+		}
+	}
+	if reserved != stock {
+		t.Errorf("%d reservations succeeded, want %d", reserved, stock)
+	}
+	var got models.Item
+	if err := db.First(&got, item.ID).Error; err != nil {
+		t.Fatalf("find item: %v", err)
+	}
+	if got.Stock != 0 || got.Status != models.ItemStatusOutOfStock {
+		t.Errorf("stock %d %s, want 0 out_of_stock", got.Stock, got.Status)
+	}
+}
@@ -3,23 +3,85 @@ package handlers
 import (
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/catalog"
+	"ecommerce-app/internal/i18n"
 	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+	"ecommerce-app/internal/storage"
 )
 
 type ItemHandler struct {
-	DB *gorm.DB
+	DB    *gorm.DB
+	Blobs storage.BlobStore
+	Cache *catalog.Cache
+	// Locales are the locales items can be translated into.
+	Locales i18n.Locales
 }
 
-func NewItemHandler(db *gorm.DB) *ItemHandler {
-	return &ItemHandler{DB: db}
+func NewItemHandler(db *gorm.DB, blobs storage.BlobStore, cache *catalog.Cache) *ItemHandler {
+	return &ItemHandler{DB: db, Blobs: blobs, Cache: cache, Locales: i18n.ParseLocales("")}
 }
 
+// ItemResponse is the public view of an item used by the listing and detail
+// endpoints. Name and Description are in Locale, which is the requested
+// locale or, when the item has no translation into it, the default one.
+type ItemResponse struct {
+	ID          uint            `json:"id"`
+	SKU         string          `json:"sku,omitempty"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Locale      string          `json:"locale"`
+	Price       money.Money     `json:"price"`
+	Status      string          `json:"status,omitempty"`
+	Stock       int             `json:"stock"`
+	Digital     bool            `json:"digital"`
+	Images      []ImageResponse `json:"images"`
+	// Purchase limits per order, left out when there are none
+	MaxPerOrder      int `json:"max_per_order,omitempty"`
+	MinOrderQuantity int `json:"min_order_quantity,omitempty"`
+	// The publishing fields are only shown in the admin listing
+	Visibility  string     `json:"visibility,omitempty"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time `json:"unpublish_at,omitempty"`
+	Visible     *bool      `json:"visible,omitempty"`
+	// Attributes are the item's specs in attribute display order
+	Attributes []AttributeValueResponse `json:"attributes"`
+	ratingSummary
+}
+
+// CreateItemRequest takes the price either as a decimal in the default
+// currency (999.99) or as {"amount": 99999, "currency": "USD"} in minor units.
+// Items are published right away unless created as drafts or with a window.
 type CreateItemRequest struct {
-	Name  string  `json:"name" binding:"required"`
-	Price float64 `json:"price" binding:"required,gt=0"`
+	SKU            string      `json:"sku"`
+	Name           string      `json:"name" binding:"required"`
+	Description    string      `json:"description"`
+	Price          money.Money `json:"price"`
+	Stock          int         `json:"stock" binding:"gte=0"`
+	AllowBackorder bool        `json:"allow_backorder"`
+	Digital        bool        `json:"digital"`
+	Visibility     string      `json:"visibility"`
+	PublishAt      *time.Time  `json:"publish_at"`
+	UnpublishAt    *time.Time  `json:"unpublish_at"`
+
+	MaxPerOrder      int `json:"max_per_order" binding:"gte=0"`
+	MinOrderQuantity int `json:"min_order_quantity" binding:"gte=0"`
+}
+
+type UpdateStockRequest struct {
+	Stock          *int  `json:"stock" binding:"required,gte=0"`
+	AllowBackorder *bool `json:"allow_backorder"`
+}
+
+// UpdateLimitsRequest sets the purchase limits of an item; zero removes one.
+type UpdateLimitsRequest struct {
+	MaxPerOrder      int `json:"max_per_order" binding:"gte=0"`
+	MinOrderQuantity int `json:"min_order_quantity" binding:"gte=0"`
 }
 
 func (h *ItemHandler) CreateItem(c *gin.Context) {
@@ -28,68 +90,342 @@ func (h *ItemHandler) CreateItem(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.Price.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "price must be greater than 0"})
+		return
+	}
+	if req.Visibility == "" {
+		req.Visibility = models.ItemVisibilityPublished
+	}
+	if err := checkPublishing(req.Visibility, req.PublishAt, req.UnpublishAt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.MaxPerOrder > 0 && req.MinOrderQuantity > req.MaxPerOrder {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_order_quantity must not exceed max_per_order"})
+		return
+	}
+
+	// Items created without a SKU get a generated one
+	sku := strings.TrimSpace(req.SKU)
+	if sku == "" {
+		suffix, err := randomHex(4)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate SKU"})
+			return
+		}
+		sku = "SKU-" + strings.ToUpper(suffix)
+	}
+
+	var existing models.Item
+	err := h.DB.Where("sku = ? OR normalized_name = ?", sku, models.NormalizeItemName(req.Name)).First(&existing).Error
+	if err == nil {
+		respondItemConflict(c, existing)
+		return
+	}
+	if err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing items"})
+		return
+	}
 
 	item := models.Item{
-		Name:  req.Name,
-		Price: req.Price,
+		SKU:            sku,
+		Name:           strings.TrimSpace(req.Name),
+		Description:    strings.TrimSpace(req.Description),
+		Price:          req.Price,
+		Status:         models.ItemStatusAvailable,
+		Stock:          req.Stock,
+		AllowBackorder: req.AllowBackorder,
+		Digital:        req.Digital,
+		Visibility:     req.Visibility,
+		PublishAt:      req.PublishAt,
+		UnpublishAt:    req.UnpublishAt,
+
+		MaxPerOrder:      req.MaxPerOrder,
+		MinOrderQuantity: req.MinOrderQuantity,
+	}
+	if item.Stock == 0 && !item.Digital {
+		item.Status = models.ItemStatusOutOfStock
+	}
+
+	var createdBy *uint
+	if userID, ok := userIDFromContext(c); ok {
+		createdBy = &userID
 	}
 
-	if err := h.DB.Create(&item).Error; err != nil {
+	tx := h.DB.Begin()
+	if err := tx.Create(&item).Error; err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			// Another request created the same item after the check above
+			c.JSON(http.StatusConflict, gin.H{"error": "An item with this SKU or name already exists"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create item"})
 		return
 	}
+	if err := catalog.RecordInitialPrice(tx, &item, models.PriceSourceManual, createdBy); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create item"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create item"})
+		return
+	}
+	h.Cache.Invalidate()
 
 	c.JSON(http.StatusCreated, item)
 }
 
+// respondItemConflict reports that an item with the same SKU or name exists.
+func respondItemConflict(c *gin.Context, existing models.Item) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "An item with this SKU or name already exists",
+		"item_id": existing.ID,
+		"sku":     existing.SKU,
+		"name":    existing.Name,
+	})
+}
+
+// isUniqueViolation reports whether err was caused by a UNIQUE constraint.
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// UpdateStock sets the quantity on hand for an item. Restocking an item that
+// ran out makes it available again; setting stock to zero takes it off sale.
+func (h *ItemHandler) UpdateStock(c *gin.Context) {
+	var req UpdateStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var item models.Item
+	if err := h.DB.First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+
+	updates := map[string]interface{}{"stock": *req.Stock}
+	if req.AllowBackorder != nil {
+		updates["allow_backorder"] = *req.AllowBackorder
+	}
+	switch {
+	case item.Digital:
+		// Digital items have no stock to run out of
+	case *req.Stock > 0 && item.Status == models.ItemStatusOutOfStock:
+		updates["status"] = models.ItemStatusAvailable
+	case *req.Stock == 0 && item.Status == models.ItemStatusAvailable:
+		updates["status"] = models.ItemStatusOutOfStock
+	}
+
+	tx := h.DB.Begin()
+	oldStatus := item.Status
+	if err := tx.Model(&item).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
+		return
+	}
+	// Restocking fires back-in-stock alerts
+	if err := catalog.RecordStatusChange(tx, item.ID, oldStatus, item.Status); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
+		return
+	}
+	h.Cache.Invalidate()
+
+	log.Printf("Stock for item %d set to %d (status: %s)", item.ID, item.Stock, item.Status)
+	c.JSON(http.StatusOK, item)
+}
+
+// UpdateLimits sets how many units of an item one order may contain. Carts
+// already over a new limit are only held to it when they change.
+func (h *ItemHandler) UpdateLimits(c *gin.Context) {
+	var req UpdateLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if req.MaxPerOrder > 0 && req.MinOrderQuantity > req.MaxPerOrder {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_order_quantity must not exceed max_per_order"})
+		return
+	}
+
+	var item models.Item
+	if err := h.DB.First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+
+	// Updating from a struct would skip zero limits
+	updates := map[string]interface{}{
+		"max_per_order":      req.MaxPerOrder,
+		"min_order_quantity": req.MinOrderQuantity,
+	}
+	if err := h.DB.Model(&item).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update limits"})
+		return
+	}
+	h.Cache.Invalidate()
+
+	c.JSON(http.StatusOK, item)
+}
+
+// GetItem returns a single item with its ordered images, in the locale
+// picked by the Locale middleware. Items shoppers cannot see are not found.
+func (h *ItemHandler) GetItem(c *gin.Context) {
+	var item models.Item
+	if err := catalog.Visible(h.DB, time.Now()).First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+
+	images, err := h.loadImages([]uint{item.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item images"})
+		return
+	}
+	ratings, err := loadRatings(h.DB, []uint{item.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item ratings"})
+		return
+	}
+	attributes, err := loadAttributeValues(h.DB, []uint{item.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item attributes"})
+		return
+	}
+	locale, defaultLocale := localeFromContext(c)
+	translations, err := loadTranslations(h.DB, []uint{item.ID}, locale)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item translations"})
+		return
+	}
+
+	response := ItemResponse{
+		ID:      item.ID,
+		SKU:     item.SKU,
+		Price:   item.Price,
+		Status:  item.Status,
+		Stock:   item.Stock,
+		Digital: item.Digital,
+		Images:  append([]ImageResponse{}, images[item.ID]...),
+
+		MaxPerOrder:      item.MaxPerOrder,
+		MinOrderQuantity: item.MinOrderQuantity,
+
+		Attributes:    append([]AttributeValueResponse{}, attributes[item.ID]...),
+		ratingSummary: ratings[item.ID],
+	}
+	localize(&response, item, translations, defaultLocale)
+	c.JSON(http.StatusOK, response)
+}
+
+// ListItems returns the catalog. It takes the same filter[code]=value
+// parameters as the facets endpoint and is localized like GetItem. Items
+// shoppers cannot see are left out. Responses are cached by the
+// CatalogCache middleware, so this only runs after the catalog changed.
 func (h *ItemHandler) ListItems(c *gin.Context) {
-	type ItemResponse struct {
-		ID     uint    `json:"id"`
-		Name   string  `json:"name"`
-		Price  float64 `json:"price"`
-		Status string  `json:"status,omitempty"`
+	h.listItems(c, false)
+}
+
+// AdminListItems is ListItems for admins. With ?preview=true it includes
+// drafts and items outside their publish window, with their publishing
+// fields, so launches can be checked before they go live.
+func (h *ItemHandler) AdminListItems(c *gin.Context) {
+	h.listItems(c, c.Query("preview") == "true")
+}
+
+func (h *ItemHandler) listItems(c *gin.Context, preview bool) {
+	_, filters, ok := attributeFilters(c, h.DB)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	query := h.DB
+	if !preview {
+		query = catalog.Visible(query, now)
 	}
 
 	var items []models.Item
-	// First, get all items from the database
-	if err := h.DB.Find(&items).Error; err != nil {
+	// First, get all matching items from the database
+	if err := catalog.ApplyAttributeFilters(query, filters).Order("id").Find(&items).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch items"})
 		return
 	}
 
-	// Log the items being returned for debugging
-	log.Printf("Found %d items in database", len(items))
+	// Names and SKUs are unique in the database, so no deduplication is needed here
+	itemIDs := make([]uint, 0, len(items))
 	for _, item := range items {
-		log.Printf("Item - ID: %d, Name: %s, Price: %.2f, Status: %s", 
-			item.ID, item.Name, item.Price, item.Status)
+		itemIDs = append(itemIDs, item.ID)
+	}
+	images, err := h.loadImages(itemIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item images"})
+		return
+	}
+	ratings, err := loadRatings(h.DB, itemIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item ratings"})
+		return
+	}
+	attributes, err := loadAttributeValues(h.DB, itemIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item attributes"})
+		return
+	}
+	locale, defaultLocale := localeFromContext(c)
+	translations, err := loadTranslations(h.DB, itemIDs, locale)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item translations"})
+		return
 	}
 
-	// Use a map to ensure unique items by name (case-insensitive)
-	uniqueItems := make(map[string]ItemResponse)
-	
-	// Process items, keeping only the first occurrence of each item name
+	response := make([]ItemResponse, 0, len(items))
 	for _, item := range items {
-		// Use lowercase name as the key for case-insensitive comparison
-		nameKey := item.Name
-		if _, exists := uniqueItems[nameKey]; !exists {
-			uniqueItems[nameKey] = ItemResponse{
-				ID:     item.ID,
-				Name:   item.Name,
-				Price:  item.Price,
-				Status: item.Status,
-			}
-		} else {
-			log.Printf("Duplicate item found - ID: %d, Name: %s (keeping first occurrence)", 
-				item.ID, item.Name)
-		}
-	}
+		resp := ItemResponse{
+			ID:      item.ID,
+			SKU:     item.SKU,
+			Price:   item.Price,
+			Status:  item.Status,
+			Stock:   item.Stock,
+			Digital: item.Digital,
+			Images:  append([]ImageResponse{}, images[item.ID]...),
 
-	// Convert map values to slice
-	response := make([]ItemResponse, 0, len(uniqueItems))
-	for _, item := range uniqueItems {
-		response = append(response, item)
+			MaxPerOrder:      item.MaxPerOrder,
+			MinOrderQuantity: item.MinOrderQuantity,
+
+			Attributes:    append([]AttributeValueResponse{}, attributes[item.ID]...),
+			ratingSummary: ratings[item.ID],
+		}
+		localize(&resp, item, translations, defaultLocale)
+		if preview {
+			visible := item.Visible(now)
+			resp.Visibility, resp.PublishAt, resp.UnpublishAt, resp.Visible = item.Visibility, item.PublishAt, item.UnpublishAt, &visible
+		}
+		response = append(response, resp)
 	}
 
-	log.Printf("Returning %d unique items in response", len(response))
+	log.Printf("Returning %d items in response", len(response))
 	c.JSON(http.StatusOK, response)
 }
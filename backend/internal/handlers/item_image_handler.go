@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/catalog"
+	"ecommerce-app/internal/imaging"
+	"ecommerce-app/internal/models"
+)
+
+const (
+	// maxImageSize is the largest original accepted per uploaded file.
+	maxImageSize = 10 << 20
+	// maxImagePixels bounds the decoded size of an image: a small file can
+	// declare dimensions that take gigabytes to decode.
+	maxImagePixels = 40_000_000
+	// thumbnailSize is the longest side, in pixels, of generated thumbnails.
+	thumbnailSize = 300
+)
+
+// ImageResponse is how an item image is exposed to clients.
+type ImageResponse struct {
+	ID           uint   `json:"id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	Position     int    `json:"position"`
+}
+
+func (h *ItemHandler) imageResponse(img models.ItemImage) ImageResponse {
+	return ImageResponse{
+		ID:           img.ID,
+		URL:          h.Blobs.URL(img.StorageKey),
+		ThumbnailURL: h.Blobs.URL(img.ThumbnailKey),
+		Position:     img.Position,
+	}
+}
+
+// loadImages returns the ordered images of the given items keyed by item ID.
+func (h *ItemHandler) loadImages(itemIDs []uint) (map[uint][]ImageResponse, error) {
+	result := make(map[uint][]ImageResponse)
+	if len(itemIDs) == 0 {
+		return result, nil
+	}
+
+	var images []models.ItemImage
+	if err := h.DB.Where("item_id IN (?)", itemIDs).
+		Order("item_id, position, id").
+		Find(&images).Error; err != nil {
+		return nil, err
+	}
+	for _, img := range images {
+		result[img.ItemID] = append(result[img.ItemID], h.imageResponse(img))
+	}
+	return result, nil
+}
+
+// UploadImages accepts one or more multipart files in the "images" field,
+// stores the originals and a generated thumbnail for each, and appends them
+// to the item's image list.
+func (h *ItemHandler) UploadImages(c *gin.Context) {
+	var item models.Item
+	if err := h.DB.First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart form", "details": err.Error()})
+		return
+	}
+	files := form.File["images"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No files uploaded in the images field"})
+		return
+	}
+
+	// New images go after the existing ones
+	var position int
+	if err := h.DB.Model(&models.ItemImage{}).
+		Where("item_id = ?", item.ID).
+		Select("COALESCE(MAX(position) + 1, 0)").
+		Row().Scan(&position); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item images"})
+		return
+	}
+
+	uploaded := make([]ImageResponse, 0, len(files))
+	for _, fh := range files {
+		img, err := h.storeImage(item.ID, position, fh)
+		if err != nil {
+			log.Printf("Error storing image %q for item %d: %v", fh.Filename, item.ID, err)
+			if len(uploaded) > 0 {
+				h.imagesChanged(item.ID)
+			}
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":    "Failed to store image",
+				"filename": fh.Filename,
+				"details":  err.Error(),
+				"uploaded": uploaded,
+			})
+			return
+		}
+		uploaded = append(uploaded, h.imageResponse(*img))
+		position++
+	}
+	h.imagesChanged(item.ID)
+
+	c.JSON(http.StatusCreated, gin.H{"images": uploaded})
+}
+
+// storeImage decodes an uploaded file, writes the original and its thumbnail
+// to the blob store and records them.
+func (h *ItemHandler) storeImage(itemID uint, position int, fh *multipart.FileHeader) (*models.ItemImage, error) {
+	if fh.Size > maxImageSize {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxImageSize)
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageSize {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxImageSize)
+	}
+
+	cfg, _, err := imaging.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %v", err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return nil, fmt.Errorf("image is %dx%d, more than %d pixels", cfg.Width, cfg.Height, maxImagePixels)
+	}
+
+	src, format, err := imaging.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %v", err)
+	}
+
+	var thumb bytes.Buffer
+	if err := imaging.Encode(&thumb, imaging.Thumbnail(src, thumbnailSize), format); err != nil {
+		return nil, fmt.Errorf("failed to generate thumbnail: %v", err)
+	}
+
+	name, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	ext := format
+	if ext == "jpeg" {
+		ext = "jpg"
+	}
+
+	img := &models.ItemImage{
+		ItemID:       itemID,
+		Position:     position,
+		StorageKey:   fmt.Sprintf("items/%d/%s.%s", itemID, name, ext),
+		ThumbnailKey: fmt.Sprintf("items/%d/%s_thumb.%s", itemID, name, ext),
+		ContentType:  imaging.ContentType(format),
+		Width:        src.Bounds().Dx(),
+		Height:       src.Bounds().Dy(),
+	}
+
+	if err := h.Blobs.Put(img.StorageKey, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if err := h.Blobs.Put(img.ThumbnailKey, &thumb); err != nil {
+		h.Blobs.Delete(img.StorageKey)
+		return nil, err
+	}
+	if err := h.DB.Create(img).Error; err != nil {
+		h.Blobs.Delete(img.StorageKey)
+		h.Blobs.Delete(img.ThumbnailKey)
+		return nil, err
+	}
+	return img, nil
+}
+
+// DeleteImage removes an image record and its files.
+func (h *ItemHandler) DeleteImage(c *gin.Context) {
+	var img models.ItemImage
+	if err := h.DB.Where("id = ? AND item_id = ?", c.Param("imageID"), c.Param("id")).
+		First(&img).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch image"})
+		}
+		return
+	}
+
+	if err := h.DB.Delete(&img).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+		return
+	}
+
+	h.imagesChanged(img.ItemID)
+
+	// The record is gone, so a leftover file is only wasted space
+	for _, key := range []string{img.StorageKey, img.ThumbnailKey} {
+		if err := h.Blobs.Delete(key); err != nil {
+			log.Printf("Error deleting blob %s: %v", key, err)
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// imagesChanged marks the item as modified after its images changed, so
+// cached catalog responses are not served with the old images.
+func (h *ItemHandler) imagesChanged(itemID uint) {
+	if err := catalog.TouchItems(h.DB, itemID); err != nil {
+		log.Printf("Error touching item %d: %v", itemID, err)
+	}
+	h.Cache.Invalidate()
+}
+
+// randomHex returns n random bytes encoded as hex.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
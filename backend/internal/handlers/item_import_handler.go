@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/catalog"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+)
+
+// catalogColumns is the column order used by the CSV export. The import
+// accepts the same columns in any order; sku, name and price are required.
+var catalogColumns = []string{"sku", "name", "price", "currency", "status", "stock", "allow_backorder", "description"}
+
+// catalogRow is one item in an import or export file, in the default locale.
+// Stock, AllowBackorder and Description are optional on import and keep
+// their current values when left out for an existing SKU. In CSV files the price is a decimal with a
+// separate currency column, which defaults to money.DefaultCurrency.
+type catalogRow struct {
+	SKU            string      `json:"sku"`
+	Name           string      `json:"name"`
+	Price          money.Money `json:"price"`
+	Status         string      `json:"status,omitempty"`
+	Stock          *int        `json:"stock,omitempty"`
+	AllowBackorder *bool       `json:"allow_backorder,omitempty"`
+	Description    *string     `json:"description,omitempty"`
+}
+
+// importRowError reports why a row was rejected. Row is the 1-based position
+// of the row in the file, not counting the CSV header.
+type importRowError struct {
+	Row   int    `json:"row"`
+	SKU   string `json:"sku,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportItems upserts items by SKU from a CSV or JSON body. The whole file
+// is applied in one transaction, so any invalid row leaves the catalog
+// untouched. With ?dry_run=true the rows are validated and applied, then
+// rolled back, which reports every error without changing anything.
+func (h *ItemHandler) ImportItems(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	format := catalogFormat(c)
+	var rows []catalogRow
+	var rowErrors []importRowError
+	var err error
+	switch format {
+	case "csv":
+		rows, rowErrors, err = parseCatalogCSV(c.Request.Body)
+	case "json":
+		rows, rowErrors, err = parseCatalogJSON(c.Request.Body)
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Import must be text/csv or application/json"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import file", "details": err.Error()})
+		return
+	}
+
+	var changedBy *uint
+	if userID, ok := userIDFromContext(c); ok {
+		changedBy = &userID
+	}
+
+	tx := h.DB.Begin()
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+
+	created, updated := 0, 0
+	for i, row := range rows {
+		if row.SKU == "" {
+			// Rows that failed to parse are already in rowErrors
+			continue
+		}
+		isNew, err := upsertCatalogRow(tx, row, changedBy)
+		if err != nil {
+			rowErrors = append(rowErrors, importRowError{Row: i + 1, SKU: row.SKU, Error: err.Error()})
+			continue
+		}
+		if isNew {
+			created++
+		} else {
+			updated++
+		}
+	}
+
+	report := gin.H{
+		"dry_run": dryRun,
+		"rows":    len(rows),
+		"created": created,
+		"updated": updated,
+		"errors":  rowErrors,
+	}
+	if rowErrors == nil {
+		report["errors"] = []importRowError{}
+	}
+
+	if dryRun || len(rowErrors) > 0 {
+		tx.Rollback()
+		status := http.StatusOK
+		if !dryRun {
+			status = http.StatusUnprocessableEntity
+			report["created"], report["updated"] = 0, 0
+		}
+		c.JSON(status, report)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import items", "details": err.Error()})
+		return
+	}
+
+	h.Cache.Invalidate()
+	log.Printf("Catalog import: %d rows, %d created, %d updated", len(rows), created, updated)
+	c.JSON(http.StatusOK, report)
+}
+
+// upsertCatalogRow validates row and creates or updates the item with its SKU.
+func upsertCatalogRow(tx *gorm.DB, row catalogRow, changedBy *uint) (created bool, err error) {
+	if strings.TrimSpace(row.Name) == "" {
+		return false, fmt.Errorf("name is required")
+	}
+	if row.Price.Amount <= 0 {
+		return false, fmt.Errorf("price must be greater than 0")
+	}
+	if row.Stock != nil && *row.Stock < 0 {
+		return false, fmt.Errorf("stock must not be negative")
+	}
+	switch row.Status {
+	case "", models.ItemStatusAvailable, models.ItemStatusOutOfStock:
+	default:
+		return false, fmt.Errorf("unknown status %q", row.Status)
+	}
+
+	var item models.Item
+	err = tx.Where("sku = ?", row.SKU).First(&item).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return false, err
+	}
+	created = err == gorm.ErrRecordNotFound
+
+	// Names are unique too, so a row may not take the name of another SKU
+	var other models.Item
+	err = tx.Where("normalized_name = ? AND sku <> ?", models.NormalizeItemName(row.Name), row.SKU).First(&other).Error
+	if err == nil {
+		return false, fmt.Errorf("name %q is already used by SKU %s", other.Name, other.SKU)
+	}
+	if err != gorm.ErrRecordNotFound {
+		return false, err
+	}
+
+	oldStatus := item.Status
+	item.SKU = row.SKU
+	item.Name = strings.TrimSpace(row.Name)
+	if created {
+		item.Price = row.Price
+	} else if err := catalog.SetPrice(tx, &item, row.Price, models.PriceSourceImport, changedBy); err != nil {
+		return false, err
+	}
+	if row.Stock != nil {
+		item.Stock = *row.Stock
+	}
+	if row.AllowBackorder != nil {
+		item.AllowBackorder = *row.AllowBackorder
+	}
+	if row.Description != nil {
+		item.Description = strings.TrimSpace(*row.Description)
+	}
+	item.Status = row.Status
+	if item.Status == "" {
+		item.Status = models.ItemStatusAvailable
+		if item.Stock <= 0 {
+			item.Status = models.ItemStatusOutOfStock
+		}
+	}
+
+	if created {
+		if err := tx.Create(&item).Error; err != nil {
+			return false, err
+		}
+		return true, catalog.RecordInitialPrice(tx, &item, models.PriceSourceImport, changedBy)
+	}
+	if err := tx.Save(&item).Error; err != nil {
+		return false, err
+	}
+	return false, catalog.RecordStatusChange(tx, item.ID, oldStatus, item.Status)
+}
+
+// ExportItems streams the catalog as CSV or JSON in the import format, so
+// the file can be edited in a spreadsheet and imported back.
+func (h *ItemHandler) ExportItems(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+		return
+	}
+
+	rows, err := h.DB.Model(&models.Item{}).Order("id").Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch items"})
+		return
+	}
+	defer rows.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=items.%s", format))
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+	}
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure from here on can only be logged
+	// and the response cut short
+	var count int
+	var writeErr error
+	switch format {
+	case "csv":
+		w := csv.NewWriter(c.Writer)
+		writeErr = w.Write(catalogColumns)
+		for writeErr == nil && rows.Next() {
+			var item models.Item
+			if writeErr = h.DB.ScanRows(rows, &item); writeErr != nil {
+				break
+			}
+			writeErr = w.Write([]string{
+				item.SKU,
+				item.Name,
+				item.Price.Decimal(),
+				item.Price.Currency,
+				item.Status,
+				strconv.Itoa(item.Stock),
+				strconv.FormatBool(item.AllowBackorder),
+				item.Description,
+			})
+			count++
+			if count%100 == 0 {
+				w.Flush()
+				writeErr = w.Error()
+			}
+		}
+		w.Flush()
+		if writeErr == nil {
+			writeErr = w.Error()
+		}
+	case "json":
+		enc := json.NewEncoder(c.Writer)
+		_, writeErr = io.WriteString(c.Writer, "[")
+		for writeErr == nil && rows.Next() {
+			var item models.Item
+			if writeErr = h.DB.ScanRows(rows, &item); writeErr != nil {
+				break
+			}
+			if count > 0 {
+				if _, writeErr = io.WriteString(c.Writer, ","); writeErr != nil {
+					break
+				}
+			}
+			writeErr = enc.Encode(catalogRow{
+				SKU:            item.SKU,
+				Name:           item.Name,
+				Price:          item.Price,
+				Status:         item.Status,
+				Stock:          &item.Stock,
+				AllowBackorder: &item.AllowBackorder,
+				Description:    &item.Description,
+			})
+			count++
+		}
+		if writeErr == nil {
+			_, writeErr = io.WriteString(c.Writer, "]\n")
+		}
+	}
+	if writeErr == nil {
+		writeErr = rows.Err()
+	}
+
+	if writeErr != nil {
+		log.Printf("Catalog export aborted after %d items: %v", count, writeErr)
+		return
+	}
+	log.Printf("Catalog export: %d items as %s", count, format)
+}
+
+// catalogFormat picks the import format from ?format= or the Content-Type.
+func catalogFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	switch c.ContentType() {
+	case "text/csv", "application/csv":
+		return "csv"
+	case "application/json":
+		return "json"
+	}
+	return ""
+}
+
+// parseCatalogCSV reads rows keyed by the header line. Rows that cannot be
+// parsed are reported in the returned errors and left zero in rows, so row
+// numbers stay aligned with the file.
+func parseCatalogCSV(r io.Reader) ([]catalogRow, []importRowError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("file is empty")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"sku", "name", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("missing required column %q", required)
+		}
+	}
+	// Records may be shorter than the header in hand-edited files
+	reader.FieldsPerRecord = -1
+
+	var rows []catalogRow
+	var rowErrors []importRowError
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row, err := catalogRowFromFields(field)
+		if err != nil {
+			rowErrors = append(rowErrors, importRowError{Row: n, SKU: field("sku"), Error: err.Error()})
+			row = catalogRow{}
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+func catalogRowFromFields(field func(string) string) (catalogRow, error) {
+	row := catalogRow{
+		SKU:    field("sku"),
+		Name:   field("name"),
+		Status: field("status"),
+	}
+	if row.SKU == "" {
+		return row, fmt.Errorf("sku is required")
+	}
+
+	price, err := money.Parse(field("price"), field("currency"))
+	if err != nil {
+		return row, fmt.Errorf("invalid price: %v", err)
+	}
+	row.Price = price
+
+	if v := field("stock"); v != "" {
+		stock, err := strconv.Atoi(v)
+		if err != nil {
+			return row, fmt.Errorf("invalid stock %q", v)
+		}
+		row.Stock = &stock
+	}
+	if v := field("allow_backorder"); v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			return row, fmt.Errorf("invalid allow_backorder %q", v)
+		}
+		row.AllowBackorder = &allow
+	}
+	// Like the other optional columns, an empty cell keeps the current value
+	if v := field("description"); v != "" {
+		row.Description = &v
+	}
+	return row, nil
+}
+
+// parseCatalogJSON reads an array of rows. Each element is decoded on its own
+// so a bad value only rejects that row.
+func parseCatalogJSON(r io.Reader) ([]catalogRow, []importRowError, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+
+	rows := make([]catalogRow, len(raw))
+	var rowErrors []importRowError
+	for i, msg := range raw {
+		var row catalogRow
+		if err := json.Unmarshal(msg, &row); err != nil {
+			rowErrors = append(rowErrors, importRowError{Row: i + 1, Error: err.Error()})
+			continue
+		}
+		row.SKU = strings.TrimSpace(row.SKU)
+		if row.SKU == "" {
+			rowErrors = append(rowErrors, importRowError{Row: i + 1, Error: "sku is required"})
+			continue
+		}
+		rows[i] = row
+	}
+	return rows, rowErrors, nil
+}
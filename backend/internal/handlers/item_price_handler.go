@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/catalog"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+)
+
+type UpdatePriceRequest struct {
+	Price money.Money `json:"price"`
+}
+
+type SchedulePriceRequest struct {
+	Price    money.Money `json:"price"`
+	StartsAt time.Time   `json:"starts_at" binding:"required"`
+	EndsAt   *time.Time  `json:"ends_at"`
+}
+
+// ListPrices returns the current price of an item, its full price history
+// (newest first) and any scheduled prices that have not finished yet. It is
+// for admins: it shows prices before they take effect and who set them.
+func (h *ItemHandler) ListPrices(c *gin.Context) {
+	var item models.Item
+	if err := h.DB.First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+
+	history := []models.PriceChange{}
+	if err := h.DB.Where("item_id = ?", item.ID).Order("changed_at DESC, id DESC").Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history"})
+		return
+	}
+
+	scheduled := []models.ScheduledPrice{}
+	if err := h.DB.Where("item_id = ? AND status IN (?)", item.ID,
+		[]string{models.ScheduledPricePending, models.ScheduledPriceActive}).
+		Order("starts_at, id").Find(&scheduled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduled prices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"item_id":   item.ID,
+		"price":     item.Price,
+		"history":   history,
+		"scheduled": scheduled,
+	})
+}
+
+// UpdatePrice sets the price of an item right away.
+func (h *ItemHandler) UpdatePrice(c *gin.Context) {
+	var req UpdatePriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Price.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "price must be greater than 0"})
+		return
+	}
+
+	tx := h.DB.Begin()
+	var item models.Item
+	if err := tx.First(&item, c.Param("id")).Error; err != nil {
+		tx.Rollback()
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+
+	var changedBy *uint
+	if userID, ok := userIDFromContext(c); ok {
+		changedBy = &userID
+	}
+	if err := catalog.SetPrice(tx, &item, req.Price, models.PriceSourceManual, changedBy); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update price", "details": err.Error()})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update price", "details": err.Error()})
+		return
+	}
+
+	log.Printf("Price of item %d set to %s", item.ID, item.Price)
+	h.Cache.Invalidate()
+	c.JSON(http.StatusOK, item)
+}
+
+// SchedulePrice plans a price for an item between starts_at and the optional
+// ends_at. The background price scheduler applies and reverts it.
+func (h *ItemHandler) SchedulePrice(c *gin.Context) {
+	var req SchedulePriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Price.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "price must be greater than 0"})
+		return
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(req.StartsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at"})
+		return
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be in the future"})
+		return
+	}
+
+	var item models.Item
+	if err := h.DB.First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+	// Switching currency for a while would leave carts with mixed currencies
+	price := money.New(req.Price.Amount, req.Price.Currency)
+	if price.Currency != item.Price.Currency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Price must be in " + item.Price.Currency})
+		return
+	}
+
+	scheduled := models.ScheduledPrice{
+		ItemID:   item.ID,
+		Price:    price,
+		StartsAt: req.StartsAt,
+		EndsAt:   req.EndsAt,
+		Status:   models.ScheduledPricePending,
+	}
+	if userID, ok := userIDFromContext(c); ok {
+		scheduled.CreatedBy = &userID
+	}
+	if err := h.DB.Create(&scheduled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule price", "details": err.Error()})
+		return
+	}
+
+	log.Printf("Scheduled price %s for item %d from %s", scheduled.Price, item.ID, scheduled.StartsAt)
+	c.JSON(http.StatusCreated, scheduled)
+}
+
+// CancelScheduledPrice drops a pending scheduled price, or stops an active
+// one and restores the price from before it started.
+func (h *ItemHandler) CancelScheduledPrice(c *gin.Context) {
+	tx := h.DB.Begin()
+	var scheduled models.ScheduledPrice
+	if err := tx.Where("id = ? AND item_id = ?", c.Param("scheduleID"), c.Param("id")).
+		First(&scheduled).Error; err != nil {
+		tx.Rollback()
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled price not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduled price"})
+		}
+		return
+	}
+
+	if err := catalog.CancelScheduledPrice(tx, scheduled); err != nil {
+		tx.Rollback()
+		if err == catalog.ErrScheduleNotActive {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": scheduled.Status})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel scheduled price", "details": err.Error()})
+		}
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel scheduled price", "details": err.Error()})
+		return
+	}
+	h.Cache.Invalidate()
+
+	c.Status(http.StatusNoContent)
+}
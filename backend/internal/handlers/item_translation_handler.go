@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/catalog"
+	"ecommerce-app/internal/i18n"
+	"ecommerce-app/internal/models"
+)
+
+// SetTranslationRequest is an item's name and description in one locale.
+type SetTranslationRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// localeFromContext returns the locale the Locale middleware picked for the
+// request and the catalog's default locale.
+func localeFromContext(c *gin.Context) (locale, defaultLocale string) {
+	locale, defaultLocale = c.GetString("locale"), c.GetString("defaultLocale")
+	if locale == "" {
+		locale = defaultLocale
+	}
+	return locale, defaultLocale
+}
+
+// loadTranslations returns the translations of the given items into locale,
+// keyed by item ID. Items without one are missing from the map and are shown
+// in the default locale.
+func loadTranslations(db *gorm.DB, itemIDs []uint, locale string) (map[uint]models.ItemTranslation, error) {
+	result := make(map[uint]models.ItemTranslation)
+	if len(itemIDs) == 0 || locale == "" {
+		return result, nil
+	}
+
+	var translations []models.ItemTranslation
+	if err := db.Where("item_id IN (?) AND locale = ?", itemIDs, locale).Find(&translations).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range translations {
+		result[t.ItemID] = t
+	}
+	return result, nil
+}
+
+// localize fills in the name, description and locale of an item response,
+// using the translation when there is one.
+func localize(resp *ItemResponse, item models.Item, translations map[uint]models.ItemTranslation, defaultLocale string) {
+	resp.Name, resp.Description, resp.Locale = item.Name, item.Description, defaultLocale
+	if t, ok := translations[item.ID]; ok {
+		resp.Name, resp.Description, resp.Locale = t.Name, t.Description, t.Locale
+	}
+}
+
+// translationLocale validates the :locale parameter. Only supported locales
+// other than the default one can have translations; the default locale is
+// the item's own name and description. On error it writes a 400 response.
+func (h *ItemHandler) translationLocale(c *gin.Context) (string, bool) {
+	locale := i18n.Normalize(c.Param("locale"))
+	if !h.Locales.IsSupported(locale) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale", "details": h.Locales.Supported})
+		return "", false
+	}
+	if locale == h.Locales.Default {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The default locale is edited on the item itself"})
+		return "", false
+	}
+	return locale, true
+}
+
+// ListTranslations returns all translations of an item.
+func (h *ItemHandler) ListTranslations(c *gin.Context) {
+	var item models.Item
+	if err := h.DB.First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+
+	translations := []models.ItemTranslation{}
+	if err := h.DB.Where("item_id = ?", item.ID).Order("locale").Find(&translations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch translations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"item_id":        item.ID,
+		"default_locale": h.Locales.Default,
+		"translations":   translations,
+	})
+}
+
+// SetTranslation creates or replaces an item's translation into a locale.
+func (h *ItemHandler) SetTranslation(c *gin.Context) {
+	locale, ok := h.translationLocale(c)
+	if !ok {
+		return
+	}
+	var req SetTranslationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+		return
+	}
+
+	var item models.Item
+	if err := h.DB.First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+
+	tx := h.DB.Begin()
+	var translation models.ItemTranslation
+	if err := tx.Where("item_id = ? AND locale = ?", item.ID, locale).
+		FirstOrInit(&translation, models.ItemTranslation{ItemID: item.ID, Locale: locale}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save translation"})
+		return
+	}
+	status := http.StatusOK
+	if translation.ID == 0 {
+		status = http.StatusCreated
+	}
+	translation.Name = name
+	translation.Description = strings.TrimSpace(req.Description)
+	if err := tx.Save(&translation).Error; err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The translation was changed concurrently, try again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save translation"})
+		return
+	}
+	if err := catalog.TouchItems(tx, item.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save translation"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save translation"})
+		return
+	}
+	h.Cache.Invalidate()
+
+	c.JSON(status, translation)
+}
+
+// DeleteTranslation removes an item's translation into a locale, so the
+// item is shown in the default locale there again.
+func (h *ItemHandler) DeleteTranslation(c *gin.Context) {
+	locale, ok := h.translationLocale(c)
+	if !ok {
+		return
+	}
+
+	tx := h.DB.Begin()
+	result := tx.Where("item_id = ? AND locale = ?", c.Param("id"), locale).Delete(&models.ItemTranslation{})
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete translation"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Translation not found"})
+		return
+	}
+	var item models.Item
+	if err := tx.First(&item, c.Param("id")).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete translation"})
+		return
+	}
+	if err := catalog.TouchItems(tx, item.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete translation"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete translation"})
+		return
+	}
+	h.Cache.Invalidate()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Translation deleted"})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+)
+
+// SetVisibilityRequest replaces an item's visibility and publish window.
+// Leaving out publish_at or unpublish_at removes that end of the window.
+type SetVisibilityRequest struct {
+	Visibility  string     `json:"visibility" binding:"required"`
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+// checkPublishing validates a visibility and publish window.
+func checkPublishing(visibility string, publishAt, unpublishAt *time.Time) error {
+	switch visibility {
+	case models.ItemVisibilityPublished, models.ItemVisibilityDraft:
+	default:
+		return fmt.Errorf("visibility must be %s or %s", models.ItemVisibilityPublished, models.ItemVisibilityDraft)
+	}
+	if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+		return fmt.Errorf("unpublish_at must be after publish_at")
+	}
+	return nil
+}
+
+// SetVisibility publishes an item, turns it back into a draft or schedules
+// its publish window.
+func (h *ItemHandler) SetVisibility(c *gin.Context) {
+	var req SetVisibilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if err := checkPublishing(req.Visibility, req.PublishAt, req.UnpublishAt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var item models.Item
+	if err := h.DB.First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+
+	// Updating from a struct would skip nil fields and keep the old window
+	updates := map[string]interface{}{
+		"visibility":   req.Visibility,
+		"publish_at":   req.PublishAt,
+		"unpublish_at": req.UnpublishAt,
+	}
+	if err := h.DB.Model(&item).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update visibility"})
+		return
+	}
+	h.Cache.Invalidate()
+
+	log.Printf("Item %d is now %s (visible: %t)", item.ID, item.Visibility, item.Visible(time.Now()))
+	c.JSON(http.StatusOK, item)
+}
@@ -1,19 +1,43 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/catalog"
 	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+	"ecommerce-app/internal/pricing"
 )
 
 type OrderHandler struct {
-	DB *gorm.DB
+	DB    *gorm.DB
+	Cache *catalog.Cache
+	// Pricing computes order totals; it must match the one of CartHandler
+	Pricing *pricing.Engine
 }
 
-func NewOrderHandler(db *gorm.DB) *OrderHandler {
-	return &OrderHandler{DB: db}
+func NewOrderHandler(db *gorm.DB, cache *catalog.Cache) *OrderHandler {
+	return &OrderHandler{DB: db, Cache: cache, Pricing: pricing.NewEngine(pricing.CouponDiscount{})}
+}
+
+// CreateOrderRequest is the optional body of CreateOrder. Lines whose price
+// changed since they were added to the cart must be acknowledged at their
+// current price.
+type CreateOrderRequest struct {
+	AcknowledgedPrices []PriceAcknowledgement `json:"acknowledged_prices" binding:"dive"`
+}
+
+// unavailableLine is a cart line whose item shoppers can no longer see, such
+// as a draft or an item outside its publish window. For bundles, ItemID is
+// the unavailable component.
+type unavailableLine struct {
+	ItemID   uint   `json:"item_id"`
+	BundleID uint   `json:"bundle_id,omitempty"`
+	Name     string `json:"name"`
 }
 
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
@@ -23,6 +47,12 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
+	var req CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
 	// Start a transaction
 	tx := h.DB.Begin()
 
@@ -39,14 +69,23 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 
 	// Get cart items with product details
 	var cartItems []struct {
-		ID       uint    `gorm:"column:id"`
-		Name     string  `gorm:"column:name"`
-		Price    float64 `gorm:"column:price"`
-		Quantity int     `gorm:"column:quantity"`
+		ID             uint        `gorm:"column:id" json:"id"`
+		Name           string      `gorm:"column:name" json:"name"`
+		Price          money.Money `gorm:"embedded;embedded_prefix:price_" json:"price"`
+		AddedPrice     money.Money `gorm:"embedded;embedded_prefix:unit_price_" json:"-"`
+		Quantity       int         `gorm:"column:quantity" json:"quantity"`
+		Stock          int         `gorm:"column:stock" json:"-"`
+		AllowBackorder bool        `gorm:"column:allow_backorder" json:"-"`
+		Digital        bool        `gorm:"column:digital" json:"-"`
+		Visibility     string      `gorm:"column:visibility" json:"-"`
+		PublishAt      *time.Time  `gorm:"column:publish_at" json:"-"`
+		UnpublishAt    *time.Time  `gorm:"column:unpublish_at" json:"-"`
+		Discount       money.Money `gorm:"-" json:"discount"`
+		Backordered    int         `gorm:"-" json:"backordered"`
 	}
 
 	if err := tx.Table("cart_items").
-		Select("items.id, items.name, items.price, cart_items.quantity").
+		Select("items.id, items.name, items.price_amount, items.price_currency, cart_items.unit_price_amount, cart_items.unit_price_currency, cart_items.quantity, items.stock, items.allow_backorder, items.digital, items.visibility, items.publish_at, items.unpublish_at").
 		Joins("JOIN items ON items.id = cart_items.item_id").
 		Where("cart_items.cart_id = ?", cart.ID).
 		Scan(&cartItems).Error; err != nil {
@@ -58,18 +97,154 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
+	var cartBundles []models.CartBundle
+	if err := tx.Where("cart_id = ?", cart.ID).Order("id").Find(&cartBundles).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch cart items",
+			"details": err.Error(),
+		})
+		return
+	}
+
 	// Check if cart is empty
-	if len(cartItems) == 0 {
+	if len(cartItems) == 0 && len(cartBundles) == 0 {
 		tx.Rollback()
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot create order with empty cart"})
 		return
 	}
 
+	// Priced like GetCart prices the cart: items first, then bundles
+	lines := make([]pricing.Line, 0, len(cartItems)+len(cartBundles))
+	var changes []priceChange
+	// Items may have been unpublished since they were added to the cart
+	now := time.Now()
+	var unavailable []unavailableLine
+	for _, line := range cartItems {
+		item := models.Item{Visibility: line.Visibility, PublishAt: line.PublishAt, UnpublishAt: line.UnpublishAt}
+		if !item.Visible(now) {
+			unavailable = append(unavailable, unavailableLine{ItemID: line.ID, Name: line.Name})
+		}
+		lines = append(lines, itemPricingLine(line.ID, line.Name, line.Price, line.Quantity, line.Digital))
+		if priceChanged(line.AddedPrice, line.Price) {
+			changes = append(changes, priceChange{ItemID: line.ID, Name: line.Name, AddedPrice: line.AddedPrice, Price: line.Price})
+		}
+	}
+	// Bundles are ordered as their components
+	var expandedBundles [][]models.OrderItem
+	var bundles []gin.H
+	components := make(map[uint]models.Item)
+	for _, line := range cartBundles {
+		var bundle models.Bundle
+		if err := tx.Preload("Components").First(&bundle, line.BundleID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch bundle",
+				"details": err.Error(),
+			})
+			return
+		}
+		items, err := loadBundleItems(tx, bundle)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch bundle items",
+				"details": err.Error(),
+			})
+			return
+		}
+		for _, comp := range bundle.Components {
+			if item := items[comp.ItemID]; !item.Visible(now) {
+				unavailable = append(unavailable, unavailableLine{ItemID: comp.ItemID, BundleID: bundle.ID, Name: item.Name})
+			}
+		}
+		for id, item := range items {
+			components[id] = item
+		}
+
+		expanded, err := expandBundle(bundle, items, line.Quantity)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot create order with items in different currencies"})
+			return
+		}
+		expandedBundles = append(expandedBundles, expanded)
+		lines = append(lines, bundlePricingLine(bundle, items, line.Quantity))
+		if priceChanged(line.UnitPrice, bundle.Price) {
+			changes = append(changes, priceChange{BundleID: bundle.ID, Name: bundle.Name, AddedPrice: line.UnitPrice, Price: bundle.Price})
+		}
+		bundles = append(bundles, gin.H{
+			"bundle_id": bundle.ID,
+			"name":      bundle.Name,
+			"price":     bundle.Price,
+			"quantity":  line.Quantity,
+		})
+	}
+
+	if len(unavailable) > 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Some items are no longer available",
+			"items": unavailable,
+		})
+		return
+	}
+
+	// The order is charged at current prices, so the customer has to have
+	// seen every one that changed
+	if missing := unacknowledged(changes, req.AcknowledgedPrices); len(missing) > 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "Prices changed since the items were added to the cart",
+			"price_changes": missing,
+		})
+		return
+	}
+
+	coupon, pricedCoupon, err := cartCoupon(tx, cart, lines, now)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupon", "details": err.Error()})
+		return
+	}
+	breakdown, err := h.Pricing.Price(c.Request.Context(), pricing.Cart{ID: cart.ID, UserID: cart.UserID, Lines: lines, Coupon: pricedCoupon})
+	if err != nil {
+		tx.Rollback()
+		if err == pricing.ErrMixedCurrencies {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot create order with items in different currencies"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price order", "details": err.Error()})
+		}
+		return
+	}
+	// A coupon that stopped applying is not dropped silently; the customer
+	// changes the cart or removes the coupon
+	if breakdown.Coupon != nil && !breakdown.Coupon.Applied {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": breakdown.Coupon.Reason, "coupon": breakdown.Coupon})
+		return
+	}
+	// Line discounts stay with the lines they were given to; a bundle's is
+	// spread over its components
+	for i := range cartItems {
+		cartItems[i].Discount = breakdown.Lines[i].Discount
+	}
+	var bundleLines []models.OrderItem
+	for j, expanded := range expandedBundles {
+		spreadDiscount(expanded, breakdown.Lines[len(cartItems)+j].Discount)
+		bundleLines = append(bundleLines, expanded...)
+	}
+
 	// Create order
 	order := models.Order{
-		UserID: userID.(uint),
-		CartID: cart.ID,
-		Status: "completed",
+		UserID:   userID.(uint),
+		CartID:   cart.ID,
+		Status:   "completed",
+		Total:    breakdown.Total,
+		Subtotal: breakdown.Subtotal,
+		Discount: breakdown.Discount,
+		Shipping: breakdown.Shipping,
+		Tax:      breakdown.Tax,
 	}
 
 	if err := tx.Create(&order).Error; err != nil {
@@ -80,6 +255,159 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		})
 		return
 	}
+	for _, adj := range breakdown.Adjustments {
+		adjustment := models.OrderAdjustment{
+			OrderID:    order.ID,
+			Calculator: adj.Calculator,
+			Kind:       adj.Kind,
+			Label:      adj.Label,
+			Amount:     adj.Amount,
+		}
+		if err := tx.Create(&adjustment).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create order",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+	if coupon != nil {
+		if err := redeemCoupon(tx, *coupon, order, breakdown.Coupon.Discount); err != nil {
+			tx.Rollback()
+			if ce, ok := err.(*cartError); ok {
+				c.JSON(ce.status, ce.body)
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to redeem coupon",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	// Reserve stock for every line, collecting all shortages so the client
+	// can fix the whole cart in one go
+	var shortages []stockShortage
+	var ordered []models.OrderItem
+	for i := range cartItems {
+		line := &cartItems[i]
+		item := models.Item{ID: line.ID, Stock: line.Stock, AllowBackorder: line.AllowBackorder, Digital: line.Digital}
+
+		backordered, err := reserveStock(tx, item, line.Quantity)
+		if err == errInsufficientStock {
+			shortages = append(shortages, stockShortage{
+				ItemID:    line.ID,
+				Name:      line.Name,
+				Requested: line.Quantity,
+				Available: availableStock(item),
+			})
+			continue
+		}
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to reserve stock",
+				"details": err.Error(),
+			})
+			return
+		}
+		line.Backordered = backordered
+
+		orderItem := models.OrderItem{
+			OrderID:     order.ID,
+			ItemID:      line.ID,
+			Quantity:    line.Quantity,
+			UnitPrice:   line.Price,
+			Discount:    line.Discount,
+			Backordered: backordered,
+		}
+		if err := tx.Create(&orderItem).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create order items",
+				"details": err.Error(),
+			})
+			return
+		}
+		ordered = append(ordered, orderItem)
+		if backordered > 0 {
+			order.Status = "backordered"
+		}
+	}
+
+	for _, orderItem := range bundleLines {
+		item := components[orderItem.ItemID]
+		backordered, err := reserveStock(tx, item, orderItem.Quantity)
+		if err == errInsufficientStock {
+			shortages = append(shortages, stockShortage{
+				ItemID:    item.ID,
+				Name:      item.Name,
+				Requested: orderItem.Quantity,
+				Available: availableStock(item),
+			})
+			continue
+		}
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to reserve stock",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		orderItem.OrderID = order.ID
+		orderItem.Backordered = backordered
+		if err := tx.Create(&orderItem).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create order items",
+				"details": err.Error(),
+			})
+			return
+		}
+		ordered = append(ordered, orderItem)
+		if backordered > 0 {
+			order.Status = "backordered"
+		}
+	}
+
+	if len(shortages) > 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Insufficient stock for some items",
+			"items": shortages,
+		})
+		return
+	}
+
+	// Digital items get their downloads and license keys right away
+	hasDigital, err := fulfillDigitalItems(tx, order.ID, ordered)
+	if err != nil {
+		tx.Rollback()
+		if ce, ok := err.(*cartError); ok {
+			c.JSON(ce.status, ce.body)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to deliver digital items",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if order.Status == "backordered" {
+		if err := tx.Model(&order).Update("status", order.Status).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to update order status",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
 
 	// Update cart status to 'ordered'
 	if err := tx.Model(&models.Cart{}).Where("id = ?", cart.ID).Update("status", "ordered").Error; err != nil {
@@ -100,15 +428,23 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		})
 		return
 	}
+	// Stock and status of the ordered items changed
+	h.Cache.Invalidate()
 
-	// Prepare response with order details
-	response := gin.H{
-		"message":    "Order created successfully",
-		"order_id":   order.ID,
-		"cart_id":    order.CartID,
-		"status":     order.Status,
-		"created_at": order.CreatedAt,
-		"items":      cartItems,
+	// Prepare response with order details and the same breakdown the cart
+	// showed
+	response := breakdownResponse(breakdown)
+	response["message"] = "Order created successfully"
+	response["order_id"] = order.ID
+	response["cart_id"] = order.CartID
+	response["status"] = order.Status
+	response["created_at"] = order.CreatedAt
+	response["items"] = cartItems
+	if len(bundles) > 0 {
+		response["bundles"] = bundles
+	}
+	if hasDigital {
+		response["downloads_url"] = orderDownloadsURL(order.ID)
 	}
 
 	c.JSON(http.StatusCreated, response)
@@ -136,37 +472,68 @@ func (h *OrderHandler) ListOrders(c *gin.Context) {
 			continue
 		}
 
-		// Get cart items with details
+		// Get order lines with the price that was charged. Orders placed
+		// before order lines were recorded fall back to the cart contents
+		// at the current item price.
 		var items []map[string]interface{}
-		rows, err := h.DB.Table("cart_items").
-			Select("items.id, items.name, items.price, cart_items.quantity").
-			Joins("JOIN items ON items.id = cart_items.item_id").
-			Where("cart_items.cart_id = ?", cart.ID).
-			Rows()
+		var lineCount int
+		h.DB.Model(&models.OrderItem{}).Where("order_id = ?", order.ID).Count(&lineCount)
+
+		query := h.DB.Table("order_items").
+			Select("items.id, items.name, order_items.unit_price_amount, order_items.unit_price_currency, order_items.quantity, order_items.bundle_id, order_items.discount_amount").
+			Joins("JOIN items ON items.id = order_items.item_id").
+			Where("order_items.order_id = ?", order.ID)
+		if lineCount == 0 {
+			query = h.DB.Table("cart_items").
+				Select("items.id, items.name, items.price_amount, items.price_currency, cart_items.quantity, NULL, 0").
+				Joins("JOIN items ON items.id = cart_items.item_id").
+				Where("cart_items.cart_id = ?", cart.ID)
+		}
+		rows, err := query.Rows()
 
 		if err == nil {
 			for rows.Next() {
 				var id uint
 				var name string
-				var price float64
+				var price money.Money
 				var quantity int
-				rows.Scan(&id, &name, &price, &quantity)
-				items = append(items, map[string]interface{}{
+				var bundleID *uint
+				var discount int64
+				rows.Scan(&id, &name, &price.Amount, &price.Currency, &quantity, &bundleID, &discount)
+				line := map[string]interface{}{
 					"id":       id,
 					"name":     name,
 					"price":    price,
 					"quantity": quantity,
-				})
+				}
+				// Components of a bundle carry their share of its saving, and
+				// any line may have been discounted when it was priced
+				if bundleID != nil {
+					line["bundle_id"] = *bundleID
+				}
+				if bundleID != nil || discount != 0 {
+					line["discount"] = money.New(discount, price.Currency)
+				}
+				items = append(items, line)
 			}
 			rows.Close()
 		}
 
+		adjustments := []models.OrderAdjustment{}
+		h.DB.Where("order_id = ?", order.ID).Order("id").Find(&adjustments)
+
 		orderDetails = append(orderDetails, map[string]interface{}{
-			"order_id":   order.ID,
-			"cart_id":    order.CartID,
-			"status":     order.Status,
-			"created_at": order.CreatedAt,
-			"items":      items,
+			"order_id":    order.ID,
+			"cart_id":     order.CartID,
+			"status":      order.Status,
+			"subtotal":    order.Subtotal,
+			"discount":    order.Discount,
+			"shipping":    order.Shipping,
+			"tax":         order.Tax,
+			"total":       order.Total,
+			"adjustments": adjustments,
+			"created_at":  order.CreatedAt,
+			"items":       items,
 		})
 	}
 
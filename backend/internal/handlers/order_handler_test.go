@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+)
+
+// newOrderTestDB is newCartTestDB with the tables checkout writes to.
+func newOrderTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return newCartTestDB(t, &models.ItemEvent{}, &models.Coupon{}, &models.CouponItem{},
+		&models.CouponCategory{}, &models.CouponRedemption{}, &models.Order{},
+		&models.OrderAdjustment{}, &models.OrderItem{})
+}
+
+func newOrderTestRouter(h *OrderHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/orders", func(c *gin.Context) {
+		var userID uint
+		if _, err := fmt.Sscan(c.GetHeader("X-Test-User"), &userID); err == nil {
+			c.Set("userID", userID)
+		}
+		h.CreateOrder(c)
+	})
+	return r
+}
+
+// placeOrder checks out the active cart of userID with body, which may be
+// nil.
+func placeOrder(r http.Handler, userID uint, body interface{}) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", fmt.Sprint(userID))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// createActiveCart gives userID an active cart holding quantity of item at
+// its current price, with coupon applied when it is not nil.
+func createActiveCart(t *testing.T, db *gorm.DB, userID uint, item models.Item, quantity int, coupon *models.Coupon) models.Cart {
+	t.Helper()
+	cart := models.Cart{UserID: &userID, Status: "active"}
+	if coupon != nil {
+		cart.CouponID = &coupon.ID
+	}
+	if err := db.Create(&cart).Error; err != nil {
+		t.Fatalf("create cart: %v", err)
+	}
+	line := models.CartItem{CartID: cart.ID, ItemID: item.ID, Quantity: quantity, UnitPrice: item.Price}
+	if err := db.Create(&line).Error; err != nil {
+		t.Fatalf("create cart item: %v", err)
+	}
+	return cart
+}
+
+func createOrderTestItem(t *testing.T, db *gorm.DB) models.Item {
+	t.Helper()
+	item := models.Item{SKU: "SKU-1", Name: "Widget", Price: money.New(1000, "USD"),
+		Status: models.ItemStatusAvailable, Stock: 100}
+	if err := db.Create(&item).Error; err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	return item
+}
+
+func TestCreateOrderCouponMaxUsesConcurrent(t *testing.T) {
+	const n, maxUses = 10, 3
+	db := newOrderTestDB(t)
+	item := createOrderTestItem(t, db)
+	coupon := models.Coupon{Code: "SAVE10", Type: models.CouponTypePercent, PercentOff: 10, MaxUses: maxUses}
+	if err := db.Create(&coupon).Error; err != nil {
+		t.Fatalf("create coupon: %v", err)
+	}
+	for userID := uint(1); userID <= n; userID++ {
+		createActiveCart(t, db, userID, item, 1, &coupon)
+	}
+	r := newOrderTestRouter(NewOrderHandler(db, nil))
+
+	codes := make([]int, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			codes[i] = placeOrder(r, uint(i+1), nil).Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	var created, rejected int
+	for i, code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+			rejected++
+		default:
+			t.Errorf("order %d: status %d, want 201 or 409", i, code)
+		}
+	}
+	if created != maxUses || rejected != n-maxUses {
+		t.Errorf("got %d orders and %d rejected, want %d and %d", created, rejected, maxUses, n-maxUses)
+	}
+
+	var got models.Coupon
+	if err := db.First(&got, coupon.ID).Error; err != nil {
+		t.Fatalf("find coupon: %v", err)
+	}
+	var orders, redemptions int
+	db.Model(&models.Order{}).Count(&orders)
+	db.Model(&models.CouponRedemption{}).Where("coupon_id = ?", coupon.ID).Count(&redemptions)
+	if got.Uses != maxUses || orders != maxUses || redemptions != maxUses {
+		t.Errorf("%d uses, %d orders and %d redemptions, want %d of each", got.Uses, orders, redemptions, maxUses)
+	}
+}
+
+func TestCreateOrderCouponMaxUsesPerUser(t *testing.T) {
+	db := newOrderTestDB(t)
+	item := createOrderTestItem(t, db)
+	coupon := models.Coupon{Code: "ONCE", Type: models.CouponTypeFixed, AmountOff: money.New(200, "USD"), MaxUsesPerUser: 1}
+	if err := db.Create(&coupon).Error; err != nil {
+		t.Fatalf("create coupon: %v", err)
+	}
+	r := newOrderTestRouter(NewOrderHandler(db, nil))
+
+	createActiveCart(t, db, 7, item, 1, &coupon)
+	if w := placeOrder(r, 7, nil); w.Code != http.StatusCreated {
+		t.Fatalf("first order: status %d, want 201: %s", w.Code, w.Body)
+	}
+
+	// The coupon is not dropped from the second cart; checkout refuses it
+	createActiveCart(t, db, 7, item, 1, &coupon)
+	w := placeOrder(r, 7, nil)
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusConflict || body.Error != "You have already used this coupon" {
+		t.Errorf("second order: status %d %q, want 409 %q", w.Code, body.Error, "You have already used this coupon")
+	}
+
+	// Other users are not limited by it
+	createActiveCart(t, db, 8, item, 1, &coupon)
+	if w := placeOrder(r, 8, nil); w.Code != http.StatusCreated {
+		t.Errorf("other user: status %d, want 201: %s", w.Code, w.Body)
+	}
+}
+
+func TestRedeemCouponPerUserConcurrent(t *testing.T) {
+	const n = 10
+	db := newOrderTestDB(t)
+	coupon := models.Coupon{Code: "ONCE", Type: models.CouponTypePercent, PercentOff: 10, MaxUsesPerUser: 1}
+	if err := db.Create(&coupon).Error; err != nil {
+		t.Fatalf("create coupon: %v", err)
+	}
+
+	// Checkouts of one user that both passed the cart's coupon check
+	errs := make([]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			tx := db.Begin()
+			order := models.Order{ID: uint(i + 1), UserID: 7}
+			if err := redeemCoupon(tx, coupon, order, money.New(100, "USD")); err != nil {
+				tx.Rollback()
+				errs[i] = err
+				return
+			}
+			errs[i] = tx.Commit().Error
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	var redeemed int
+	for i, err := range errs {
+		if err == nil {
+			redeemed++
+			continue
+		}
+		if ce, ok := err.(*cartError); !ok || ce.status != http.StatusConflict {
+			t.Errorf("redemption %d: %v, want a 409 cartError", i, err)
+		}
+	}
+	if redeemed != 1 {
+		t.Errorf("%d redemptions succeeded, want 1", redeemed)
+	}
+	var got models.Coupon
+	if err := db.First(&got, coupon.ID).Error; err != nil {
+		t.Fatalf("find coupon: %v", err)
+	}
+	if got.Uses != 1 {
+		t.Errorf("%d uses, want 1", got.Uses)
+	}
+}
+
+func TestCreateOrderPriceAcknowledgement(t *testing.T) {
+	db := newOrderTestDB(t)
+	item := createOrderTestItem(t, db)
+	user := map[string]string{"X-Test-User": "7"}
+	cartRouter := newCartTestRouter(NewCartHandler(db))
+	orderRouter := newOrderTestRouter(NewOrderHandler(db, nil))
+
+	if codes := addConcurrently(cartRouter, 1, gin.H{"item_id": item.ID}, user); codes[0] != http.StatusOK {
+		t.Fatalf("add to cart: status %d", codes[0])
+	}
+	if err := db.Model(&item).UpdateColumn("price_amount", 1200).Error; err != nil {
+		t.Fatalf("update price: %v", err)
+	}
+	// Adding more of the item keeps the price it was first added at, so the
+	// change still has to be acknowledged
+	if codes := addConcurrently(cartRouter, 1, gin.H{"item_id": item.ID}, user); codes[0] != http.StatusOK {
+		t.Fatalf("add to cart: status %d", codes[0])
+	}
+	var line models.CartItem
+	if err := db.Where("item_id = ?", item.ID).First(&line).Error; err != nil {
+		t.Fatalf("find cart item: %v", err)
+	}
+	if line.Quantity != 2 || line.UnitPrice != money.New(1000, "USD") {
+		t.Errorf("line of %d at %s, want 2 at 10.00 USD", line.Quantity, line.UnitPrice)
+	}
+
+	type response struct {
+		PriceChanges []priceChange `json:"price_changes"`
+		Total        money.Money   `json:"total"`
+	}
+	acknowledge := func(amount int64) gin.H {
+		return gin.H{"acknowledged_prices": []gin.H{{"item_id": item.ID, "price": money.New(amount, "USD")}}}
+	}
+	tests := []struct {
+		name     string
+		body     interface{}
+		wantCode int
+	}{
+		{"not acknowledged", nil, http.StatusConflict},
+		{"old price acknowledged", acknowledge(1000), http.StatusConflict},
+		{"other line acknowledged", gin.H{"acknowledged_prices": []gin.H{{"item_id": item.ID + 1, "price": money.New(1200, "USD")}}}, http.StatusConflict},
+		{"current price acknowledged", acknowledge(1200), http.StatusCreated},
+	}
+	for _, tt := range tests {
+		w := placeOrder(orderRouter, 7, tt.body)
+		if w.Code != tt.wantCode {
+			t.Fatalf("%s: status %d, want %d: %s", tt.name, w.Code, tt.wantCode, w.Body)
+		}
+		var got response
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: decode response: %v", tt.name, err)
+		}
+		if tt.wantCode == http.StatusConflict {
+			want := priceChange{ItemID: item.ID, Name: item.Name, AddedPrice: money.New(1000, "USD"), Price: money.New(1200, "USD")}
+			if len(got.PriceChanges) != 1 || got.PriceChanges[0] != want {
+				t.Errorf("%s: price changes %+v, want %+v", tt.name, got.PriceChanges, want)
+			}
+			continue
+		}
+		// Charged at the current price
+		if got.Total != money.New(2400, "USD") {
+			t.Errorf("%s: total %s, want 24.00 USD", tt.name, got.Total)
+		}
+	}
+}
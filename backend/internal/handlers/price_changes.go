@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"ecommerce-app/internal/money"
+)
+
+// priceChange is a cart line whose price changed after it was added to the
+// cart. The line is either an item or a bundle.
+type priceChange struct {
+	ItemID     uint        `json:"item_id,omitempty"`
+	BundleID   uint        `json:"bundle_id,omitempty"`
+	Name       string      `json:"name"`
+	AddedPrice money.Money `json:"added_price"`
+	Price      money.Money `json:"price"`
+}
+
+// PriceAcknowledgement accepts the current price of a changed cart line,
+// as shown in price_changes of the cart.
+type PriceAcknowledgement struct {
+	ItemID   uint        `json:"item_id"`
+	BundleID uint        `json:"bundle_id"`
+	Price    money.Money `json:"price" binding:"required"`
+}
+
+// priceChanged reports whether the price of a line differs from the price
+// it was added at.
+func priceChanged(added, current money.Money) bool {
+	return money.New(added.Amount, added.Currency) != money.New(current.Amount, current.Currency)
+}
+
+// unacknowledged returns the changes without an acknowledgement of their
+// current price. Acknowledging an older price does not count, so a price
+// that changes again has to be accepted again.
+func unacknowledged(changes []priceChange, acks []PriceAcknowledgement) []priceChange {
+	type line struct{ itemID, bundleID uint }
+	accepted := make(map[line]money.Money, len(acks))
+	for _, a := range acks {
+		accepted[line{a.ItemID, a.BundleID}] = a.Price
+	}
+
+	var missing []priceChange
+	for _, change := range changes {
+		price, ok := accepted[line{change.ItemID, change.BundleID}]
+		if !ok || priceChanged(price, change.Price) {
+			missing = append(missing, change)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/catalog"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+	"ecommerce-app/internal/recommend"
+)
+
+type RecommendationHandler struct {
+	DB *gorm.DB
+}
+
+func NewRecommendationHandler(db *gorm.DB) *RecommendationHandler {
+	return &RecommendationHandler{DB: db}
+}
+
+// RecommendedItem is an item suggested alongside another one.
+type RecommendedItem struct {
+	ID     uint        `json:"id"`
+	SKU    string      `json:"sku,omitempty"`
+	Name   string      `json:"name"`
+	Price  money.Money `json:"price"`
+	Status string      `json:"status"`
+	Score  float64     `json:"score"`
+}
+
+// GetRecommendations returns the precomputed recommendations for an item,
+// best first. ?strategy= picks the strategy (default
+// frequently_bought_together) and ?limit= caps the list (default 5, max 10).
+// Items that can no longer be bought or are hidden from shoppers are left out.
+func (h *RecommendationHandler) GetRecommendations(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+	strategy := c.DefaultQuery("strategy", recommend.StrategyFrequentlyBoughtTogether)
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if err != nil || limit < 1 {
+		limit = 5
+	}
+	if limit > 10 {
+		limit = 10
+	}
+
+	var recs []models.ItemRecommendation
+	if err := h.DB.Where("strategy = ? AND item_id = ?", strategy, itemID).
+		Order("rank").
+		Find(&recs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recommendations"})
+		return
+	}
+
+	ids := make([]uint, 0, len(recs))
+	for _, rec := range recs {
+		ids = append(ids, rec.RecommendedItemID)
+	}
+	var items []models.Item
+	if len(ids) > 0 {
+		if err := catalog.Visible(h.DB, time.Now()).Where("id IN (?)", ids).Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch items"})
+			return
+		}
+	}
+	byID := make(map[uint]models.Item, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	response := make([]RecommendedItem, 0, limit)
+	for _, rec := range recs {
+		item, ok := byID[rec.RecommendedItemID]
+		if !ok || !item.Purchasable(1) {
+			continue
+		}
+		response = append(response, RecommendedItem{
+			ID:     item.ID,
+			SKU:    item.SKU,
+			Name:   item.Name,
+			Price:  item.Price,
+			Status: item.Status,
+			Score:  rec.Score,
+		})
+		if len(response) == limit {
+			break
+		}
+	}
+
+	result := gin.H{
+		"item_id":         itemID,
+		"strategy":        strategy,
+		"recommendations": response,
+	}
+	if len(recs) > 0 {
+		result["computed_at"] = recs[0].ComputedAt
+	}
+	c.JSON(http.StatusOK, result)
+}
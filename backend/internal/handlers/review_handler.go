@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/catalog"
+	"ecommerce-app/internal/models"
+)
+
+type ReviewHandler struct {
+	DB    *gorm.DB
+	Cache *catalog.Cache
+}
+
+func NewReviewHandler(db *gorm.DB, cache *catalog.Cache) *ReviewHandler {
+	return &ReviewHandler{DB: db, Cache: cache}
+}
+
+type CreateReviewRequest struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Title  string `json:"title" binding:"max=200"`
+	Body   string `json:"body" binding:"max=5000"`
+}
+
+type ModerateReviewRequest struct {
+	Status string `json:"status" binding:"required,oneof=approved rejected"`
+}
+
+// ratingSummary is the aggregate of approved reviews for an item.
+type ratingSummary struct {
+	AverageRating float64 `json:"average_rating"`
+	ReviewCount   int     `json:"review_count"`
+}
+
+// loadRatings returns the rating summary of approved reviews per item.
+// Items without reviews are left out of the map.
+func loadRatings(db *gorm.DB, itemIDs []uint) (map[uint]ratingSummary, error) {
+	result := make(map[uint]ratingSummary)
+	if len(itemIDs) == 0 {
+		return result, nil
+	}
+
+	rows, err := db.Model(&models.Review{}).
+		Select("item_id, AVG(rating), COUNT(*)").
+		Where("item_id IN (?) AND status = ?", itemIDs, models.ReviewStatusApproved).
+		Group("item_id").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemID uint
+		var summary ratingSummary
+		if err := rows.Scan(&itemID, &summary.AverageRating, &summary.ReviewCount); err != nil {
+			return nil, err
+		}
+		summary.AverageRating = math.Round(summary.AverageRating*100) / 100
+		result[itemID] = summary
+	}
+	return result, rows.Err()
+}
+
+// hasOrderedItem reports whether the user has an order containing the item.
+// Orders placed before order lines were recorded are matched on their cart.
+func hasOrderedItem(db *gorm.DB, userID, itemID uint) (bool, error) {
+	var count int
+	err := db.Table("orders").
+		Where("orders.user_id = ?", userID).
+		Where(`EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = orders.id AND oi.item_id = ?)
+			OR EXISTS (SELECT 1 FROM cart_items ci WHERE ci.cart_id = orders.cart_id AND ci.item_id = ?)`,
+			itemID, itemID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CreateReview adds a review for an item. Only customers with an order that
+// contains the item may review it, and only once. New reviews wait in the
+// moderation queue before they are shown.
+func (h *ReviewHandler) CreateReview(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	var item models.Item
+	if err := h.DB.First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+
+	verified, err := hasOrderedItem(h.DB, userID, item.ID)
+	if err != nil {
+		log.Printf("Error checking orders of user %d for item %d: %v", userID, item.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check purchase history"})
+		return
+	}
+	if !verified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only customers who ordered this item can review it"})
+		return
+	}
+
+	var existing int
+	if err := h.DB.Model(&models.Review{}).Where("item_id = ? AND user_id = ?", item.ID, userID).
+		Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing reviews"})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reviewed this item"})
+		return
+	}
+
+	review := models.Review{
+		ItemID: item.ID,
+		UserID: userID,
+		Rating: req.Rating,
+		Title:  strings.TrimSpace(req.Title),
+		Body:   strings.TrimSpace(req.Body),
+		Status: models.ReviewStatusPending,
+	}
+	if err := h.DB.Create(&review).Error; err != nil {
+		// The unique index catches a second review racing the check above
+		log.Printf("Error creating review: %v", err)
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reviewed this item"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, review)
+}
+
+// ListReviews returns the approved reviews of an item, newest first.
+// Supports ?page= and ?per_page= (max 100).
+func (h *ReviewHandler) ListReviews(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+	page, perPage := pagination(c)
+
+	reviews := []models.Review{}
+	if err := h.DB.Where("item_id = ? AND status = ?", itemID, models.ReviewStatusApproved).
+		Order("created_at DESC, id DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"item_id":  itemID,
+		"page":     page,
+		"per_page": perPage,
+		"reviews":  reviews,
+	})
+}
+
+// GetRating returns the average rating, review count and the number of
+// approved reviews for each star value.
+func (h *ReviewHandler) GetRating(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	rows, err := h.DB.Model(&models.Review{}).
+		Select("rating, COUNT(*)").
+		Where("item_id = ? AND status = ?", itemID, models.ReviewStatusApproved).
+		Group("rating").
+		Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rating"})
+		return
+	}
+	defer rows.Close()
+
+	distribution := map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}
+	total, sum := 0, 0
+	for rows.Next() {
+		var rating, count int
+		if err := rows.Scan(&rating, &count); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rating"})
+			return
+		}
+		distribution[rating] = count
+		total += count
+		sum += rating * count
+	}
+
+	average := 0.0
+	if total > 0 {
+		average = math.Round(float64(sum)/float64(total)*100) / 100
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"item_id":        itemID,
+		"average_rating": average,
+		"review_count":   total,
+		"distribution":   distribution,
+	})
+}
+
+// ListModerationQueue returns reviews waiting for moderation, oldest first.
+// ?status= selects another status, e.g. rejected.
+func (h *ReviewHandler) ListModerationQueue(c *gin.Context) {
+	status := c.DefaultQuery("status", models.ReviewStatusPending)
+	page, perPage := pagination(c)
+
+	reviews := []models.Review{}
+	if err := h.DB.Where("status = ?", status).
+		Order("created_at, id").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   status,
+		"page":     page,
+		"per_page": perPage,
+		"reviews":  reviews,
+	})
+}
+
+// ModerateReview approves or rejects a review.
+func (h *ReviewHandler) ModerateReview(c *gin.Context) {
+	var req ModerateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	var review models.Review
+	if err := h.DB.First(&review, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review"})
+		}
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":       req.Status,
+		"moderated_at": now,
+	}
+	if userID, ok := userIDFromContext(c); ok {
+		updates["moderated_by"] = userID
+	}
+	tx := h.DB.Begin()
+	if err := tx.Model(&review).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update review"})
+		return
+	}
+	// The item's rating changes with the set of approved reviews
+	if err := catalog.TouchItems(tx, review.ItemID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update review"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update review"})
+		return
+	}
+	h.Cache.Invalidate()
+
+	log.Printf("Review %d for item %d %s", review.ID, review.ItemID, req.Status)
+	c.JSON(http.StatusOK, review)
+}
+
+// pagination reads ?page= and ?per_page= with defaults of 1 and 20.
+func pagination(c *gin.Context) (page, perPage int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err = strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if err != nil || perPage < 1 {
+		perPage = 20
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	return page, perPage
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+)
+
+// CartSummary is one of a user's open carts: the current one, which adding
+// to the cart and checkout use, or a saved one.
+type CartSummary struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Current   bool      `json:"current"`
+	Lines     int       `json:"lines"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateCartRequest creates an empty named cart. It becomes the current
+// cart when Current is set or the user has none.
+type CreateCartRequest struct {
+	Name    string `json:"name" binding:"required,max=100"`
+	Current bool   `json:"current"`
+}
+
+// RenameCartRequest renames a cart.
+type RenameCartRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// openCartStatuses are the statuses of carts users can list and switch to.
+var openCartStatuses = []string{models.CartStatusActive, models.CartStatusSaved}
+
+// findUserCart returns the open cart id of userID. Carts of other owners
+// and closed carts are not found.
+func findUserCart(tx *gorm.DB, userID uint, id string) (models.Cart, error) {
+	var cart models.Cart
+	cartID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return cart, &cartError{status: http.StatusBadRequest, body: gin.H{"error": "Invalid cart ID"}}
+	}
+	err = tx.Where("id = ? AND user_id = ? AND status IN (?)", cartID, userID, openCartStatuses).First(&cart).Error
+	if err == gorm.ErrRecordNotFound {
+		return cart, &cartError{status: http.StatusNotFound, body: gin.H{"error": "Cart not found"}}
+	}
+	return cart, err
+}
+
+// cartName trims name and checks that no other open cart of userID has it,
+// regardless of case.
+func cartName(tx *gorm.DB, userID uint, name string, exceptID uint) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", &cartError{status: http.StatusBadRequest, body: gin.H{"error": "Name is required"}}
+	}
+	var taken int
+	if err := tx.Model(&models.Cart{}).
+		Where("user_id = ? AND status IN (?) AND id <> ? AND LOWER(name) = LOWER(?)", userID, openCartStatuses, exceptID, name).
+		Count(&taken).Error; err != nil {
+		return "", err
+	}
+	if taken > 0 {
+		return "", &cartError{status: http.StatusConflict, body: gin.H{"error": "You already have a cart with this name"}}
+	}
+	return name, nil
+}
+
+// saveCurrentCart turns the current cart of userID, if any, into a saved
+// one.
+func saveCurrentCart(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.Cart{}).
+		Where("user_id = ? AND status = ?", userID, models.CartStatusActive).
+		UpdateColumn("status", models.CartStatusSaved).Error
+}
+
+// makeCartCurrent makes a saved cart the current one of its user, saving
+// the previous current cart. It counts as using the cart.
+func makeCartCurrent(tx *gorm.DB, cart *models.Cart) error {
+	if err := saveCurrentCart(tx, *cart.UserID); err != nil {
+		return err
+	}
+	if err := tx.Model(cart).UpdateColumns(map[string]interface{}{
+		"status":     models.CartStatusActive,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+	cart.Status = models.CartStatusActive
+	return nil
+}
+
+// ListCarts returns the open carts of the current user, the current one
+// first, then the most recently used.
+func (h *CartHandler) ListCarts(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var carts []models.Cart
+	if err := h.DB.Where("user_id = ? AND status IN (?)", userID, openCartStatuses).
+		Order("CASE WHEN status = 'active' THEN 0 ELSE 1 END, updated_at DESC, id DESC").
+		Find(&carts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch carts"})
+		return
+	}
+
+	ids := make([]uint, 0, len(carts))
+	for _, cart := range carts {
+		ids = append(ids, cart.ID)
+	}
+	lines := make(map[uint]int)
+	for _, table := range []string{"cart_items", "cart_bundles"} {
+		if len(ids) == 0 {
+			break
+		}
+		var counts []struct {
+			CartID uint `gorm:"column:cart_id"`
+			Lines  int  `gorm:"column:lines"`
+		}
+		if err := h.DB.Table(table).Select("cart_id, COUNT(*) AS lines").
+			Where("cart_id IN (?)", ids).Group("cart_id").Scan(&counts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch carts"})
+			return
+		}
+		for _, count := range counts {
+			lines[count.CartID] += count.Lines
+		}
+	}
+
+	summaries := make([]CartSummary, 0, len(carts))
+	for _, cart := range carts {
+		summaries = append(summaries, CartSummary{
+			ID:        cart.ID,
+			Name:      cart.Name,
+			Current:   cart.Status == models.CartStatusActive,
+			Lines:     lines[cart.ID],
+			CreatedAt: cart.CreatedAt,
+			UpdatedAt: cart.UpdatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, summaries)
+}
+
+// CreateCart creates an empty named cart for the current user.
+func (h *CartHandler) CreateCart(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	var req CreateCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	tx := h.DB.Begin()
+	name, err := cartName(tx, userID, req.Name, 0)
+	if err != nil {
+		tx.Rollback()
+		respondCartError(c, err)
+		return
+	}
+	cart := models.Cart{UserID: &userID, Name: name, Status: models.CartStatusSaved}
+	if req.Current {
+		if err := saveCurrentCart(tx, userID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create cart", "details": err.Error()})
+			return
+		}
+		cart.Status = models.CartStatusActive
+	} else if _, err := findActiveCart(tx, &userID, ""); err == gorm.ErrRecordNotFound {
+		cart.Status = models.CartStatusActive
+	} else if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create cart", "details": err.Error()})
+		return
+	}
+	if err := tx.Create(&cart).Error; err != nil {
+		tx.Rollback()
+		// Another request made a current cart meanwhile
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Carts changed meanwhile, please retry"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create cart", "details": err.Error()})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create cart", "details": err.Error()})
+		return
+	}
+
+	log.Printf("Created cart %d %q for user %d", cart.ID, cart.Name, userID)
+	c.JSON(http.StatusCreated, CartSummary{
+		ID:        cart.ID,
+		Name:      cart.Name,
+		Current:   cart.Status == models.CartStatusActive,
+		CreatedAt: cart.CreatedAt,
+		UpdatedAt: cart.UpdatedAt,
+	})
+}
+
+// RenameCart renames one of the current user's carts.
+func (h *CartHandler) RenameCart(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	var req RenameCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	cart, err := findUserCart(h.DB, userID, c.Param("id"))
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+	name, err := cartName(h.DB, userID, req.Name, cart.ID)
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+	if err := h.DB.Model(&cart).UpdateColumn("name", name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename cart", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": cart.ID, "name": name, "current": cart.Status == models.CartStatusActive})
+}
+
+// SwitchCart makes one of the current user's carts the current one, saving
+// the previous, and responds with it like GetCart. It counts as using the
+// cart, so a long saved cart does not expire right away.
+func (h *CartHandler) SwitchCart(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tx := h.DB.Begin()
+	cart, err := findUserCart(tx, userID, c.Param("id"))
+	if err != nil {
+		tx.Rollback()
+		respondCartError(c, err)
+		return
+	}
+	if cart.Status != models.CartStatusActive {
+		if err := makeCartCurrent(tx, &cart); err != nil {
+			tx.Rollback()
+			if isUniqueViolation(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Carts changed meanwhile, please retry"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to switch cart", "details": err.Error()})
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to switch cart", "details": err.Error()})
+		return
+	}
+
+	log.Printf("User %d switched to cart %d", userID, cart.ID)
+	h.renderCart(c, h.DB, cart)
+}
+
+// DeleteCart deletes one of the current user's carts with its lines. After
+// deleting the current cart there is none until the next add to the cart
+// or switch.
+func (h *CartHandler) DeleteCart(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tx := h.DB.Begin()
+	cart, err := findUserCart(tx, userID, c.Param("id"))
+	if err != nil {
+		tx.Rollback()
+		respondCartError(c, err)
+		return
+	}
+	if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete cart", "details": err.Error()})
+		return
+	}
+	if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.CartBundle{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete cart", "details": err.Error()})
+		return
+	}
+	if err := tx.Delete(&cart).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete cart", "details": err.Error()})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete cart", "details": err.Error()})
+		return
+	}
+
+	log.Printf("Deleted cart %d of user %d", cart.ID, userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Cart deleted successfully"})
+}
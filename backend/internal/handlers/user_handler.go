@@ -10,10 +10,13 @@ import (
 	"github.com/jinzhu/gorm"
 	"golang.org/x/crypto/bcrypt"
 	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/signing"
 )
 
 type UserHandler struct {
 	DB *gorm.DB
+	// Signer verifies the opt-out links of cart reminders
+	Signer *signing.Signer
 }
 
 func NewUserHandler(db *gorm.DB) *UserHandler {
@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+	"ecommerce-app/internal/money"
+)
+
+type WishlistHandler struct {
+	DB *gorm.DB
+}
+
+func NewWishlistHandler(db *gorm.DB) *WishlistHandler {
+	return &WishlistHandler{DB: db}
+}
+
+type WishlistRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type AddWishlistItemRequest struct {
+	ItemID uint `json:"item_id" binding:"required"`
+}
+
+// WishlistItemResponse is a saved item with its current price and whether
+// it can be put in the cart right now.
+type WishlistItemResponse struct {
+	ItemID      uint        `json:"item_id"`
+	SKU         string      `json:"sku,omitempty"`
+	Name        string      `json:"name"`
+	Price       money.Money `json:"price"`
+	Status      string      `json:"status"`
+	Purchasable bool        `json:"purchasable"`
+	AddedAt     time.Time   `json:"added_at"`
+}
+
+// newShareToken returns a random token for a wishlist's public link.
+func newShareToken() (string, error) {
+	return randomHex(16)
+}
+
+// loadWishlist returns the wishlist from the :id parameter if it belongs to
+// the current user. On failure it writes the response and returns false.
+func (h *WishlistHandler) loadWishlist(c *gin.Context) (models.Wishlist, bool) {
+	var wishlist models.Wishlist
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return wishlist, false
+	}
+
+	if err := h.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&wishlist).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wishlist not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wishlist"})
+		}
+		return wishlist, false
+	}
+	return wishlist, true
+}
+
+// wishlistItems returns the items on a wishlist, most recently added first.
+func (h *WishlistHandler) wishlistItems(wishlistID uint) ([]WishlistItemResponse, error) {
+	var saved []models.WishlistItem
+	if err := h.DB.Where("wishlist_id = ?", wishlistID).Order("created_at DESC, id DESC").Find(&saved).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(saved))
+	for _, s := range saved {
+		ids = append(ids, s.ItemID)
+	}
+	var items []models.Item
+	if len(ids) > 0 {
+		if err := h.DB.Where("id IN (?)", ids).Find(&items).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[uint]models.Item, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	// Items hidden since they were saved stay listed but cannot be bought
+	now := time.Now()
+	response := make([]WishlistItemResponse, 0, len(saved))
+	for _, s := range saved {
+		item, ok := byID[s.ItemID]
+		if !ok {
+			continue
+		}
+		response = append(response, WishlistItemResponse{
+			ItemID:      item.ID,
+			SKU:         item.SKU,
+			Name:        item.Name,
+			Price:       item.Price,
+			Status:      item.Status,
+			Purchasable: item.Purchasable(1) && item.Visible(now),
+			AddedAt:     s.CreatedAt,
+		})
+	}
+	return response, nil
+}
+
+// ListWishlists returns the current user's wishlists with their item counts.
+func (h *WishlistHandler) ListWishlists(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	wishlists := []models.Wishlist{}
+	if err := h.DB.Where("user_id = ?", userID).Order("name").Find(&wishlists).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wishlists"})
+		return
+	}
+
+	counts := make(map[uint]int)
+	rows, err := h.DB.Table("wishlist_items").
+		Select("wishlist_id, COUNT(*)").
+		Where("wishlist_id IN (SELECT id FROM wishlists WHERE user_id = ?)", userID).
+		Group("wishlist_id").
+		Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wishlists"})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uint
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wishlists"})
+			return
+		}
+		counts[id] = count
+	}
+
+	response := make([]gin.H, 0, len(wishlists))
+	for _, w := range wishlists {
+		response = append(response, gin.H{
+			"id":          w.ID,
+			"name":        w.Name,
+			"share_token": w.ShareToken,
+			"item_count":  counts[w.ID],
+			"created_at":  w.CreatedAt,
+			"updated_at":  w.UpdatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateWishlist adds a named wishlist for the current user, e.g. "Saved
+// for later". Names are unique per user.
+func (h *WishlistHandler) CreateWishlist(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req WishlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create wishlist"})
+		return
+	}
+	wishlist := models.Wishlist{UserID: userID, Name: name, ShareToken: token}
+	if err := h.DB.Create(&wishlist).Error; err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You already have a wishlist with this name"})
+			return
+		}
+		log.Printf("Error creating wishlist: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create wishlist"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, wishlist)
+}
+
+// GetWishlist returns one of the current user's wishlists with its items.
+func (h *WishlistHandler) GetWishlist(c *gin.Context) {
+	wishlist, ok := h.loadWishlist(c)
+	if !ok {
+		return
+	}
+
+	items, err := h.wishlistItems(wishlist.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wishlist items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":          wishlist.ID,
+		"name":        wishlist.Name,
+		"share_token": wishlist.ShareToken,
+		"items":       items,
+	})
+}
+
+// DeleteWishlist removes a wishlist and the items saved on it.
+func (h *WishlistHandler) DeleteWishlist(c *gin.Context) {
+	wishlist, ok := h.loadWishlist(c)
+	if !ok {
+		return
+	}
+
+	tx := h.DB.Begin()
+	if err := tx.Where("wishlist_id = ?", wishlist.ID).Delete(&models.WishlistItem{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete wishlist"})
+		return
+	}
+	if err := tx.Delete(&wishlist).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete wishlist"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete wishlist"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// AddWishlistItem saves an item on a wishlist. Unavailable items can be
+// saved too; adding an item that is already on the list changes nothing.
+func (h *WishlistHandler) AddWishlistItem(c *gin.Context) {
+	wishlist, ok := h.loadWishlist(c)
+	if !ok {
+		return
+	}
+
+	var req AddWishlistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	var item models.Item
+	if err := h.DB.First(&item, req.ItemID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item"})
+		}
+		return
+	}
+
+	status := http.StatusCreated
+	saved := models.WishlistItem{WishlistID: wishlist.ID, ItemID: item.ID}
+	if err := h.DB.Create(&saved).Error; err != nil {
+		if !isUniqueViolation(err) {
+			log.Printf("Error adding item %d to wishlist %d: %v", item.ID, wishlist.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item to wishlist"})
+			return
+		}
+		status = http.StatusOK
+	}
+
+	items, err := h.wishlistItems(wishlist.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wishlist items"})
+		return
+	}
+	c.JSON(status, gin.H{
+		"id":    wishlist.ID,
+		"name":  wishlist.Name,
+		"items": items,
+	})
+}
+
+// RemoveWishlistItem takes an item off a wishlist.
+func (h *WishlistHandler) RemoveWishlistItem(c *gin.Context) {
+	wishlist, ok := h.loadWishlist(c)
+	if !ok {
+		return
+	}
+
+	res := h.DB.Where("wishlist_id = ? AND item_id = ?", wishlist.ID, c.Param("itemID")).Delete(&models.WishlistItem{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove item from wishlist"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item is not on this wishlist"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// MoveToCart puts a saved item in the user's active cart and takes it off
+// the wishlist. The item goes through the same checks as AddToCart, and
+// stays on the wishlist when they fail.
+func (h *WishlistHandler) MoveToCart(c *gin.Context) {
+	wishlist, ok := h.loadWishlist(c)
+	if !ok {
+		return
+	}
+
+	var saved models.WishlistItem
+	if err := h.DB.Where("wishlist_id = ? AND item_id = ?", wishlist.ID, c.Param("itemID")).First(&saved).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item is not on this wishlist"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wishlist item"})
+		}
+		return
+	}
+
+	tx := h.DB.Begin()
+	userID := wishlist.UserID
+	cart, err := activeCart(tx, &userID, "")
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find cart", "details": err.Error()})
+		return
+	}
+	if err := addItemToCart(tx, cart.ID, saved.ItemID, 1); err != nil {
+		tx.Rollback()
+		respondCartError(c, err)
+		return
+	}
+	if err := tx.Where("wishlist_id = ? AND item_id = ?", wishlist.ID, saved.ItemID).Delete(&models.WishlistItem{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove item from wishlist"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move item to cart", "details": err.Error()})
+		return
+	}
+
+	log.Printf("Moved item %d from wishlist %d to cart %d", saved.ItemID, wishlist.ID, cart.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Item moved to cart",
+		"cart_id":     cart.ID,
+		"item_id":     saved.ItemID,
+		"wishlist_id": wishlist.ID,
+	})
+}
+
+// RotateShareToken replaces the share token of a wishlist, so links shared
+// before stop working.
+func (h *WishlistHandler) RotateShareToken(c *gin.Context) {
+	wishlist, ok := h.loadWishlist(c)
+	if !ok {
+		return
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate share token"})
+		return
+	}
+	if err := h.DB.Model(&wishlist).Update("share_token", token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update share token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": wishlist.ID, "share_token": token})
+}
+
+// GetSharedWishlist shows a wishlist to anyone with its share token. The
+// owner is not revealed.
+func (h *WishlistHandler) GetSharedWishlist(c *gin.Context) {
+	var wishlist models.Wishlist
+	if err := h.DB.Where("share_token = ?", c.Param("token")).First(&wishlist).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wishlist not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wishlist"})
+		}
+		return
+	}
+
+	items, err := h.wishlistItems(wishlist.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wishlist items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":  wishlist.Name,
+		"items": items,
+	})
+}
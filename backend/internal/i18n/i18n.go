@@ -0,0 +1,132 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locales are the locales the catalog is offered in. Content stored on items
+// themselves is in Default; other locales come from translations.
+type Locales struct {
+	Default   string
+	Supported []string
+}
+
+// ParseLocales reads a comma separated list such as "en,de-AT". The first
+// locale is the default. An empty list means English only.
+func ParseLocales(list string) Locales {
+	var supported []string
+	for _, tag := range strings.Split(list, ",") {
+		if tag = Normalize(tag); tag != "" {
+			supported = append(supported, tag)
+		}
+	}
+	if len(supported) == 0 {
+		supported = []string{"en"}
+	}
+	return Locales{Default: supported[0], Supported: supported}
+}
+
+// Normalize lowercases the language and uppercases the region of a tag,
+// so "EN_us" becomes "en-US".
+func Normalize(tag string) string {
+	parts := strings.Split(strings.Replace(strings.TrimSpace(tag), "_", "-", -1), "-")
+	if parts[0] == "" {
+		return ""
+	}
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if len(parts[i]) == 2 {
+			parts[i] = strings.ToUpper(parts[i])
+		} else {
+			parts[i] = strings.ToLower(parts[i])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// IsSupported reports whether tag is one of the supported locales.
+func (l Locales) IsSupported(tag string) bool {
+	tag = Normalize(tag)
+	for _, s := range l.Supported {
+		if s == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the supported locale for tag: an exact match, or else the
+// first supported locale of the same language. It returns "" when there is
+// none.
+func (l Locales) Match(tag string) string {
+	tag = Normalize(tag)
+	if tag == "" {
+		return ""
+	}
+	if l.IsSupported(tag) {
+		return tag
+	}
+	lang := strings.SplitN(tag, "-", 2)[0]
+	for _, s := range l.Supported {
+		if strings.SplitN(s, "-", 2)[0] == lang {
+			return s
+		}
+	}
+	return ""
+}
+
+// Negotiate picks the locale for a request. An explicit locale, from a
+// ?locale= parameter, wins over the Accept-Language header; anything that
+// matches no supported locale falls back to the default.
+func (l Locales) Negotiate(explicit, acceptLanguage string) string {
+	if m := l.Match(explicit); m != "" {
+		return m
+	}
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if tag == "*" {
+			break
+		}
+		if m := l.Match(tag); m != "" {
+			return m
+		}
+	}
+	return l.Default
+}
+
+// parseAcceptLanguage returns the tags of an Accept-Language header, most
+// preferred first. Tags with q=0 are left out.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	result := make([]string, 0, len(tags))
+	for _, t := range tags {
+		result = append(result, t.tag)
+	}
+	return result
+}
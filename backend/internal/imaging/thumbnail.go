@@ -0,0 +1,112 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// Thumbnail scales src down so that neither side exceeds maxSize, keeping the
+// aspect ratio. Images that already fit are copied unchanged. Each destination
+// pixel is the average of the source pixels it covers, which keeps downscaled
+// product photos smooth without pulling in an external imaging library.
+func Thumbnail(src image.Image, maxSize int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if w > maxSize || h > maxSize {
+		if w >= h {
+			dw, dh = maxSize, h*maxSize/w
+		} else {
+			dw, dh = w*maxSize/h, maxSize
+		}
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	// Work on a zero-based RGBA copy so pixel access is cheap
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	if dw == w && dh == h {
+		return rgba
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := y * h / dh
+		y1 := (y + 1) * h / dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0 := x * w / dw
+			x1 := (x + 1) * w / dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				off := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(rgba.Pix[off])
+					g += uint32(rgba.Pix[off+1])
+					bl += uint32(rgba.Pix[off+2])
+					a += uint32(rgba.Pix[off+3])
+					off += 4
+					n++
+				}
+			}
+
+			off := dst.PixOffset(x, y)
+			dst.Pix[off] = uint8(r / n)
+			dst.Pix[off+1] = uint8(g / n)
+			dst.Pix[off+2] = uint8(bl / n)
+			dst.Pix[off+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// Decode reads a JPEG, PNG or GIF image and reports its format.
+func Decode(r io.Reader) (image.Image, string, error) {
+	return image.Decode(r)
+}
+
+// DecodeConfig reads only the dimensions and format of an image, which is
+// cheap however large the image claims to be.
+func DecodeConfig(r io.Reader) (image.Config, string, error) {
+	return image.DecodeConfig(r)
+}
+
+// Encode writes img in the given format. JPEG is used for anything that is
+// not PNG or GIF since it is the smallest choice for photos.
+func Encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	default:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	}
+}
+
+// ContentType returns the MIME type for an image format reported by Decode.
+func ContentType(format string) string {
+	switch format {
+	case "png":
+		return "image/png"
+	case "gif":
+		return "image/gif"
+	default:
+		return "image/jpeg"
+	}
+}
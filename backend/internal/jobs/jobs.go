@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Every runs fn once right away and then every interval until ctx is
+// cancelled. Errors are logged and do not stop later runs.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil {
+			log.Printf("Job %s failed: %v", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DurationFromEnv reads a duration such as "15m" from the environment,
+// falling back to def when the variable is unset or invalid.
+func DurationFromEnv(env string, def time.Duration) time.Duration {
+	v := os.Getenv(env)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid duration %q in %s, using %s", v, env, def)
+		return def
+	}
+	return d
+}
+
+// IntFromEnv reads a non-negative integer from the environment, falling
+// back to def when the variable is unset or invalid.
+func IntFromEnv(env string, def int) int {
+	v := os.Getenv(env)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Printf("Invalid number %q in %s, using %d", v, env, def)
+		return def
+	}
+	return n
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ecommerce-app/internal/catalog"
+)
+
+// bodyRecorder copies the response body so it can be cached.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// CatalogCache serves GET requests for catalog data conditionally. Responses
+// carry an ETag and Last-Modified for the current catalog version; requests
+// whose If-None-Match or If-Modified-Since still match get 304 Not Modified,
+// and other repeats are answered from the in-process cache without running
+// the handler. Responses are cached per locale, so Locale must run first.
+func CatalogCache(cache *catalog.Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		v, err := cache.Version()
+		if err != nil {
+			// Serve uncached rather than fail the request
+			log.Printf("Failed to read catalog version: %v", err)
+			c.Next()
+			return
+		}
+
+		locale := c.GetString("locale")
+		etag := `"` + v.ETag + `"`
+		if locale != "" {
+			etag = `"` + v.ETag + "-" + locale + `"`
+		}
+		c.Header("ETag", etag)
+		if !v.LastModified.IsZero() {
+			c.Header("Last-Modified", v.LastModified.Format(http.TimeFormat))
+		}
+		// Clients may store responses but must revalidate them
+		c.Header("Cache-Control", "no-cache")
+
+		if notModified(c.Request, etag, v.LastModified) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+
+		key := locale + " " + c.Request.URL.RequestURI()
+		if contentType, body, ok := cache.Get(key, v); ok {
+			c.Data(http.StatusOK, contentType, body)
+			c.Abort()
+			return
+		}
+
+		rec := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+
+		if rec.Status() == http.StatusOK {
+			cache.Put(key, v, rec.Header().Get("Content-Type"), rec.body.Bytes())
+		}
+	}
+}
+
+// notModified reports whether the client's copy is current. If-None-Match
+// takes precedence over If-Modified-Since, as required by RFC 7232.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
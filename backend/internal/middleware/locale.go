@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"ecommerce-app/internal/i18n"
+)
+
+// Locale picks the locale of the response from ?locale= or the
+// Accept-Language header and stores it in the context as "locale", next to
+// the default locale as "defaultLocale".
+func Locale(locales i18n.Locales) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := locales.Negotiate(c.Query("locale"), c.GetHeader("Accept-Language"))
+		c.Set("locale", locale)
+		c.Set("defaultLocale", locales.Default)
+		c.Header("Content-Language", locale)
+		c.Writer.Header().Add("Vary", "Accept-Language")
+		c.Next()
+	}
+}
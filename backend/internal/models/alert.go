@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"ecommerce-app/internal/money"
+)
+
+// Kinds of item alerts a user can subscribe to.
+const (
+	AlertKindBackInStock = "back_in_stock"
+	AlertKindPriceDrop   = "price_drop"
+)
+
+// Alert statuses. An alert fires once and is then triggered; users
+// subscribe again to be told the next time.
+const (
+	AlertStatusActive    = "active"
+	AlertStatusTriggered = "triggered"
+	AlertStatusCancelled = "cancelled"
+)
+
+// ItemAlert is a user's subscription to be notified about an item. Price
+// drop alerts fire when the price falls below BasePrice, the price when the
+// user subscribed, and, when set, to TargetPrice or lower.
+type ItemAlert struct {
+	ID          uint        `gorm:"primary_key" json:"id"`
+	UserID      uint        `gorm:"not null;index" json:"user_id"`
+	ItemID      uint        `gorm:"not null;index" json:"item_id"`
+	Kind        string      `gorm:"size:32;not null" json:"kind"`
+	Status      string      `gorm:"size:16;not null;default:'active'" json:"status"`
+	BasePrice   money.Money `gorm:"embedded;embedded_prefix:base_price_" json:"base_price"`
+	TargetPrice money.Money `gorm:"embedded;embedded_prefix:target_price_" json:"target_price"`
+	TriggeredAt *time.Time  `gorm:"default:null" json:"triggered_at,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// Item event types.
+const (
+	ItemEventPriceChanged  = "price_changed"
+	ItemEventStatusChanged = "status_changed"
+)
+
+// ItemEvent records a change to an item that others may react to, such as
+// alerts. Events are written in the same transaction as the change and
+// processed later, so a rolled back change never fires one.
+type ItemEvent struct {
+	ID          uint        `gorm:"primary_key" json:"id"`
+	ItemID      uint        `gorm:"not null;index" json:"item_id"`
+	Type        string      `gorm:"size:32;not null" json:"type"`
+	OldStatus   string      `gorm:"size:32" json:"old_status,omitempty"`
+	NewStatus   string      `gorm:"size:32" json:"new_status,omitempty"`
+	OldPrice    money.Money `gorm:"embedded;embedded_prefix:old_price_" json:"old_price"`
+	NewPrice    money.Money `gorm:"embedded;embedded_prefix:new_price_" json:"new_price"`
+	Attempts    int         `gorm:"not null;default:0" json:"attempts"`
+	LastError   string      `gorm:"type:text" json:"last_error,omitempty"`
+	ProcessedAt *time.Time  `gorm:"default:null;index" json:"processed_at,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// Attribute types. Enum values must be one of the attribute's options.
+const (
+	AttributeTypeEnum    = "enum"
+	AttributeTypeNumber  = "number"
+	AttributeTypeBoolean = "boolean"
+)
+
+// AttributeDefinition describes a product spec shoppers can filter on, such
+// as brand, RAM or Wi-Fi support. Code is the key used in filters.
+type AttributeDefinition struct {
+	ID        uint              `gorm:"primary_key" json:"id"`
+	Code      string            `gorm:"size:64;not null;unique_index" json:"code"`
+	Name      string            `gorm:"not null" json:"name"`
+	Type      string            `gorm:"size:16;not null" json:"type"`
+	Unit      string            `gorm:"size:16" json:"unit,omitempty"`
+	Position  int               `gorm:"not null;default:0" json:"position"`
+	Options   []AttributeOption `gorm:"foreignkey:AttributeID" json:"options,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// AttributeOption is one allowed value of an enum attribute.
+type AttributeOption struct {
+	ID          uint   `gorm:"primary_key" json:"-"`
+	AttributeID uint   `gorm:"not null;unique_index:idx_attribute_options_value" json:"-"`
+	Value       string `gorm:"not null;unique_index:idx_attribute_options_value" json:"value"`
+	Position    int    `gorm:"not null;default:0" json:"position"`
+}
+
+// ItemAttributeValue is the value of one attribute for an item. Only the
+// column matching the attribute type is set.
+type ItemAttributeValue struct {
+	ID          uint      `gorm:"primary_key" json:"-"`
+	ItemID      uint      `gorm:"not null;unique_index:idx_item_attribute_values_item_attribute" json:"item_id"`
+	AttributeID uint      `gorm:"not null;unique_index:idx_item_attribute_values_item_attribute;index" json:"attribute_id"`
+	TextValue   *string   `gorm:"default:null" json:"text_value,omitempty"`
+	NumberValue *float64  `gorm:"default:null" json:"number_value,omitempty"`
+	BoolValue   *bool     `gorm:"default:null" json:"bool_value,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
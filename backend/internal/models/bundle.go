@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"ecommerce-app/internal/money"
+)
+
+// Bundle is a kit of items sold together at its own price. It has no stock
+// of its own; availability follows from its components.
+type Bundle struct {
+	ID         uint              `gorm:"primary_key" json:"id"`
+	SKU        string            `gorm:"column:sku;size:64;not null;unique_index" json:"sku"`
+	Name       string            `gorm:"not null;unique_index" json:"name"`
+	Price      money.Money       `gorm:"embedded;embedded_prefix:price_" json:"price"`
+	Components []BundleComponent `gorm:"foreignkey:BundleID" json:"components"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// BundleComponent is Quantity units of an item in every unit of a bundle.
+type BundleComponent struct {
+	ID       uint `gorm:"primary_key" json:"-"`
+	BundleID uint `gorm:"not null;unique_index:idx_bundle_components_item" json:"-"`
+	ItemID   uint `gorm:"not null;unique_index:idx_bundle_components_item" json:"item_id"`
+	Quantity int  `gorm:"not null;default:1" json:"quantity"`
+}
+
+// Purchasable reports whether quantity units of the bundle can be put in a
+// cart, given its component items keyed by ID.
+func (b Bundle) Purchasable(quantity int, items map[uint]Item) bool {
+	if len(b.Components) == 0 {
+		return false
+	}
+	for _, comp := range b.Components {
+		item, ok := items[comp.ItemID]
+		if !ok || !item.Purchasable(comp.Quantity*quantity) {
+			return false
+		}
+	}
+	return true
+}
+
+// Stock returns how many complete bundles the components on hand make up.
+func (b Bundle) Stock(items map[uint]Item) int {
+	stock := -1
+	for _, comp := range b.Components {
+		item := items[comp.ItemID]
+		n := 0
+		if item.Stock > 0 && comp.Quantity > 0 {
+			n = item.Stock / comp.Quantity
+		}
+		if stock < 0 || n < stock {
+			stock = n
+		}
+	}
+	if stock < 0 {
+		return 0
+	}
+	return stock
+}
+
+// Status is ItemStatusAvailable when one bundle can be bought and
+// ItemStatusOutOfStock otherwise.
+func (b Bundle) Status(items map[uint]Item) string {
+	if b.Purchasable(1, items) {
+		return ItemStatusAvailable
+	}
+	return ItemStatusOutOfStock
+}
+
+// CartBundle is a bundle in a cart. It is a single line however many
+// components the bundle has.
+type CartBundle struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CartID    uint      `gorm:"not null;unique_index:idx_cart_bundles_bundle" json:"-"`
+	BundleID  uint      `gorm:"not null;unique_index:idx_cart_bundles_bundle" json:"bundle_id"`
+	Quantity  int       `gorm:"not null;default:1" json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// UnitPrice is the bundle's price when it was last added to the cart
+	UnitPrice money.Money `gorm:"embedded;embedded_prefix:unit_price_" json:"unit_price"`
+}
@@ -2,22 +2,50 @@ package models
 
 import (
 	"time"
+
+	"ecommerce-app/internal/money"
+)
+
+// Cart statuses. A user or session has at most one active cart, the
+// current one. Users may keep other named carts saved for later; the rest
+// were ordered, superseded by a duplicate or expired.
+const (
+	CartStatusActive     = "active"
+	CartStatusSaved      = "saved"
+	CartStatusOrdered    = "ordered"
+	CartStatusSuperseded = "superseded"
+	CartStatusExpired    = "expired"
 )
 
 type Cart struct {
-	ID        uint       `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
-	UserID    *uint      `gorm:"default:null" json:"user_id"`
-	SessionID string     `gorm:"size:255;default:'';index" json:"-"`
-	Status    string     `gorm:"default:'active'" json:"status"`
-	Items     []CartItem `gorm:"foreignkey:CartID" json:"items,omitempty"`
-	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	ID        uint         `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID    *uint        `gorm:"default:null" json:"user_id"`
+	SessionID string       `gorm:"size:255;default:'';index" json:"-"`
+	Status    string       `gorm:"default:'active';index:idx_carts_status_updated_at" json:"status"`
+	Items     []CartItem   `gorm:"foreignkey:CartID" json:"items,omitempty"`
+	Bundles   []CartBundle `gorm:"foreignkey:CartID" json:"bundles,omitempty"`
+	CreatedAt time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time    `gorm:"autoUpdateTime;index:idx_carts_status_updated_at" json:"updated_at"`
+
+	// Abandoned cart reminders sent for this cart, and when the last went out
+	ReminderCount int        `gorm:"not null;default:0" json:"-"`
+	RemindedAt    *time.Time `gorm:"default:null" json:"-"`
+
+	// CouponID is the coupon applied to the cart, if any
+	CouponID *uint `gorm:"default:null;index" json:"coupon_id,omitempty"`
+
+	// Name tells a user's carts apart; carts started by adding an item
+	// have none
+	Name string `gorm:"size:100;not null;default:''" json:"name"`
 }
 
 type CartItem struct {
-	CartID    uint      `gorm:"primaryKey;index" json:"-"`
-	ItemID    uint      `gorm:"primaryKey" json:"item_id"`
+	CartID    uint      `gorm:"primaryKey;index;unique_index:idx_cart_items_cart_item" json:"-"`
+	ItemID    uint      `gorm:"primaryKey;unique_index:idx_cart_items_cart_item" json:"item_id"`
 	Quantity  int       `gorm:"default:1" json:"quantity"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// UnitPrice is the item's price when it was last added to the cart
+	UnitPrice money.Money `gorm:"embedded;embedded_prefix:unit_price_" json:"unit_price"`
 }
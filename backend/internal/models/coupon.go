@@ -0,0 +1,81 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"ecommerce-app/internal/money"
+)
+
+// Coupon types. Percent coupons take PercentOff percent off, fixed coupons
+// take AmountOff off.
+const (
+	CouponTypePercent = "percent"
+	CouponTypeFixed   = "fixed"
+)
+
+// CategoryAttribute is the code of the enum attribute whose values are the
+// categories coupons can be limited to.
+const CategoryAttribute = "category"
+
+// Coupon is a promotion code shoppers apply to their cart. A coupon without
+// eligible items or categories discounts the whole cart; otherwise only
+// the lines of eligible items. Zero limits mean no limit.
+type Coupon struct {
+	ID          uint   `gorm:"primary_key" json:"id"`
+	Code        string `gorm:"size:64;not null;unique_index" json:"code"`
+	Description string `gorm:"not null;default:''" json:"description"`
+	Type        string `gorm:"size:16;not null" json:"type"`
+	// PercentOff is a whole percentage for percent coupons
+	PercentOff int         `gorm:"not null;default:0" json:"percent_off,omitempty"`
+	AmountOff  money.Money `gorm:"embedded;embedded_prefix:amount_off_" json:"amount_off"`
+	// MinSpend is what the cart's goods must cost, after line discounts
+	MinSpend       money.Money `gorm:"embedded;embedded_prefix:min_spend_" json:"min_spend"`
+	MaxUses        int         `gorm:"not null;default:0" json:"max_uses"`
+	MaxUsesPerUser int         `gorm:"not null;default:0" json:"max_uses_per_user"`
+	// Uses counts redemptions; it only changes inside order transactions
+	Uses       int              `gorm:"not null;default:0" json:"uses"`
+	StartsAt   *time.Time       `gorm:"default:null" json:"starts_at"`
+	EndsAt     *time.Time       `gorm:"default:null" json:"ends_at"`
+	Items      []CouponItem     `gorm:"foreignkey:CouponID" json:"items,omitempty"`
+	Categories []CouponCategory `gorm:"foreignkey:CouponID" json:"categories,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+// BeforeSave stores codes in upper case, so that they match however
+// shoppers type them.
+func (c *Coupon) BeforeSave() error {
+	c.Code = NormalizeCouponCode(c.Code)
+	return nil
+}
+
+// NormalizeCouponCode returns code the way coupon codes are stored.
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// CouponItem makes an item eligible for a coupon.
+type CouponItem struct {
+	ID       uint `gorm:"primary_key" json:"-"`
+	CouponID uint `gorm:"not null;unique_index:idx_coupon_items_item" json:"-"`
+	ItemID   uint `gorm:"not null;unique_index:idx_coupon_items_item" json:"item_id"`
+}
+
+// CouponCategory makes the items of a category eligible for a coupon.
+// Category is a value of the CategoryAttribute attribute.
+type CouponCategory struct {
+	ID       uint   `gorm:"primary_key" json:"-"`
+	CouponID uint   `gorm:"not null;unique_index:idx_coupon_categories_category" json:"-"`
+	Category string `gorm:"not null;unique_index:idx_coupon_categories_category" json:"category"`
+}
+
+// CouponRedemption records the use of a coupon by an order.
+type CouponRedemption struct {
+	ID        uint        `gorm:"primary_key" json:"id"`
+	CouponID  uint        `gorm:"not null;index:idx_coupon_redemptions_user" json:"coupon_id"`
+	UserID    uint        `gorm:"not null;index:idx_coupon_redemptions_user" json:"user_id"`
+	OrderID   uint        `gorm:"not null;unique_index" json:"order_id"`
+	Discount  money.Money `gorm:"embedded;embedded_prefix:discount_" json:"discount"`
+	CreatedAt time.Time   `json:"created_at"`
+}
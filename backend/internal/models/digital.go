@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// DigitalAsset is a file delivered to buyers of a digital item, such as an
+// e-book or an installer. Files live in a private blob store and are only
+// reachable through signed download links.
+type DigitalAsset struct {
+	ID          uint      `gorm:"primary_key" json:"id"`
+	ItemID      uint      `gorm:"not null;index" json:"item_id"`
+	FileName    string    `gorm:"not null" json:"file_name"`
+	StorageKey  string    `gorm:"not null" json:"-"`
+	ContentType string    `gorm:"not null" json:"content_type"`
+	Size        int64     `gorm:"not null;default:0" json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// LicenseKey is a key in an item's pool. Keys are handed out in upload
+// order, one per unit ordered; OrderID is set once a key is assigned.
+type LicenseKey struct {
+	ID         uint       `gorm:"primary_key" json:"id"`
+	ItemID     uint       `gorm:"not null;unique_index:idx_license_keys_key" json:"item_id"`
+	Key        string     `gorm:"column:license_key;not null;unique_index:idx_license_keys_key" json:"key"`
+	OrderID    *uint      `gorm:"default:null;index" json:"order_id,omitempty"`
+	AssignedAt *time.Time `gorm:"default:null" json:"assigned_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// DownloadGrant lets the buyer of an order download one asset up to
+// MaxDownloads times.
+type DownloadGrant struct {
+	ID           uint      `gorm:"primary_key" json:"id"`
+	OrderID      uint      `gorm:"not null;index" json:"order_id"`
+	ItemID       uint      `gorm:"not null" json:"item_id"`
+	AssetID      uint      `gorm:"not null" json:"asset_id"`
+	Downloads    int       `gorm:"not null;default:0" json:"downloads"`
+	MaxDownloads int       `gorm:"not null" json:"max_downloads"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
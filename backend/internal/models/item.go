@@ -1,14 +1,100 @@
 package models
 
 import (
+	"strings"
 	"time"
+
+	"ecommerce-app/internal/money"
+)
+
+// Item statuses. An item moves to ItemStatusOutOfStock automatically when
+// its stock reaches zero and back to ItemStatusAvailable when restocked.
+const (
+	ItemStatusAvailable  = "available"
+	ItemStatusOutOfStock = "out_of_stock"
+)
+
+// Item visibilities. Draft items are hidden from shoppers; published items
+// are shown within their publish window.
+const (
+	ItemVisibilityPublished = "published"
+	ItemVisibilityDraft     = "draft"
 )
 
+// Item is a product in the catalog. Digital items are delivered as downloads
+// and license keys; they have no stock, and when they have license keys the
+// size of the key pool limits how many can be sold.
 type Item struct {
-	ID        uint      `gorm:"primary_key" json:"id"`
-	Name      string    `gorm:"not null" json:"name"`
-	Status    string    `gorm:"default:'available'" json:"status"`
-	Price     float64   `gorm:"not null" json:"price"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID             uint        `gorm:"primary_key" json:"id"`
+	SKU            string      `gorm:"column:sku;size:64;not null;unique_index" json:"sku"`
+	Name           string      `gorm:"not null" json:"name"`
+	Description    string      `gorm:"type:text;not null;default:''" json:"description"`
+	NormalizedName string      `gorm:"size:255;not null;unique_index" json:"-"`
+	Status         string      `gorm:"default:'available'" json:"status"`
+	Price          money.Money `gorm:"embedded;embedded_prefix:price_" json:"price"`
+	Stock          int         `gorm:"not null;default:0" json:"stock"`
+	AllowBackorder bool        `gorm:"not null;default:false" json:"allow_backorder"`
+	Digital        bool        `gorm:"not null;default:false" json:"digital"`
+	Visibility     string      `gorm:"size:16;not null;default:'published'" json:"visibility"`
+	PublishAt      *time.Time  `gorm:"index" json:"publish_at"`
+	UnpublishAt    *time.Time  `gorm:"index" json:"unpublish_at"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `gorm:"index" json:"updated_at"`
+
+	// MaxPerOrder and MinOrderQuantity limit how many units one cart may
+	// hold; zero means no limit.
+	MaxPerOrder      int `gorm:"not null;default:0" json:"max_per_order"`
+	MinOrderQuantity int `gorm:"not null;default:0" json:"min_order_quantity"`
+}
+
+// ItemTranslation holds an item's name and description in a locale other than
+// the catalog's default one, which is stored on the item itself.
+type ItemTranslation struct {
+	ID          uint      `gorm:"primary_key" json:"-"`
+	ItemID      uint      `gorm:"not null;unique_index:idx_item_translations_item_locale" json:"item_id"`
+	Locale      string    `gorm:"size:35;not null;unique_index:idx_item_translations_item_locale" json:"locale"`
+	Name        string    `gorm:"not null" json:"name"`
+	Description string    `gorm:"type:text;not null;default:''" json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// NormalizeItemName is the form of an item name that must be unique across
+// the catalog, so "Mouse" and " mouse" cannot both exist.
+func NormalizeItemName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// BeforeSave keeps NormalizedName in sync with Name.
+func (i *Item) BeforeSave() error {
+	i.NormalizedName = NormalizeItemName(i.Name)
+	if i.Visibility == "" {
+		i.Visibility = ItemVisibilityPublished
+	}
+	return nil
+}
+
+// Visible reports whether shoppers can see the item at now: it must be
+// published, and now must fall within its publish window where one is set.
+func (i Item) Visible(now time.Time) bool {
+	if i.Visibility != ItemVisibilityPublished {
+		return false
+	}
+	if i.PublishAt != nil && now.Before(*i.PublishAt) {
+		return false
+	}
+	return i.UnpublishAt == nil || now.Before(*i.UnpublishAt)
+}
+
+// Purchasable reports whether quantity units of the item can be put in a cart.
+// Backorderable items stay purchasable when they run out of stock, and
+// digital items whenever they are available.
+func (i Item) Purchasable(quantity int) bool {
+	if i.Digital {
+		return i.Status == ItemStatusAvailable
+	}
+	if i.AllowBackorder {
+		return i.Status == ItemStatusAvailable || i.Status == ItemStatusOutOfStock
+	}
+	return i.Status == ItemStatusAvailable && i.Stock >= quantity
 }
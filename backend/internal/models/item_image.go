@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// ItemImage is an uploaded product photo. The original and its thumbnail are
+// kept in a blob store; only their keys are stored here.
+type ItemImage struct {
+	ID           uint      `gorm:"primary_key" json:"id"`
+	ItemID       uint      `gorm:"not null;index" json:"item_id"`
+	Position     int       `gorm:"not null;default:0" json:"position"`
+	StorageKey   string    `gorm:"not null" json:"-"`
+	ThumbnailKey string    `gorm:"not null" json:"-"`
+	ContentType  string    `gorm:"not null" json:"content_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
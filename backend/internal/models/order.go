@@ -2,13 +2,56 @@ package models
 
 import (
 	"time"
+
+	"ecommerce-app/internal/money"
 )
 
 type Order struct {
-	ID        uint      `gorm:"primary_key" json:"id"`
-	UserID    uint      `gorm:"not null" json:"user_id"`
-	CartID    uint      `gorm:"not null" json:"cart_id"`
-	Status    string    `gorm:"default:'pending'" json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint        `gorm:"primary_key" json:"id"`
+	UserID    uint        `gorm:"not null" json:"user_id"`
+	CartID    uint        `gorm:"not null" json:"cart_id"`
+	Status    string      `gorm:"default:'pending'" json:"status"`
+	Total     money.Money `gorm:"embedded;embedded_prefix:total_" json:"total"`
+	Items     []OrderItem `gorm:"foreignkey:OrderID" json:"items,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+
+	// The price breakdown the total was computed from: Total is Subtotal
+	// less Discount plus Shipping and Tax
+	Subtotal    money.Money       `gorm:"embedded;embedded_prefix:subtotal_" json:"subtotal"`
+	Discount    money.Money       `gorm:"embedded;embedded_prefix:discount_" json:"discount"`
+	Shipping    money.Money       `gorm:"embedded;embedded_prefix:shipping_" json:"shipping"`
+	Tax         money.Money       `gorm:"embedded;embedded_prefix:tax_" json:"tax"`
+	Adjustments []OrderAdjustment `gorm:"foreignkey:OrderID" json:"adjustments,omitempty"`
+}
+
+// OrderAdjustment is a discount, shipping charge or tax applied by one of
+// the pricing calculators when the order was placed.
+type OrderAdjustment struct {
+	ID         uint        `gorm:"primary_key" json:"-"`
+	OrderID    uint        `gorm:"not null;index" json:"-"`
+	Calculator string      `gorm:"size:64;not null" json:"calculator"`
+	Kind       string      `gorm:"size:16;not null" json:"kind"`
+	Label      string      `gorm:"not null" json:"label"`
+	Amount     money.Money `gorm:"embedded;embedded_prefix:amount_" json:"amount"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// OrderItem records how many units of an item were ordered and how many of
+// those could not be reserved from stock and are waiting on a backorder.
+// Bundles are ordered as their components, each with BundleID set and the
+// bundle's saving spread over them as Discount. Line discounts from pricing
+// are part of Discount too, so the lines of an order always add up to its
+// subtotal less its line discounts.
+type OrderItem struct {
+	ID          uint        `gorm:"primary_key" json:"id"`
+	OrderID     uint        `gorm:"not null;index" json:"-"`
+	ItemID      uint        `gorm:"not null;index" json:"item_id"`
+	BundleID    *uint       `gorm:"default:null" json:"bundle_id,omitempty"`
+	Quantity    int         `gorm:"not null" json:"quantity"`
+	UnitPrice   money.Money `gorm:"embedded;embedded_prefix:unit_price_" json:"unit_price"`
+	Discount    money.Money `gorm:"embedded;embedded_prefix:discount_" json:"discount"`
+	Backordered int         `gorm:"not null;default:0" json:"backordered"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
 }
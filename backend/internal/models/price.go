@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"ecommerce-app/internal/money"
+)
+
+// Price change sources recorded in the price history.
+const (
+	PriceSourceManual   = "manual"
+	PriceSourceImport   = "import"
+	PriceSourceSchedule = "schedule"
+)
+
+// Scheduled price statuses.
+const (
+	ScheduledPricePending   = "pending"
+	ScheduledPriceActive    = "active"
+	ScheduledPriceEnded     = "ended"
+	ScheduledPriceCancelled = "cancelled"
+)
+
+// PriceChange is one entry in an item's price history. A row is written every
+// time the price of an item changes, whatever caused it.
+type PriceChange struct {
+	ID        uint        `gorm:"primary_key" json:"id"`
+	ItemID    uint        `gorm:"not null;index" json:"item_id"`
+	OldPrice  money.Money `gorm:"embedded;embedded_prefix:old_price_" json:"old_price"`
+	NewPrice  money.Money `gorm:"embedded;embedded_prefix:new_price_" json:"new_price"`
+	Source    string      `gorm:"not null" json:"source"`
+	ChangedBy *uint       `gorm:"default:null" json:"changed_by,omitempty"`
+	ChangedAt time.Time   `gorm:"not null;index" json:"changed_at"`
+}
+
+// ScheduledPrice is a future price for an item. The scheduler applies it at
+// StartsAt and, when EndsAt is set, restores BasePrice once it ends.
+type ScheduledPrice struct {
+	ID        uint        `gorm:"primary_key" json:"id"`
+	ItemID    uint        `gorm:"not null;index" json:"item_id"`
+	Price     money.Money `gorm:"embedded;embedded_prefix:price_" json:"price"`
+	BasePrice money.Money `gorm:"embedded;embedded_prefix:base_price_" json:"base_price"`
+	StartsAt  time.Time   `gorm:"not null;index" json:"starts_at"`
+	EndsAt    *time.Time  `gorm:"default:null" json:"ends_at,omitempty"`
+	Status    string      `gorm:"not null;default:'pending';index" json:"status"`
+	CreatedBy *uint       `gorm:"default:null" json:"created_by,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
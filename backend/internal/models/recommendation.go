@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// ItemRecommendation is a precomputed suggestion of RecommendedItemID to
+// shoppers looking at ItemID. Rows are replaced per strategy each time the
+// recommendations are refreshed.
+type ItemRecommendation struct {
+	ID                uint      `gorm:"primary_key" json:"-"`
+	Strategy          string    `gorm:"size:64;not null;unique_index:idx_item_recommendations_pair" json:"strategy"`
+	ItemID            uint      `gorm:"not null;unique_index:idx_item_recommendations_pair" json:"item_id"`
+	RecommendedItemID uint      `gorm:"not null;unique_index:idx_item_recommendations_pair" json:"recommended_item_id"`
+	Score             float64   `gorm:"not null" json:"score"`
+	Rank              int       `gorm:"not null" json:"rank"`
+	ComputedAt        time.Time `gorm:"not null" json:"computed_at"`
+}
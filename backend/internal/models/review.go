@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// Review moderation statuses. Only approved reviews are shown to shoppers.
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected"
+)
+
+// Review is a rating with optional text left by a customer who ordered the
+// item. A customer can review each item once.
+type Review struct {
+	ID          uint       `gorm:"primary_key" json:"id"`
+	ItemID      uint       `gorm:"not null;unique_index:idx_reviews_item_user" json:"item_id"`
+	UserID      uint       `gorm:"not null;unique_index:idx_reviews_item_user" json:"user_id"`
+	Rating      int        `gorm:"not null" json:"rating"`
+	Title       string     `gorm:"size:200" json:"title"`
+	Body        string     `gorm:"type:text" json:"body"`
+	Status      string     `gorm:"not null;default:'pending';index" json:"status"`
+	ModeratedBy *uint      `gorm:"default:null" json:"moderated_by,omitempty"`
+	ModeratedAt *time.Time `gorm:"default:null" json:"moderated_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -10,6 +10,10 @@ type User struct {
 	Password  string    `gorm:"not null" json:"-"`
 	Token     *string   `gorm:"unique;default:null" json:"token,omitempty"`
 	CartID    uint      `json:"cart_id,omitempty"`
+	IsAdmin   bool      `gorm:"not null;default:false" json:"is_admin"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// CartRemindersOptOut stops abandoned cart reminders
+	CartRemindersOptOut bool `gorm:"not null;default:false" json:"cart_reminders_opt_out"`
 }
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// Wishlist is a named list of items a user wants to keep track of without
+// putting them in the cart. Anyone with the share token can view the list.
+type Wishlist struct {
+	ID         uint           `gorm:"primary_key" json:"id"`
+	UserID     uint           `gorm:"not null;unique_index:idx_wishlists_user_name" json:"user_id"`
+	Name       string         `gorm:"size:100;not null;unique_index:idx_wishlists_user_name" json:"name"`
+	ShareToken string         `gorm:"size:64;not null;unique_index" json:"share_token"`
+	Items      []WishlistItem `gorm:"foreignkey:WishlistID" json:"items,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// WishlistItem is an item saved on a wishlist. An item is on a list at most once.
+type WishlistItem struct {
+	ID         uint      `gorm:"primary_key" json:"-"`
+	WishlistID uint      `gorm:"not null;unique_index:idx_wishlist_items_item" json:"-"`
+	ItemID     uint      `gorm:"not null;unique_index:idx_wishlist_items_item" json:"item_id"`
+	CreatedAt  time.Time `json:"added_at"`
+}
@@ -0,0 +1,232 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used when an amount is given without a currency.
+const DefaultCurrency = "USD"
+
+// ErrCurrencyMismatch is returned when combining amounts in different currencies.
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// ErrOverflow is returned when a result does not fit in an int64 amount.
+var ErrOverflow = errors.New("amount out of range")
+
+// minorDigits lists ISO 4217 currencies that do not use two decimal places.
+var minorDigits = map[string]int{
+	"BHD": 3, "CLP": 0, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0,
+	"KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3, "VND": 0,
+}
+
+// Money is an exact amount in the minor unit of its currency, e.g. cents for
+// USD. It is stored as two columns when embedded in a model.
+type Money struct {
+	Amount   int64  `gorm:"not null;default:0" json:"amount"`
+	Currency string `gorm:"size:3;not null;default:'USD'" json:"currency"`
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: normalizeCurrency(currency)}
+}
+
+// Zero returns an amount of nothing in currency.
+func Zero(currency string) Money {
+	return New(0, currency)
+}
+
+// Digits returns the number of decimal places used by currency.
+func Digits(currency string) int {
+	if d, ok := minorDigits[normalizeCurrency(currency)]; ok {
+		return d
+	}
+	return 2
+}
+
+func normalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return DefaultCurrency
+	}
+	return currency
+}
+
+// validCurrency reports whether a normalized currency has the shape of an
+// ISO 4217 code: three ASCII letters.
+func validCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for i := 0; i < len(currency); i++ {
+		if currency[i] < 'A' || currency[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse converts a decimal string such as "1299.50" into minor units without
+// going through floating point. More decimals than the currency allows is an
+// error rather than a silent rounding.
+func Parse(s, currency string) (Money, error) {
+	currency = normalizeCurrency(currency)
+	if !validCurrency(currency) {
+		return Money{}, fmt.Errorf("invalid currency %q", currency)
+	}
+	digits := Digits(currency)
+
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if whole == "" && frac == "" {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	// Trailing zeros beyond the currency's precision carry no value
+	for len(frac) > digits && strings.HasSuffix(frac, "0") {
+		frac = frac[:len(frac)-1]
+	}
+	if len(frac) > digits {
+		return Money{}, fmt.Errorf("amount %q has more than %d decimal places", s, digits)
+	}
+	frac += strings.Repeat("0", digits-len(frac))
+
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || strings.ContainsAny(whole+frac, "+-") {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if neg {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// Decimal formats the amount with the currency's decimal places, e.g. "1299.50".
+func (m Money) Decimal() string {
+	digits := Digits(m.Currency)
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	s := strconv.FormatInt(amount, 10)
+	if digits == 0 {
+		return sign + s
+	}
+	if len(s) <= digits {
+		s = strings.Repeat("0", digits-len(s)+1) + s
+	}
+	return sign + s[:len(s)-digits] + "." + s[len(s)-digits:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + normalizeCurrency(m.Currency)
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Add returns m + o. Both amounts must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if normalizeCurrency(m.Currency) != normalizeCurrency(o.Currency) {
+		return Money{}, ErrCurrencyMismatch
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return New(sum, m.Currency), nil
+}
+
+// Mul returns the amount multiplied by a quantity.
+func (m Money) Mul(n int64) (Money, error) {
+	product := m.Amount * n
+	if n != 0 && (product/n != m.Amount || (n == -1 && m.Amount == math.MinInt64)) {
+		return Money{}, ErrOverflow
+	}
+	return New(product, m.Currency), nil
+}
+
+// Sum adds up amounts, which must all share a currency. The sum of no amounts
+// is zero in DefaultCurrency.
+func Sum(amounts ...Money) (Money, error) {
+	if len(amounts) == 0 {
+		return Zero(DefaultCurrency), nil
+	}
+	total := Zero(amounts[0].Currency)
+	for _, a := range amounts {
+		var err error
+		if total, err = total.Add(a); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// moneyJSON is the wire format. Formatted is output only and lets clients
+// show the amount without knowing each currency's decimal places.
+type moneyJSON struct {
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+	Formatted string `json:"formatted,omitempty"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{
+		Amount:    m.Amount,
+		Currency:  normalizeCurrency(m.Currency),
+		Formatted: m.Decimal(),
+	})
+}
+
+// UnmarshalJSON accepts either {"amount": 129950, "currency": "USD"} in minor
+// units, or a plain decimal number or string such as 1299.50 in the default
+// currency. Decimal input is parsed from its text, never as a float.
+// Currencies must be three letters.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var v moneyJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		if !validCurrency(normalizeCurrency(v.Currency)) {
+			return fmt.Errorf("invalid currency %q", v.Currency)
+		}
+		*m = New(v.Amount, v.Currency)
+		return nil
+	}
+
+	var text string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	} else {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return err
+		}
+		text = n.String()
+	}
+	parsed, err := Parse(text, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,169 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+		want     Money
+	}{
+		{"1299.50", "USD", New(129950, "USD")},
+		{"1299.5", "usd", New(129950, "USD")},
+		{"1299", "", New(129900, "USD")},
+		{".5", "USD", New(50, "USD")},
+		{"+3.10", "USD", New(310, "USD")},
+		{"-12.34", "USD", New(-1234, "USD")},
+		{"-0.05", "EUR", New(-5, "EUR")},
+		// Zeros beyond the currency's precision carry no value
+		{"1.230", "USD", New(123, "USD")},
+		{"500.000", "JPY", New(500, "JPY")},
+		{"1.234", "KWD", New(1234, "KWD")},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in, tt.currency)
+		if err != nil {
+			t.Errorf("Parse(%q, %q): %v", tt.in, tt.currency, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q, %q) = %+v, want %+v", tt.in, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+	}{
+		// Amounts are never rounded
+		{"1.235", "USD"},
+		{"0.001", "USD"},
+		{"5.5", "JPY"},
+		{"", "USD"},
+		{".", "USD"},
+		{"-", "USD"},
+		{"--1", "USD"},
+		{"1.-5", "USD"},
+		{"1e3", "USD"},
+		{"abc", "USD"},
+		{"99999999999999999999", "USD"},
+		{"1.00", "US"},
+		{"1.00", "US1"},
+		{"1.00", "💲"},
+	}
+	for _, tt := range tests {
+		if got, err := Parse(tt.in, tt.currency); err == nil {
+			t.Errorf("Parse(%q, %q) = %+v, want error", tt.in, tt.currency, got)
+		}
+	}
+}
+
+func TestMul(t *testing.T) {
+	got, err := New(1999, "USD").Mul(3)
+	if err != nil || got != New(5997, "USD") {
+		t.Errorf("Mul(3) = %+v, %v, want 59.97 USD", got, err)
+	}
+	if got, err := New(-250, "EUR").Mul(4); err != nil || got != New(-1000, "EUR") {
+		t.Errorf("Mul(4) = %+v, %v, want -10.00 EUR", got, err)
+	}
+	if got, err := New(math.MaxInt64, "USD").Mul(0); err != nil || got.Amount != 0 {
+		t.Errorf("Mul(0) = %+v, %v, want 0", got, err)
+	}
+
+	overflows := []struct {
+		amount, n int64
+	}{
+		{math.MaxInt64, 2},
+		{math.MaxInt64/1000 + 1, 1000},
+		{math.MinInt64, -1},
+		{-1, math.MinInt64},
+		{math.MinInt64 / 2, 3},
+	}
+	for _, tt := range overflows {
+		if got, err := New(tt.amount, "USD").Mul(tt.n); err != ErrOverflow {
+			t.Errorf("%d * %d = %+v, %v, want ErrOverflow", tt.amount, tt.n, got, err)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	if got, err := New(100, "USD").Add(New(-250, "usd")); err != nil || got != New(-150, "USD") {
+		t.Errorf("Add = %+v, %v, want -1.50 USD", got, err)
+	}
+	if _, err := New(100, "USD").Add(New(100, "EUR")); err != ErrCurrencyMismatch {
+		t.Errorf("Add across currencies: %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := New(math.MaxInt64, "USD").Add(New(1, "USD")); err != ErrOverflow {
+		t.Errorf("Add past MaxInt64: %v, want ErrOverflow", err)
+	}
+	if _, err := New(math.MinInt64, "USD").Add(New(-1, "USD")); err != ErrOverflow {
+		t.Errorf("Add past MinInt64: %v, want ErrOverflow", err)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, m := range []Money{New(129950, "USD"), New(-5, "EUR"), New(1500, "JPY"), New(1234, "KWD"), Zero("USD")} {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("marshal %+v: %v", m, err)
+		}
+		var got Money
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("unmarshal %s: %v", data, err)
+		}
+		if got != m {
+			t.Errorf("round trip of %+v through %s = %+v", m, data, got)
+		}
+	}
+
+	data, _ := json.Marshal(New(129950, "USD"))
+	if want := `{"amount":129950,"currency":"USD","formatted":"1299.50"}`; string(data) != want {
+		t.Errorf("marshal = %s, want %s", data, want)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{`{"amount": 129950, "currency": "USD"}`, New(129950, "USD")},
+		{`{"amount": 100, "currency": "eur"}`, New(100, "EUR")},
+		{`{"amount": 100}`, New(100, DefaultCurrency)},
+		{`1299.50`, New(129950, DefaultCurrency)},
+		{`"1299.50"`, New(129950, DefaultCurrency)},
+		{`-0.10`, New(-10, DefaultCurrency)},
+	}
+	for _, tt := range tests {
+		var got Money
+		if err := json.Unmarshal([]byte(tt.in), &got); err != nil {
+			t.Errorf("unmarshal %s: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("unmarshal %s = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{
+		`{"amount": 100, "currency": "US"}`,
+		`{"amount": 100, "currency": "USDX"}`,
+		`{"amount": 100, "currency": "U5D"}`,
+		`{"amount": 100, "currency": "💲"}`,
+		`{"amount": 1.5, "currency": "USD"}`,
+		`1.001`,
+		`"abc"`,
+		`true`,
+	} {
+		var got Money
+		if err := json.Unmarshal([]byte(in), &got); err == nil {
+			t.Errorf("unmarshal %s = %+v, want error", in, got)
+		}
+	}
+}
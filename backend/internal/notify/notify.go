@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Message is a notification for a single user.
+type Message struct {
+	UserID  uint
+	Kind    string
+	ItemID  uint
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users. Implementations may send email, push
+// notifications or anything else; a returned error means the message was not
+// delivered and may be retried.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// LogNotifier writes messages to the server log. It stands in until a real
+// delivery channel is configured.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, msg Message) error {
+	log.Printf("Notify user %d: %s", msg.UserID, msg.Subject)
+	return nil
+}
+
+// Outbox keeps messages in memory instead of delivering them, for tests and
+// local development.
+type Outbox struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewOutbox() *Outbox {
+	return &Outbox{}
+}
+
+func (o *Outbox) Notify(ctx context.Context, msg Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+// Messages returns the messages received so far, oldest first.
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Message(nil), o.messages...)
+}
+
+// Reset empties the outbox.
+func (o *Outbox) Reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = nil
+}
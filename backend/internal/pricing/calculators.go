@@ -0,0 +1,149 @@
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ecommerce-app/internal/money"
+)
+
+// QuantityDiscount takes a percentage, in basis points, off every item line
+// of at least MinQuantity units. Bundles are already sold at a saving and
+// are not discounted.
+type QuantityDiscount struct {
+	MinQuantity int
+	BasisPoints int64
+}
+
+func (QuantityDiscount) Name() string { return "quantity_discount" }
+
+func (QuantityDiscount) Stage() Stage { return StageLineDiscount }
+
+func (d QuantityDiscount) Apply(ctx context.Context, cart Cart, b *Breakdown) error {
+	if d.MinQuantity <= 0 || d.BasisPoints <= 0 {
+		return nil
+	}
+	label := fmt.Sprintf("%s off %d or more", FormatPercent(d.BasisPoints), d.MinQuantity)
+	for i, line := range b.Lines {
+		if line.ItemID == 0 || line.Quantity < d.MinQuantity {
+			continue
+		}
+		amount, err := Percent(line.Total.Amount, d.BasisPoints)
+		if err != nil {
+			return err
+		}
+		b.DiscountLine(d.Name(), label, i, amount)
+	}
+	return nil
+}
+
+// SpendDiscount takes a percentage, in basis points, off carts whose goods
+// cost at least From after the discounts before it.
+type SpendDiscount struct {
+	From        money.Money
+	BasisPoints int64
+}
+
+func (SpendDiscount) Name() string { return "spend_discount" }
+
+func (SpendDiscount) Stage() Stage { return StageCartDiscount }
+
+func (d SpendDiscount) Apply(ctx context.Context, cart Cart, b *Breakdown) error {
+	if d.BasisPoints <= 0 {
+		return nil
+	}
+	if !d.From.IsZero() && d.From.Currency != b.Currency() {
+		return fmt.Errorf("spend discount threshold is in %s, the cart in %s", d.From.Currency, b.Currency())
+	}
+	if b.Goods() < d.From.Amount {
+		return nil
+	}
+	amount, err := Percent(b.Goods(), d.BasisPoints)
+	if err != nil {
+		return err
+	}
+	label := FormatPercent(d.BasisPoints) + " off"
+	if !d.From.IsZero() {
+		label += " orders from " + d.From.String()
+	}
+	b.DiscountCart(d.Name(), label, amount)
+	return nil
+}
+
+// FlatRateShipping charges Rate for carts with anything to ship. Carts
+// whose goods cost at least FreeFrom after discounts ship free; a zero
+// FreeFrom never does.
+type FlatRateShipping struct {
+	Rate     money.Money
+	FreeFrom money.Money
+}
+
+func (FlatRateShipping) Name() string { return "flat_rate_shipping" }
+
+func (FlatRateShipping) Stage() Stage { return StageShipping }
+
+func (s FlatRateShipping) Apply(ctx context.Context, cart Cart, b *Breakdown) error {
+	if !b.Shippable() || s.Rate.IsZero() {
+		return nil
+	}
+	if s.Rate.Currency != b.Currency() {
+		return fmt.Errorf("shipping rate is in %s, the cart in %s", s.Rate.Currency, b.Currency())
+	}
+	if !s.FreeFrom.IsZero() && b.Goods() >= s.FreeFrom.Amount {
+		return nil
+	}
+	b.AddShipping(s.Name(), "Shipping", s.Rate.Amount)
+	return nil
+}
+
+// PercentTax charges a percentage of the goods after discounts, given in
+// basis points (825 is 8.25%). Shipping is not taxed.
+type PercentTax struct {
+	BasisPoints int64
+}
+
+func (PercentTax) Name() string { return "percent_tax" }
+
+func (PercentTax) Stage() Stage { return StageTax }
+
+func (t PercentTax) Apply(ctx context.Context, cart Cart, b *Breakdown) error {
+	if t.BasisPoints <= 0 {
+		return nil
+	}
+	amount, err := Percent(b.Goods(), t.BasisPoints)
+	if err != nil {
+		return err
+	}
+	b.AddTax(t.Name(), "Tax "+FormatPercent(t.BasisPoints), amount)
+	return nil
+}
+
+// ParsePercent converts a percentage such as "8.25" into basis points.
+// More than two decimal places is an error.
+func ParsePercent(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "%")
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if len(frac) > 2 {
+		return 0, fmt.Errorf("percentage %q has more than 2 decimal places", s)
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	bp, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || bp < 0 || strings.ContainsAny(whole+frac, "+-") {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+	return bp, nil
+}
+
+// FormatPercent formats basis points as a percentage, e.g. "8.25%".
+func FormatPercent(basisPoints int64) string {
+	s := fmt.Sprintf("%d.%02d", basisPoints/100, basisPoints%100)
+	return strings.TrimSuffix(strings.TrimSuffix(s, "0"), ".0") + "%"
+}
@@ -0,0 +1,131 @@
+package pricing
+
+import (
+	"context"
+
+	"ecommerce-app/internal/money"
+)
+
+// Coupon is a coupon applied to a cart. Coupons with eligible items or
+// categories only discount the lines of those items; others discount the
+// whole cart. Problem, when set, is why the coupon cannot be used at all,
+// for reasons only the store knows, such as usage limits.
+type Coupon struct {
+	Code string
+	// PercentOff is in basis points; coupons without it take AmountOff off
+	PercentOff int64
+	AmountOff  money.Money
+	MinSpend   money.Money
+	ItemIDs    map[uint]bool
+	Categories map[string]bool
+	Problem    string
+}
+
+// Limited reports whether the coupon only applies to some items.
+func (c *Coupon) Limited() bool {
+	return len(c.ItemIDs) > 0 || len(c.Categories) > 0
+}
+
+// Eligible reports whether the coupon applies to line. Bundles are only
+// discounted by coupons for the whole cart.
+func (c *Coupon) Eligible(line Line) bool {
+	if !c.Limited() {
+		return true
+	}
+	if line.ItemID == 0 {
+		return false
+	}
+	if c.ItemIDs[line.ItemID] {
+		return true
+	}
+	for _, category := range line.Categories {
+		if c.Categories[category] {
+			return true
+		}
+	}
+	return false
+}
+
+// CouponResult is what the cart's coupon did. Reason says why it did not
+// apply.
+type CouponResult struct {
+	Code     string      `json:"code"`
+	Applied  bool        `json:"applied"`
+	Discount money.Money `json:"discount"`
+	Reason   string      `json:"reason,omitempty"`
+}
+
+// CouponDiscount applies the cart's coupon. It runs with the cart
+// discounts, so the minimum spend is checked against the goods after line
+// discounts.
+type CouponDiscount struct{}
+
+func (CouponDiscount) Name() string { return "coupon" }
+
+func (CouponDiscount) Stage() Stage { return StageCartDiscount }
+
+func (d CouponDiscount) Apply(ctx context.Context, cart Cart, b *Breakdown) error {
+	coupon := cart.Coupon
+	if coupon == nil {
+		return nil
+	}
+	result := &CouponResult{Code: coupon.Code, Discount: money.Zero(b.Currency())}
+	b.Coupon = result
+
+	if coupon.Problem != "" {
+		result.Reason = coupon.Problem
+		return nil
+	}
+	for _, amount := range []money.Money{coupon.AmountOff, coupon.MinSpend} {
+		if !amount.IsZero() && amount.Currency != b.Currency() {
+			result.Reason = "Coupon is not valid for " + b.Currency() + " carts"
+			return nil
+		}
+	}
+	if !coupon.MinSpend.IsZero() && b.Goods() < coupon.MinSpend.Amount {
+		result.Reason = "Spend at least " + coupon.MinSpend.String() + " to use this coupon"
+		return nil
+	}
+
+	label := "Coupon " + coupon.Code
+	var taken int64
+	if !coupon.Limited() {
+		amount := coupon.AmountOff.Amount
+		if coupon.PercentOff > 0 {
+			var err error
+			if amount, err = Percent(b.Goods(), coupon.PercentOff); err != nil {
+				return err
+			}
+		}
+		taken = b.DiscountCart(d.Name(), label, amount)
+	} else {
+		eligible := false
+		// A fixed amount is taken off the eligible lines in turn
+		remaining := coupon.AmountOff.Amount
+		for i, line := range b.Lines {
+			if !coupon.Eligible(line) {
+				continue
+			}
+			eligible = true
+			if coupon.PercentOff > 0 {
+				amount, err := Percent(line.Total.Amount, coupon.PercentOff)
+				if err != nil {
+					return err
+				}
+				taken += b.DiscountLine(d.Name(), label, i, amount)
+			} else if remaining > 0 {
+				n := b.DiscountLine(d.Name(), label, i, remaining)
+				remaining -= n
+				taken += n
+			}
+		}
+		if !eligible {
+			result.Reason = "No item in the cart is eligible for this coupon"
+			return nil
+		}
+	}
+
+	result.Applied = true
+	result.Discount.Amount = taken
+	return nil
+}
@@ -0,0 +1,100 @@
+package pricing
+
+import (
+	"context"
+	"testing"
+
+	"ecommerce-app/internal/money"
+)
+
+func TestCouponEligible(t *testing.T) {
+	cable := Line{ItemID: 1, Categories: []string{"cables"}}
+	mouse := Line{ItemID: 2, Categories: []string{"mice"}}
+	bundle := Line{BundleID: 1}
+	tests := []struct {
+		name   string
+		coupon Coupon
+		line   Line
+		want   bool
+	}{
+		{"whole cart item", Coupon{}, cable, true},
+		{"whole cart bundle", Coupon{}, bundle, true},
+		{"listed item", Coupon{ItemIDs: map[uint]bool{1: true}}, cable, true},
+		{"unlisted item", Coupon{ItemIDs: map[uint]bool{1: true}}, mouse, false},
+		{"listed category", Coupon{Categories: map[string]bool{"mice": true}}, mouse, true},
+		{"item or category", Coupon{ItemIDs: map[uint]bool{1: true}, Categories: map[string]bool{"mice": true}}, mouse, true},
+		{"limited bundle", Coupon{Categories: map[string]bool{"mice": true}}, bundle, false},
+	}
+	for _, tt := range tests {
+		if got := tt.coupon.Eligible(tt.line); got != tt.want {
+			t.Errorf("%s: Eligible = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCouponDiscount(t *testing.T) {
+	// 20.00 + 30.00 + 20.00 in goods
+	cart := Cart{Lines: []Line{
+		{ItemID: 1, Name: "Cable", UnitPrice: usd(1000), Quantity: 2, Categories: []string{"cables"}},
+		{ItemID: 2, Name: "Mouse", UnitPrice: usd(3000), Quantity: 1, Categories: []string{"mice"}},
+		{BundleID: 1, Name: "Kit", UnitPrice: usd(2000), Quantity: 1},
+	}}
+	tests := []struct {
+		name   string
+		coupon Coupon
+		// extra runs alongside the coupon
+		extra        []Calculator
+		wantApplied  bool
+		wantDiscount int64
+		wantLines    []int64
+		wantReason   string
+	}{
+		{"percent off the cart", Coupon{PercentOff: 1000}, nil, true, 700, []int64{0, 0, 0}, ""},
+		{"amount off the cart", Coupon{AmountOff: usd(500)}, nil, true, 500, []int64{0, 0, 0}, ""},
+		{"amount past the goods", Coupon{AmountOff: usd(10000)}, nil, true, 7000, []int64{0, 0, 0}, ""},
+		{"percent off an item", Coupon{PercentOff: 1000, ItemIDs: map[uint]bool{1: true}}, nil, true, 200, []int64{200, 0, 0}, ""},
+		{"percent off a category", Coupon{PercentOff: 1000, Categories: map[string]bool{"mice": true}}, nil, true, 300, []int64{0, 300, 0}, ""},
+		// A fixed amount fills the first eligible line before the next
+		{"amount over items", Coupon{AmountOff: usd(2500), ItemIDs: map[uint]bool{1: true, 2: true}}, nil, true, 2500, []int64{2000, 500, 0}, ""},
+		{"no eligible item", Coupon{PercentOff: 1000, Categories: map[string]bool{"chairs": true}}, nil, false, 0, []int64{0, 0, 0}, "No item in the cart is eligible for this coupon"},
+		{"minimum spend met", Coupon{PercentOff: 1000, MinSpend: usd(7000)}, nil, true, 700, []int64{0, 0, 0}, ""},
+		{"minimum spend missed", Coupon{PercentOff: 1000, MinSpend: usd(7001)}, nil, false, 0, []int64{0, 0, 0}, "Spend at least 70.01 USD to use this coupon"},
+		// The cable's quantity discount leaves 68.00 of goods
+		{"minimum spend after line discounts", Coupon{PercentOff: 1000, MinSpend: usd(7000)},
+			[]Calculator{QuantityDiscount{MinQuantity: 2, BasisPoints: 1000}}, false, 200, []int64{200, 0, 0}, "Spend at least 70.00 USD to use this coupon"},
+		{"other currency", Coupon{AmountOff: money.New(500, "EUR")}, nil, false, 0, []int64{0, 0, 0}, "Coupon is not valid for USD carts"},
+		{"problem", Coupon{PercentOff: 1000, Problem: "Coupon has been used up"}, nil, false, 0, []int64{0, 0, 0}, "Coupon has been used up"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coupon := tt.coupon
+			coupon.Code = "SAVE"
+			cart := cart
+			cart.Coupon = &coupon
+			b, err := NewEngine(append(tt.extra, CouponDiscount{})...).Price(context.Background(), cart)
+			if err != nil {
+				t.Fatalf("Price: %v", err)
+			}
+
+			result := b.Coupon
+			if result == nil {
+				t.Fatal("no coupon result")
+			}
+			if result.Applied != tt.wantApplied || result.Reason != tt.wantReason {
+				t.Errorf("applied %v %q, want %v %q", result.Applied, result.Reason, tt.wantApplied, tt.wantReason)
+			}
+			if tt.wantApplied && result.Discount != usd(tt.wantDiscount) {
+				t.Errorf("coupon discount %s, want %d", result.Discount, tt.wantDiscount)
+			}
+			// The breakdown's discount includes any line discounts from extra
+			if b.Discount.Amount != tt.wantDiscount {
+				t.Errorf("discount %d, want %d", b.Discount.Amount, tt.wantDiscount)
+			}
+			for i, want := range tt.wantLines {
+				if got := b.Lines[i].Discount.Amount; got != want {
+					t.Errorf("line %d discount %d, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
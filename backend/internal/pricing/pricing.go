@@ -0,0 +1,249 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"ecommerce-app/internal/money"
+)
+
+// ErrMixedCurrencies is returned for carts whose lines are priced in more
+// than one currency.
+var ErrMixedCurrencies = errors.New("cart lines are priced in different currencies")
+
+// Stage orders calculators: every line discount runs before any cart
+// discount, cart discounts before shipping, and shipping before tax.
+type Stage int
+
+const (
+	StageLineDiscount Stage = iota
+	StageCartDiscount
+	StageShipping
+	StageTax
+)
+
+// Kinds of adjustments.
+const (
+	KindDiscount = "discount"
+	KindShipping = "shipping"
+	KindTax      = "tax"
+)
+
+// Calculator is one step of pricing a cart. It looks at the breakdown so
+// far and adds its adjustments through the methods of Breakdown.
+type Calculator interface {
+	// Name identifies the calculator in adjustments.
+	Name() string
+	Stage() Stage
+	Apply(ctx context.Context, cart Cart, b *Breakdown) error
+}
+
+// Cart is what gets priced.
+type Cart struct {
+	ID     uint
+	UserID *uint
+	Lines  []Line
+
+	// Coupon is the coupon applied to the cart, if any
+	Coupon *Coupon
+}
+
+// Line is a cart line, either an item or a bundle, at its current unit
+// price. Subtotal, Discount and Total are filled in by the engine.
+type Line struct {
+	ItemID    uint        `json:"item_id,omitempty"`
+	BundleID  uint        `json:"bundle_id,omitempty"`
+	Name      string      `json:"name"`
+	UnitPrice money.Money `json:"unit_price"`
+	Quantity  int         `json:"quantity"`
+	// Shippable is false for lines that are only delivered digitally
+	Shippable bool `json:"-"`
+	// Categories of the item, for coupons limited to categories
+	Categories []string `json:"-"`
+
+	Subtotal money.Money `json:"subtotal"`
+	Discount money.Money `json:"discount"`
+	Total    money.Money `json:"total"`
+}
+
+// Adjustment is an amount a calculator applied. Discounts are subtracted,
+// shipping and tax are added; Amount is never negative. Line is the index
+// of the line a line discount applies to.
+type Adjustment struct {
+	Calculator string      `json:"calculator"`
+	Kind       string      `json:"kind"`
+	Label      string      `json:"label"`
+	Amount     money.Money `json:"amount"`
+	Line       *int        `json:"line,omitempty"`
+}
+
+// Breakdown is a priced cart. Total is Subtotal less Discount plus
+// Shipping and Tax.
+type Breakdown struct {
+	Lines       []Line       `json:"lines"`
+	Subtotal    money.Money  `json:"subtotal"`
+	Discount    money.Money  `json:"discount"`
+	Shipping    money.Money  `json:"shipping"`
+	Tax         money.Money  `json:"tax"`
+	Total       money.Money  `json:"total"`
+	Adjustments []Adjustment `json:"adjustments"`
+
+	// Coupon tells whether the cart's coupon applied
+	Coupon *CouponResult `json:"coupon,omitempty"`
+}
+
+// Currency is the currency of every amount in the breakdown.
+func (b *Breakdown) Currency() string {
+	return b.Total.Currency
+}
+
+// Goods is what the lines cost after all discounts so far.
+func (b *Breakdown) Goods() int64 {
+	return b.Subtotal.Amount - b.Discount.Amount
+}
+
+// Shippable reports whether any line has to be shipped.
+func (b *Breakdown) Shippable() bool {
+	for _, line := range b.Lines {
+		if line.Shippable {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscountLine takes amount off line i, at most what is left of the line,
+// and returns what was taken off.
+func (b *Breakdown) DiscountLine(calculator, label string, i int, amount int64) int64 {
+	line := &b.Lines[i]
+	if amount > line.Total.Amount {
+		amount = line.Total.Amount
+	}
+	if amount <= 0 {
+		return 0
+	}
+	line.Discount.Amount += amount
+	line.Total.Amount -= amount
+	b.Discount.Amount += amount
+	index := i
+	b.adjust(calculator, KindDiscount, label, amount, &index)
+	return amount
+}
+
+// DiscountCart takes amount off the whole cart, at most what the goods
+// still cost, and returns what was taken off.
+func (b *Breakdown) DiscountCart(calculator, label string, amount int64) int64 {
+	if amount > b.Goods() {
+		amount = b.Goods()
+	}
+	if amount <= 0 {
+		return 0
+	}
+	b.Discount.Amount += amount
+	b.adjust(calculator, KindDiscount, label, amount, nil)
+	return amount
+}
+
+// AddShipping charges amount for shipping.
+func (b *Breakdown) AddShipping(calculator, label string, amount int64) {
+	if amount <= 0 {
+		return
+	}
+	b.Shipping.Amount += amount
+	b.adjust(calculator, KindShipping, label, amount, nil)
+}
+
+// AddTax charges amount of tax.
+func (b *Breakdown) AddTax(calculator, label string, amount int64) {
+	if amount <= 0 {
+		return
+	}
+	b.Tax.Amount += amount
+	b.adjust(calculator, KindTax, label, amount, nil)
+}
+
+// adjust records an adjustment whose amount has been added to its total.
+func (b *Breakdown) adjust(calculator, kind, label string, amount int64, line *int) {
+	b.Adjustments = append(b.Adjustments, Adjustment{
+		Calculator: calculator,
+		Kind:       kind,
+		Label:      label,
+		Amount:     money.New(amount, b.Currency()),
+		Line:       line,
+	})
+	// Kept current so calculators can read the running total
+	b.Total.Amount = b.Subtotal.Amount - b.Discount.Amount + b.Shipping.Amount + b.Tax.Amount
+}
+
+// Engine prices carts by running its calculators in stage order.
+// Calculators of the same stage run in the order they were given.
+type Engine struct {
+	calculators []Calculator
+}
+
+func NewEngine(calculators ...Calculator) *Engine {
+	sorted := append([]Calculator(nil), calculators...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Stage() < sorted[j].Stage() })
+	return &Engine{calculators: sorted}
+}
+
+// Price returns the breakdown of cart. The lines of the breakdown are in
+// the order of cart.Lines.
+func (e *Engine) Price(ctx context.Context, cart Cart) (Breakdown, error) {
+	currency := money.DefaultCurrency
+	if len(cart.Lines) > 0 {
+		currency = cart.Lines[0].UnitPrice.Currency
+	}
+
+	b := Breakdown{
+		Lines:       make([]Line, 0, len(cart.Lines)),
+		Subtotal:    money.Zero(currency),
+		Discount:    money.Zero(currency),
+		Shipping:    money.Zero(currency),
+		Tax:         money.Zero(currency),
+		Adjustments: []Adjustment{},
+	}
+	for _, line := range cart.Lines {
+		if line.UnitPrice.Currency != currency {
+			return Breakdown{}, ErrMixedCurrencies
+		}
+		subtotal, err := line.UnitPrice.Mul(int64(line.Quantity))
+		if err != nil {
+			return Breakdown{}, err
+		}
+		line.Subtotal = subtotal
+		line.Discount = money.Zero(currency)
+		line.Total = line.Subtotal
+		if b.Subtotal, err = b.Subtotal.Add(subtotal); err != nil {
+			return Breakdown{}, err
+		}
+		b.Lines = append(b.Lines, line)
+	}
+	b.Total = b.Subtotal
+
+	for _, calc := range e.calculators {
+		if err := ctx.Err(); err != nil {
+			return Breakdown{}, err
+		}
+		if err := calc.Apply(ctx, cart, &b); err != nil {
+			return Breakdown{}, err
+		}
+	}
+	return b, nil
+}
+
+// Percent returns basisPoints hundredths of a percent of amount, rounded
+// half up, or money.ErrOverflow when the amount is too large.
+func Percent(amount, basisPoints int64) (int64, error) {
+	product, err := money.Money{Amount: amount}.Mul(basisPoints)
+	if err != nil {
+		return 0, err
+	}
+	// Rounded from the remainder, as adding half first could overflow
+	percent := product.Amount / 10000
+	if product.Amount%10000 >= 5000 {
+		percent++
+	}
+	return percent, nil
+}
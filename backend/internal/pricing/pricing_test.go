@@ -0,0 +1,203 @@
+package pricing
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"ecommerce-app/internal/money"
+)
+
+// recorder notes the order calculators run in.
+type recorder struct {
+	name  string
+	stage Stage
+	ran   *[]string
+}
+
+func (r recorder) Name() string { return r.name }
+
+func (r recorder) Stage() Stage { return r.stage }
+
+func (r recorder) Apply(ctx context.Context, cart Cart, b *Breakdown) error {
+	*r.ran = append(*r.ran, r.name)
+	return nil
+}
+
+func usd(amount int64) money.Money { return money.New(amount, "USD") }
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		amount, basisPoints, want int64
+	}{
+		{10000, 825, 825},
+		// Half a cent and more rounds up, less rounds down
+		{1000, 825, 83},
+		{999, 825, 82},
+		{1, 5000, 1},
+		{1, 4999, 0},
+		{0, 825, 0},
+		{12345, 10000, 12345},
+		{math.MaxInt64 / 10000, 10000, math.MaxInt64 / 10000},
+	}
+	for _, tt := range tests {
+		got, err := Percent(tt.amount, tt.basisPoints)
+		if err != nil || got != tt.want {
+			t.Errorf("Percent(%d, %d) = %d, %v, want %d", tt.amount, tt.basisPoints, got, err, tt.want)
+		}
+	}
+	if _, err := Percent(math.MaxInt64/1000, 10000); err != money.ErrOverflow {
+		t.Errorf("Percent past MaxInt64: %v, want ErrOverflow", err)
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"8.25", 825},
+		{"8.5%", 850},
+		{" 10 ", 1000},
+		{".5", 50},
+		{"0", 0},
+	}
+	for _, tt := range tests {
+		got, err := ParsePercent(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePercent(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+		if tt.want > 0 {
+			if back, _ := ParsePercent(FormatPercent(got)); back != got {
+				t.Errorf("FormatPercent(%d) = %q does not parse back", got, FormatPercent(got))
+			}
+		}
+	}
+	for _, in := range []string{"8.125", "-5", "+5", "abc"} {
+		if got, err := ParsePercent(in); err == nil {
+			t.Errorf("ParsePercent(%q) = %d, want error", in, got)
+		}
+	}
+}
+
+func TestEngineStageOrder(t *testing.T) {
+	var ran []string
+	// Given out of order; calculators of one stage keep the order given
+	engine := NewEngine(
+		recorder{"tax", StageTax, &ran},
+		recorder{"shipping", StageShipping, &ran},
+		recorder{"cart 1", StageCartDiscount, &ran},
+		recorder{"line", StageLineDiscount, &ran},
+		recorder{"cart 2", StageCartDiscount, &ran},
+	)
+	if _, err := engine.Price(context.Background(), Cart{}); err != nil {
+		t.Fatalf("Price: %v", err)
+	}
+	want := []string{"line", "cart 1", "cart 2", "shipping", "tax"}
+	if len(ran) != len(want) {
+		t.Fatalf("ran %v, want %v", ran, want)
+	}
+	for i := range want {
+		if ran[i] != want[i] {
+			t.Fatalf("ran %v, want %v", ran, want)
+		}
+	}
+}
+
+func TestEnginePrice(t *testing.T) {
+	engine := NewEngine(
+		PercentTax{BasisPoints: 825},
+		FlatRateShipping{Rate: usd(499), FreeFrom: usd(100000)},
+		SpendDiscount{From: usd(10000), BasisPoints: 1000},
+		QuantityDiscount{MinQuantity: 10, BasisPoints: 500},
+	)
+	cart := Cart{Lines: []Line{
+		{ItemID: 1, Name: "Cable", UnitPrice: usd(999), Quantity: 10, Shippable: true},
+		{ItemID: 2, Name: "Mouse", UnitPrice: usd(4999), Quantity: 1, Shippable: true},
+		{BundleID: 1, Name: "Kit", UnitPrice: usd(1000), Quantity: 10, Shippable: true},
+	}}
+	b, err := engine.Price(context.Background(), cart)
+	if err != nil {
+		t.Fatalf("Price: %v", err)
+	}
+
+	// 99.90 + 49.99 + 100.00 in goods. The cable line gets 5% off (4.995,
+	// rounded up to 5.00); bundles get no quantity discount.
+	if b.Subtotal != usd(24989) {
+		t.Errorf("subtotal = %s, want 249.89 USD", b.Subtotal)
+	}
+	if got := b.Lines[0].Discount; got != usd(500) {
+		t.Errorf("cable discount = %s, want 5.00 USD", got)
+	}
+	if got := b.Lines[2].Discount; !got.IsZero() {
+		t.Errorf("bundle discount = %s, want none", got)
+	}
+	// 10% of the 244.89 left is 24.489, so 24.49 off; then 8.25% tax on
+	// 220.40 is 18.183, so 18.18
+	if b.Discount != usd(2949) {
+		t.Errorf("discount = %s, want 29.49 USD", b.Discount)
+	}
+	if b.Shipping != usd(499) {
+		t.Errorf("shipping = %s, want 4.99 USD", b.Shipping)
+	}
+	if b.Tax != usd(1818) {
+		t.Errorf("tax = %s, want 18.18 USD", b.Tax)
+	}
+	if want := usd(24989 - 2949 + 499 + 1818); b.Total != want {
+		t.Errorf("total = %s, want %s", b.Total, want)
+	}
+
+	kinds := []string{KindDiscount, KindDiscount, KindShipping, KindTax}
+	if len(b.Adjustments) != len(kinds) {
+		t.Fatalf("adjustments = %+v, want kinds %v", b.Adjustments, kinds)
+	}
+	for i, adj := range b.Adjustments {
+		if adj.Kind != kinds[i] {
+			t.Errorf("adjustment %d is %s, want %s", i, adj.Kind, kinds[i])
+		}
+	}
+	if line := b.Adjustments[0].Line; line == nil || *line != 0 {
+		t.Errorf("quantity discount applies to line %v, want 0", line)
+	}
+}
+
+func TestSpendDiscountThreshold(t *testing.T) {
+	engine := NewEngine(SpendDiscount{From: usd(10000), BasisPoints: 1000})
+	tests := []struct {
+		goods int64
+		want  int64
+	}{
+		{9999, 0},
+		{10000, 1000},
+		{10005, 1001},
+	}
+	for _, tt := range tests {
+		b, err := engine.Price(context.Background(), Cart{Lines: []Line{{ItemID: 1, UnitPrice: usd(tt.goods), Quantity: 1}}})
+		if err != nil {
+			t.Fatalf("Price: %v", err)
+		}
+		if b.Discount.Amount != tt.want {
+			t.Errorf("discount on %d = %d, want %d", tt.goods, b.Discount.Amount, tt.want)
+		}
+	}
+
+	eur := Cart{Lines: []Line{{ItemID: 1, UnitPrice: money.New(20000, "EUR"), Quantity: 1}}}
+	if _, err := engine.Price(context.Background(), eur); err == nil {
+		t.Error("USD threshold on an EUR cart: want error")
+	}
+}
+
+func TestEngineRejects(t *testing.T) {
+	engine := NewEngine()
+	mixed := Cart{Lines: []Line{
+		{ItemID: 1, UnitPrice: usd(100), Quantity: 1},
+		{ItemID: 2, UnitPrice: money.New(100, "EUR"), Quantity: 1},
+	}}
+	if _, err := engine.Price(context.Background(), mixed); err != ErrMixedCurrencies {
+		t.Errorf("mixed currencies: %v, want ErrMixedCurrencies", err)
+	}
+	huge := Cart{Lines: []Line{{ItemID: 1, UnitPrice: usd(math.MaxInt64 / 2), Quantity: 3}}}
+	if _, err := engine.Price(context.Background(), huge); err != money.ErrOverflow {
+		t.Errorf("overflowing line: %v, want ErrOverflow", err)
+	}
+}
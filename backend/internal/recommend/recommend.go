@@ -0,0 +1,114 @@
+package recommend
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"ecommerce-app/internal/models"
+)
+
+// Strategy computes item recommendations from stored data. Each strategy
+// owns the recommendations saved under its name.
+type Strategy interface {
+	// Name identifies the strategy in stored rows and in the API.
+	Name() string
+	// Compute returns every recommendation the strategy currently makes.
+	Compute(ctx context.Context, db *gorm.DB) ([]Recommendation, error)
+}
+
+// Recommendation suggests RecommendedItemID to shoppers looking at ItemID.
+// A higher score is a stronger recommendation.
+type Recommendation struct {
+	ItemID            uint
+	RecommendedItemID uint
+	Score             float64
+}
+
+// Refresher recomputes the recommendations of its strategies and replaces
+// the stored ones. Run is meant to be called periodically.
+type Refresher struct {
+	DB         *gorm.DB
+	Strategies []Strategy
+	// Limit is the number of recommendations kept per item and strategy.
+	Limit int
+	Now   func() time.Time
+}
+
+func NewRefresher(db *gorm.DB, strategies ...Strategy) *Refresher {
+	return &Refresher{DB: db, Strategies: strategies, Limit: 10, Now: time.Now}
+}
+
+// Run refreshes every strategy. A failing strategy keeps its previous
+// recommendations and does not stop the others.
+func (r *Refresher) Run(ctx context.Context) error {
+	for _, s := range r.Strategies {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := r.refresh(ctx, s); err != nil {
+			log.Printf("Failed to refresh %s recommendations: %v", s.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (r *Refresher) refresh(ctx context.Context, s Strategy) error {
+	recs, err := s.Compute(ctx, r.DB)
+	if err != nil {
+		return err
+	}
+
+	// Best first per item, ties broken by ID so reruns give the same ranks
+	sort.Slice(recs, func(i, j int) bool {
+		a, b := recs[i], recs[j]
+		if a.ItemID != b.ItemID {
+			return a.ItemID < b.ItemID
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.RecommendedItemID < b.RecommendedItemID
+	})
+
+	now := r.Now()
+	tx := r.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := tx.Where("strategy = ?", s.Name()).Delete(&models.ItemRecommendation{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	rank, saved := 0, 0
+	for i, rec := range recs {
+		if i == 0 || rec.ItemID != recs[i-1].ItemID {
+			rank = 0
+		}
+		if rec.ItemID == rec.RecommendedItemID || rank >= r.Limit {
+			continue
+		}
+		rank++
+		if err := tx.Create(&models.ItemRecommendation{
+			Strategy:          s.Name(),
+			ItemID:            rec.ItemID,
+			RecommendedItemID: rec.RecommendedItemID,
+			Score:             rec.Score,
+			Rank:              rank,
+			ComputedAt:        now,
+		}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		saved++
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	log.Printf("Recommendations: %s saved %d", s.Name(), saved)
+	return nil
+}
@@ -0,0 +1,71 @@
+package recommend
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+)
+
+// StrategyFrequentlyBoughtTogether is the name of FrequentlyBoughtTogether.
+const StrategyFrequentlyBoughtTogether = "frequently_bought_together"
+
+// purchasedStatuses are the statuses of placed orders. Orders with a
+// backordered line were bought all the same.
+var purchasedStatuses = []string{"completed", "backordered"}
+
+// orderLinesSQL lists the distinct items of every placed order. Orders
+// placed before order lines were recorded are read from their cart.
+const orderLinesSQL = `
+SELECT o.id AS order_id, oi.item_id AS item_id
+FROM orders o JOIN order_items oi ON oi.order_id = o.id
+WHERE o.status IN (?)
+UNION
+SELECT o.id AS order_id, ci.item_id AS item_id
+FROM orders o JOIN cart_items ci ON ci.cart_id = o.cart_id
+WHERE o.status IN (?) AND NOT EXISTS (SELECT 1 FROM order_items x WHERE x.order_id = o.id)`
+
+// FrequentlyBoughtTogether recommends the items that appear in the same
+// placed orders as an item. The score is the number of orders that
+// contain both.
+type FrequentlyBoughtTogether struct {
+	// MinOrders is how many orders must contain a pair before it is
+	// recommended, which keeps one-off combinations out.
+	MinOrders int
+}
+
+func NewFrequentlyBoughtTogether() *FrequentlyBoughtTogether {
+	return &FrequentlyBoughtTogether{MinOrders: 2}
+}
+
+func (s *FrequentlyBoughtTogether) Name() string {
+	return StrategyFrequentlyBoughtTogether
+}
+
+func (s *FrequentlyBoughtTogether) Compute(ctx context.Context, db *gorm.DB) ([]Recommendation, error) {
+	rows, err := db.Raw(`
+		SELECT a.item_id, b.item_id, COUNT(*)
+		FROM (`+orderLinesSQL+`) a
+		JOIN (`+orderLinesSQL+`) b ON b.order_id = a.order_id AND b.item_id <> a.item_id
+		GROUP BY a.item_id, b.item_id
+		HAVING COUNT(*) >= ?`,
+		purchasedStatuses, purchasedStatuses, purchasedStatuses, purchasedStatuses, s.MinOrders).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recs []Recommendation
+	for rows.Next() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var rec Recommendation
+		var orders int
+		if err := rows.Scan(&rec.ItemID, &rec.RecommendedItemID, &orders); err != nil {
+			return nil, err
+		}
+		rec.Score = float64(orders)
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_order_items_item_id;
+DROP INDEX IF EXISTS idx_order_items_order_id;
+DROP TABLE IF EXISTS order_items;
+
+ALTER TABLE items DROP COLUMN allow_backorder;
+ALTER TABLE items DROP COLUMN stock;
@@ -0,0 +1,23 @@
+-- Track quantity on hand per item
+ALTER TABLE items ADD COLUMN stock INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE items ADD COLUMN allow_backorder BOOLEAN NOT NULL DEFAULT 0;
+
+-- What existing items have on hand is unknown, so they are sold out until
+-- restocked rather than failing at checkout
+UPDATE items SET status = 'out_of_stock' WHERE status = 'available';
+
+-- Order lines with the quantity that could not be reserved from stock
+CREATE TABLE IF NOT EXISTS order_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL,
+    item_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL,
+    backordered INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (item_id) REFERENCES items(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id);
+CREATE INDEX IF NOT EXISTS idx_order_items_item_id ON order_items(item_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_item_images_item_id;
+DROP TABLE IF EXISTS item_images;
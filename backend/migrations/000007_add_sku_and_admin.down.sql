@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN is_admin;
+
+DROP INDEX IF EXISTS idx_items_sku;
+ALTER TABLE items DROP COLUMN sku;
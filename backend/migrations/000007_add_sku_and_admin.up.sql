@@ -0,0 +1,6 @@
+-- Stock keeping unit used to match rows in catalog imports
+ALTER TABLE items ADD COLUMN sku VARCHAR(64) DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_items_sku ON items(sku);
+
+-- Admins can use the /api/admin endpoints
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT 0;
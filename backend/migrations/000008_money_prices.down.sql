@@ -0,0 +1,12 @@
+ALTER TABLE order_items DROP COLUMN unit_price_currency;
+ALTER TABLE order_items DROP COLUMN unit_price_amount;
+ALTER TABLE orders DROP COLUMN total_currency;
+ALTER TABLE orders DROP COLUMN total_amount;
+
+-- Minor units divide back into the same two-decimal REAL values. The column
+-- is still there if the server did not start since the up migration.
+ALTER TABLE items ADD COLUMN price REAL NOT NULL DEFAULT 0;
+UPDATE items SET price = price_amount / 100.0;
+
+ALTER TABLE items DROP COLUMN price_currency;
+ALTER TABLE items DROP COLUMN price_amount;
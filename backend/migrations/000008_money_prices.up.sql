@@ -0,0 +1,18 @@
+-- Store prices as integer minor units plus an ISO 4217 currency code
+ALTER TABLE items ADD COLUMN price_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE items ADD COLUMN price_currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+
+-- Every existing price is in USD with at most two decimals, so rounding the
+-- scaled REAL value recovers the exact number of cents
+UPDATE items SET price_amount = CAST(ROUND(price * 100) AS INTEGER), price_currency = 'USD';
+
+-- The old column is kept for now. The server drops it on startup once every
+-- price converted exactly and refuses to start while any item below has not
+SELECT '=== Items whose price did not convert exactly ===' AS message;
+SELECT id, name, price, price_amount FROM items WHERE ABS(price * 100 - price_amount) > 0.000001;
+
+-- Amounts charged on orders
+ALTER TABLE orders ADD COLUMN total_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN total_currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE order_items ADD COLUMN unit_price_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN unit_price_currency VARCHAR(3) NOT NULL DEFAULT 'USD';
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_scheduled_prices_status;
+DROP INDEX IF EXISTS idx_scheduled_prices_starts_at;
+DROP INDEX IF EXISTS idx_scheduled_prices_item_id;
+DROP TABLE IF EXISTS scheduled_prices;
+
+DROP INDEX IF EXISTS idx_price_changes_changed_at;
+DROP INDEX IF EXISTS idx_price_changes_item_id;
+DROP TABLE IF EXISTS price_changes;
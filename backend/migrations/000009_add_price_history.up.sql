@@ -0,0 +1,42 @@
+-- Every price change, whatever caused it
+CREATE TABLE IF NOT EXISTS price_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_id INTEGER NOT NULL,
+    old_price_amount INTEGER NOT NULL DEFAULT 0,
+    old_price_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    new_price_amount INTEGER NOT NULL DEFAULT 0,
+    new_price_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    source TEXT NOT NULL,
+    changed_by INTEGER,
+    changed_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_changes_item_id ON price_changes(item_id);
+CREATE INDEX IF NOT EXISTS idx_price_changes_changed_at ON price_changes(changed_at);
+
+-- Future prices applied by the price scheduler
+CREATE TABLE IF NOT EXISTS scheduled_prices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_id INTEGER NOT NULL,
+    price_amount INTEGER NOT NULL DEFAULT 0,
+    price_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    base_price_amount INTEGER NOT NULL DEFAULT 0,
+    base_price_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP,
+    status TEXT NOT NULL DEFAULT 'pending',
+    created_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_prices_item_id ON scheduled_prices(item_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_prices_starts_at ON scheduled_prices(starts_at);
+CREATE INDEX IF NOT EXISTS idx_scheduled_prices_status ON scheduled_prices(status);
+
+-- Start the history with the current price of every item
+INSERT INTO price_changes (item_id, old_price_amount, old_price_currency, new_price_amount, new_price_currency, source, changed_at)
+SELECT id, 0, price_currency, price_amount, price_currency, 'manual', COALESCE(created_at, CURRENT_TIMESTAMP)
+FROM items;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_reviews_status;
+DROP INDEX IF EXISTS idx_reviews_item_user;
+DROP TABLE IF EXISTS reviews;
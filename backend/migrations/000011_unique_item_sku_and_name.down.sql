@@ -0,0 +1,5 @@
+-- Merged duplicates are not restored
+DROP INDEX IF EXISTS uix_items_normalized_name;
+DROP INDEX IF EXISTS uix_items_sku;
+CREATE INDEX IF NOT EXISTS idx_items_sku ON items(sku);
+ALTER TABLE items DROP COLUMN normalized_name;
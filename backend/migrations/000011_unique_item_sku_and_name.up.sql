@@ -0,0 +1,86 @@
+-- Merge items whose names differ only in case or surrounding whitespace
+-- into the oldest one, then make SKU and name unique so duplicates can no
+-- longer be created.
+CREATE TEMPORARY TABLE item_survivors AS
+SELECT i.id AS duplicate_id, s.survivor_id
+FROM items i
+JOIN (
+    SELECT LOWER(TRIM(name)) AS normalized, MIN(id) AS survivor_id
+    FROM items
+    GROUP BY LOWER(TRIM(name))
+    HAVING COUNT(*) > 1
+) s ON LOWER(TRIM(i.name)) = s.normalized
+WHERE i.id <> s.survivor_id;
+
+-- Cart lines: add duplicate quantities to an existing survivor line, create
+-- the survivor line where the cart has none, then drop the duplicate lines
+UPDATE cart_items
+SET quantity = quantity + (
+    SELECT SUM(d.quantity)
+    FROM cart_items d
+    JOIN item_survivors m ON d.item_id = m.duplicate_id
+    WHERE d.cart_id = cart_items.cart_id AND m.survivor_id = cart_items.item_id
+)
+WHERE EXISTS (
+    SELECT 1
+    FROM cart_items d
+    JOIN item_survivors m ON d.item_id = m.duplicate_id
+    WHERE d.cart_id = cart_items.cart_id AND m.survivor_id = cart_items.item_id
+);
+
+INSERT INTO cart_items (cart_id, item_id, quantity, created_at, updated_at)
+SELECT d.cart_id, m.survivor_id, SUM(d.quantity), MIN(d.created_at), MAX(d.updated_at)
+FROM cart_items d
+JOIN item_survivors m ON d.item_id = m.duplicate_id
+WHERE NOT EXISTS (
+    SELECT 1 FROM cart_items s WHERE s.cart_id = d.cart_id AND s.item_id = m.survivor_id
+)
+GROUP BY d.cart_id, m.survivor_id;
+
+DELETE FROM cart_items WHERE item_id IN (SELECT duplicate_id FROM item_survivors);
+
+-- Everything else that points at an item follows it to the survivor
+UPDATE order_items SET item_id = (SELECT survivor_id FROM item_survivors WHERE duplicate_id = order_items.item_id)
+WHERE item_id IN (SELECT duplicate_id FROM item_survivors);
+UPDATE item_images SET item_id = (SELECT survivor_id FROM item_survivors WHERE duplicate_id = item_images.item_id)
+WHERE item_id IN (SELECT duplicate_id FROM item_survivors);
+UPDATE price_changes SET item_id = (SELECT survivor_id FROM item_survivors WHERE duplicate_id = price_changes.item_id)
+WHERE item_id IN (SELECT duplicate_id FROM item_survivors);
+UPDATE scheduled_prices SET item_id = (SELECT survivor_id FROM item_survivors WHERE duplicate_id = scheduled_prices.item_id)
+WHERE item_id IN (SELECT duplicate_id FROM item_survivors);
+
+-- A customer keeps one review per item; extra reviews of a duplicate are dropped
+UPDATE OR IGNORE reviews SET item_id = (SELECT survivor_id FROM item_survivors WHERE duplicate_id = reviews.item_id)
+WHERE item_id IN (SELECT duplicate_id FROM item_survivors);
+DELETE FROM reviews WHERE item_id IN (SELECT duplicate_id FROM item_survivors);
+
+-- Stock on hand of the duplicates moves to the survivor
+UPDATE items
+SET stock = stock + (
+    SELECT COALESCE(SUM(MAX(d.stock, 0)), 0)
+    FROM items d
+    JOIN item_survivors m ON d.id = m.duplicate_id
+    WHERE m.survivor_id = items.id
+)
+WHERE id IN (SELECT survivor_id FROM item_survivors);
+UPDATE items SET status = 'available' WHERE stock > 0 AND status = 'out_of_stock';
+
+DELETE FROM items WHERE id IN (SELECT duplicate_id FROM item_survivors);
+DROP TABLE item_survivors;
+
+-- Name as it is compared for uniqueness
+ALTER TABLE items ADD COLUMN normalized_name VARCHAR(255) NOT NULL DEFAULT '';
+UPDATE items SET normalized_name = LOWER(TRIM(name));
+
+-- Items without a SKU get one derived from their ID, and repeated SKUs are
+-- made distinct the same way
+UPDATE items SET sku = 'ITEM-' || id WHERE sku IS NULL OR TRIM(sku) = '';
+UPDATE items SET sku = sku || '-' || id
+WHERE id NOT IN (SELECT MIN(id) FROM items GROUP BY sku);
+
+DROP INDEX IF EXISTS idx_items_sku;
+CREATE UNIQUE INDEX IF NOT EXISTS uix_items_sku ON items(sku);
+CREATE UNIQUE INDEX IF NOT EXISTS uix_items_normalized_name ON items(normalized_name);
+
+-- Verify no duplicates are left
+SELECT normalized_name, COUNT(*) FROM items GROUP BY normalized_name HAVING COUNT(*) > 1;
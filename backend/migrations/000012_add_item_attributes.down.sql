@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_item_attribute_values_attribute_id;
+DROP INDEX IF EXISTS idx_item_attribute_values_item_attribute;
+DROP TABLE IF EXISTS item_attribute_values;
+DROP INDEX IF EXISTS idx_attribute_options_value;
+DROP TABLE IF EXISTS attribute_options;
+DROP INDEX IF EXISTS uix_attribute_definitions_code;
+DROP TABLE IF EXISTS attribute_definitions;
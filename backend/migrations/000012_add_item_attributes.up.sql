@@ -0,0 +1,41 @@
+-- Typed product specs shoppers can filter on
+CREATE TABLE IF NOT EXISTS attribute_definitions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code VARCHAR(64) NOT NULL,
+    name TEXT NOT NULL,
+    type VARCHAR(16) NOT NULL CHECK (type IN ('enum', 'number', 'boolean')),
+    unit VARCHAR(16),
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_attribute_definitions_code ON attribute_definitions(code);
+
+-- Allowed values of enum attributes
+CREATE TABLE IF NOT EXISTS attribute_options (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    attribute_id INTEGER NOT NULL,
+    value TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (attribute_id) REFERENCES attribute_definitions(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_attribute_options_value ON attribute_options(attribute_id, value);
+
+-- One value per item and attribute, in the column matching the type
+CREATE TABLE IF NOT EXISTS item_attribute_values (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_id INTEGER NOT NULL,
+    attribute_id INTEGER NOT NULL,
+    text_value TEXT,
+    number_value REAL,
+    bool_value BOOLEAN,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE,
+    FOREIGN KEY (attribute_id) REFERENCES attribute_definitions(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_item_attribute_values_item_attribute ON item_attribute_values(item_id, attribute_id);
+CREATE INDEX IF NOT EXISTS idx_item_attribute_values_attribute_id ON item_attribute_values(attribute_id);
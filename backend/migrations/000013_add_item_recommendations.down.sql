@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_item_recommendations_pair;
+DROP TABLE IF EXISTS item_recommendations;
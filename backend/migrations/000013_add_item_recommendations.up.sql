@@ -0,0 +1,14 @@
+-- Precomputed recommendations, replaced per strategy on every refresh
+CREATE TABLE IF NOT EXISTS item_recommendations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    strategy VARCHAR(64) NOT NULL,
+    item_id INTEGER NOT NULL,
+    recommended_item_id INTEGER NOT NULL,
+    score REAL NOT NULL,
+    rank INTEGER NOT NULL,
+    computed_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE,
+    FOREIGN KEY (recommended_item_id) REFERENCES items(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_item_recommendations_pair ON item_recommendations(strategy, item_id, recommended_item_id);
@@ -0,0 +1,2 @@
+-- The catalog version used for ETags is the newest updated_at
+CREATE INDEX IF NOT EXISTS idx_items_updated_at ON items(updated_at);
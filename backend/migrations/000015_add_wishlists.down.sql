@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_wishlist_items_item;
+DROP TABLE IF EXISTS wishlist_items;
+DROP INDEX IF EXISTS uix_wishlists_share_token;
+DROP INDEX IF EXISTS idx_wishlists_user_name;
+DROP TABLE IF EXISTS wishlists;
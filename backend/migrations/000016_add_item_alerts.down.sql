@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_item_events_processed_at;
+DROP INDEX IF EXISTS idx_item_events_item_id;
+DROP TABLE IF EXISTS item_events;
+DROP INDEX IF EXISTS idx_item_alerts_item_id;
+DROP INDEX IF EXISTS idx_item_alerts_user_id;
+DROP TABLE IF EXISTS item_alerts;
@@ -0,0 +1,41 @@
+-- Back-in-stock and price drop subscriptions
+CREATE TABLE IF NOT EXISTS item_alerts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    item_id INTEGER NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'active',
+    base_price_amount BIGINT NOT NULL DEFAULT 0,
+    base_price_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    target_price_amount BIGINT NOT NULL DEFAULT 0,
+    target_price_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    triggered_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_alerts_user_id ON item_alerts(user_id);
+CREATE INDEX IF NOT EXISTS idx_item_alerts_item_id ON item_alerts(item_id);
+
+-- Status and price changes waiting to be turned into notifications
+CREATE TABLE IF NOT EXISTS item_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_id INTEGER NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    old_status VARCHAR(32),
+    new_status VARCHAR(32),
+    old_price_amount BIGINT NOT NULL DEFAULT 0,
+    old_price_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    new_price_amount BIGINT NOT NULL DEFAULT 0,
+    new_price_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    processed_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_events_item_id ON item_events(item_id);
+CREATE INDEX IF NOT EXISTS idx_item_events_processed_at ON item_events(processed_at);
@@ -0,0 +1,11 @@
+ALTER TABLE order_items DROP COLUMN discount_currency;
+ALTER TABLE order_items DROP COLUMN discount_amount;
+ALTER TABLE order_items DROP COLUMN bundle_id;
+
+DROP INDEX IF EXISTS idx_cart_bundles_bundle;
+DROP TABLE IF EXISTS cart_bundles;
+DROP INDEX IF EXISTS idx_bundle_components_item;
+DROP TABLE IF EXISTS bundle_components;
+DROP INDEX IF EXISTS uix_bundles_name;
+DROP INDEX IF EXISTS uix_bundles_sku;
+DROP TABLE IF EXISTS bundles;
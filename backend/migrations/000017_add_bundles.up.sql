@@ -0,0 +1,43 @@
+-- Kits of items sold together at their own price
+CREATE TABLE IF NOT EXISTS bundles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sku VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    price_amount BIGINT NOT NULL DEFAULT 0,
+    price_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_bundles_sku ON bundles(sku);
+CREATE UNIQUE INDEX IF NOT EXISTS uix_bundles_name ON bundles(name);
+
+CREATE TABLE IF NOT EXISTS bundle_components (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    bundle_id INTEGER NOT NULL,
+    item_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 1,
+    FOREIGN KEY (bundle_id) REFERENCES bundles(id) ON DELETE CASCADE,
+    FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bundle_components_item ON bundle_components(bundle_id, item_id);
+
+-- A bundle in a cart is one line
+CREATE TABLE IF NOT EXISTS cart_bundles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    cart_id INTEGER NOT NULL,
+    bundle_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (cart_id) REFERENCES carts(id) ON DELETE CASCADE,
+    FOREIGN KEY (bundle_id) REFERENCES bundles(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_bundles_bundle ON cart_bundles(cart_id, bundle_id);
+
+-- Ordered bundles are recorded as their components
+ALTER TABLE order_items ADD COLUMN bundle_id INTEGER NULL;
+ALTER TABLE order_items ADD COLUMN discount_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN discount_currency VARCHAR(3) NOT NULL DEFAULT 'USD';
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_download_grants_order_id;
+DROP TABLE IF EXISTS download_grants;
+DROP INDEX IF EXISTS idx_license_keys_order_id;
+DROP INDEX IF EXISTS idx_license_keys_key;
+DROP TABLE IF EXISTS license_keys;
+DROP INDEX IF EXISTS idx_digital_assets_item_id;
+DROP TABLE IF EXISTS digital_assets;
+
+ALTER TABLE items DROP COLUMN digital;
@@ -0,0 +1,44 @@
+-- Digital items are delivered as downloads and license keys
+ALTER TABLE items ADD COLUMN digital BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS digital_assets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_id INTEGER NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_digital_assets_item_id ON digital_assets(item_id);
+
+CREATE TABLE IF NOT EXISTS license_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_id INTEGER NOT NULL,
+    license_key VARCHAR(255) NOT NULL,
+    order_id INTEGER NULL,
+    assigned_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE,
+    FOREIGN KEY (order_id) REFERENCES orders(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_license_keys_key ON license_keys(item_id, license_key);
+CREATE INDEX IF NOT EXISTS idx_license_keys_order_id ON license_keys(order_id);
+
+CREATE TABLE IF NOT EXISTS download_grants (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL,
+    item_id INTEGER NOT NULL,
+    asset_id INTEGER NOT NULL,
+    downloads INTEGER NOT NULL DEFAULT 0,
+    max_downloads INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (asset_id) REFERENCES digital_assets(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_download_grants_order_id ON download_grants(order_id);
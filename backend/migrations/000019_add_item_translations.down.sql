@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_item_translations_item_locale;
+DROP TABLE IF EXISTS item_translations;
+
+ALTER TABLE items DROP COLUMN description;
@@ -0,0 +1,15 @@
+-- Items get a description; other locales are stored as translations
+ALTER TABLE items ADD COLUMN description TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS item_translations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_id INTEGER NOT NULL,
+    locale VARCHAR(35) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_item_translations_item_locale ON item_translations(item_id, locale);
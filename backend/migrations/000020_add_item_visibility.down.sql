@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_items_unpublish_at;
+DROP INDEX IF EXISTS idx_items_publish_at;
+
+ALTER TABLE items DROP COLUMN unpublish_at;
+ALTER TABLE items DROP COLUMN publish_at;
+ALTER TABLE items DROP COLUMN visibility;
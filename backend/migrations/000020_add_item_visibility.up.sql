@@ -0,0 +1,7 @@
+-- Items can be drafts or be published within a window
+ALTER TABLE items ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'published';
+ALTER TABLE items ADD COLUMN publish_at TIMESTAMP NULL;
+ALTER TABLE items ADD COLUMN unpublish_at TIMESTAMP NULL;
+
+CREATE INDEX IF NOT EXISTS idx_items_publish_at ON items(publish_at);
+CREATE INDEX IF NOT EXISTS idx_items_unpublish_at ON items(unpublish_at);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_cart_items_cart_item;
+DROP INDEX IF EXISTS idx_carts_active_session;
+DROP INDEX IF EXISTS idx_carts_active_user;
+
+UPDATE carts SET status = 'active' WHERE status = 'superseded';
@@ -0,0 +1,14 @@
+-- Each user or session has one active cart; duplicates are retired,
+-- keeping the oldest, which is the one that was in use
+UPDATE carts SET status = 'superseded' WHERE status = 'active' AND user_id IS NOT NULL
+    AND id NOT IN (SELECT MIN(id) FROM carts WHERE status = 'active' AND user_id IS NOT NULL GROUP BY user_id);
+UPDATE carts SET status = 'superseded' WHERE status = 'active' AND user_id IS NULL
+    AND id NOT IN (SELECT MIN(id) FROM carts WHERE status = 'active' AND user_id IS NULL GROUP BY session_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_carts_active_user ON carts(user_id)
+    WHERE status = 'active' AND user_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_carts_active_session ON carts(session_id)
+    WHERE status = 'active' AND user_id IS NULL;
+
+-- Cart lines are upserted on (cart_id, item_id)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_items_cart_item ON cart_items(cart_id, item_id);
@@ -0,0 +1,2 @@
+ALTER TABLE items DROP COLUMN min_order_quantity;
+ALTER TABLE items DROP COLUMN max_per_order;
@@ -0,0 +1,3 @@
+-- Per-order purchase limits of an item; 0 means no limit
+ALTER TABLE items ADD COLUMN max_per_order INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE items ADD COLUMN min_order_quantity INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_carts_status_updated_at;
@@ -0,0 +1,2 @@
+-- Lets the cart expiry job find stale active carts
+CREATE INDEX IF NOT EXISTS idx_carts_status_updated_at ON carts(status, updated_at);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN cart_reminders_opt_out;
+ALTER TABLE carts DROP COLUMN reminded_at;
+ALTER TABLE carts DROP COLUMN reminder_count;
@@ -0,0 +1,4 @@
+-- Abandoned cart reminders: how many were sent per cart, and who opted out
+ALTER TABLE carts ADD COLUMN reminder_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE carts ADD COLUMN reminded_at TIMESTAMP NULL;
+ALTER TABLE users ADD COLUMN cart_reminders_opt_out BOOLEAN NOT NULL DEFAULT FALSE;
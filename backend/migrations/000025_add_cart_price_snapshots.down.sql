@@ -0,0 +1,4 @@
+ALTER TABLE cart_bundles DROP COLUMN unit_price_currency;
+ALTER TABLE cart_bundles DROP COLUMN unit_price_amount;
+ALTER TABLE cart_items DROP COLUMN unit_price_currency;
+ALTER TABLE cart_items DROP COLUMN unit_price_amount;
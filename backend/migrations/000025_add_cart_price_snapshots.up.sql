@@ -0,0 +1,16 @@
+-- Prices of cart lines as they were when added, to detect later changes
+ALTER TABLE cart_items ADD COLUMN unit_price_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE cart_items ADD COLUMN unit_price_currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE cart_bundles ADD COLUMN unit_price_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE cart_bundles ADD COLUMN unit_price_currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+
+-- Lines already in carts take the current price, so they do not show up
+-- as changed
+UPDATE cart_items SET
+    unit_price_amount = (SELECT price_amount FROM items WHERE items.id = cart_items.item_id),
+    unit_price_currency = (SELECT price_currency FROM items WHERE items.id = cart_items.item_id)
+WHERE EXISTS (SELECT 1 FROM items WHERE items.id = cart_items.item_id);
+UPDATE cart_bundles SET
+    unit_price_amount = (SELECT price_amount FROM bundles WHERE bundles.id = cart_bundles.bundle_id),
+    unit_price_currency = (SELECT price_currency FROM bundles WHERE bundles.id = cart_bundles.bundle_id)
+WHERE EXISTS (SELECT 1 FROM bundles WHERE bundles.id = cart_bundles.bundle_id);
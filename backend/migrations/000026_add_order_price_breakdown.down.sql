@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_order_adjustments_order_id;
+DROP TABLE IF EXISTS order_adjustments;
+
+ALTER TABLE orders DROP COLUMN tax_currency;
+ALTER TABLE orders DROP COLUMN tax_amount;
+ALTER TABLE orders DROP COLUMN shipping_currency;
+ALTER TABLE orders DROP COLUMN shipping_amount;
+ALTER TABLE orders DROP COLUMN discount_currency;
+ALTER TABLE orders DROP COLUMN discount_amount;
+ALTER TABLE orders DROP COLUMN subtotal_currency;
+ALTER TABLE orders DROP COLUMN subtotal_amount;
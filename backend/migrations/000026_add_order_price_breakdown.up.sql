@@ -0,0 +1,28 @@
+-- Orders keep the price breakdown their total came from
+ALTER TABLE orders ADD COLUMN subtotal_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN subtotal_currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE orders ADD COLUMN discount_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN discount_currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE orders ADD COLUMN shipping_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN shipping_currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE orders ADD COLUMN tax_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN tax_currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+
+-- Earlier orders had neither discounts, shipping nor tax
+UPDATE orders SET
+    subtotal_amount = total_amount, subtotal_currency = total_currency,
+    discount_currency = total_currency, shipping_currency = total_currency, tax_currency = total_currency;
+
+CREATE TABLE IF NOT EXISTS order_adjustments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL,
+    calculator VARCHAR(64) NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    label VARCHAR(255) NOT NULL,
+    amount_amount INTEGER NOT NULL DEFAULT 0,
+    amount_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_adjustments_order_id ON order_adjustments(order_id);
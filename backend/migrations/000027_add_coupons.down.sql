@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_carts_coupon_id;
+ALTER TABLE carts DROP COLUMN coupon_id;
+
+DROP INDEX IF EXISTS uix_coupon_redemptions_order_id;
+DROP INDEX IF EXISTS idx_coupon_redemptions_user;
+DROP TABLE IF EXISTS coupon_redemptions;
+DROP INDEX IF EXISTS idx_coupon_categories_category;
+DROP TABLE IF EXISTS coupon_categories;
+DROP INDEX IF EXISTS idx_coupon_items_item;
+DROP TABLE IF EXISTS coupon_items;
+DROP INDEX IF EXISTS uix_coupons_code;
+DROP TABLE IF EXISTS coupons;
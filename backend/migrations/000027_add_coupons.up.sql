@@ -0,0 +1,60 @@
+-- Coupons, what they apply to, and who redeemed them with which order
+CREATE TABLE IF NOT EXISTS coupons (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code VARCHAR(64) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    type VARCHAR(16) NOT NULL,
+    percent_off INTEGER NOT NULL DEFAULT 0,
+    amount_off_amount INTEGER NOT NULL DEFAULT 0,
+    amount_off_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    min_spend_amount INTEGER NOT NULL DEFAULT 0,
+    min_spend_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    max_uses INTEGER NOT NULL DEFAULT 0,
+    max_uses_per_user INTEGER NOT NULL DEFAULT 0,
+    uses INTEGER NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP NULL,
+    ends_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_coupons_code ON coupons(code);
+
+CREATE TABLE IF NOT EXISTS coupon_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    coupon_id INTEGER NOT NULL,
+    item_id INTEGER NOT NULL,
+    FOREIGN KEY (coupon_id) REFERENCES coupons(id) ON DELETE CASCADE,
+    FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_coupon_items_item ON coupon_items(coupon_id, item_id);
+
+CREATE TABLE IF NOT EXISTS coupon_categories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    coupon_id INTEGER NOT NULL,
+    category VARCHAR(255) NOT NULL,
+    FOREIGN KEY (coupon_id) REFERENCES coupons(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_coupon_categories_category ON coupon_categories(coupon_id, category);
+
+CREATE TABLE IF NOT EXISTS coupon_redemptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    coupon_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    order_id INTEGER NOT NULL,
+    discount_amount INTEGER NOT NULL DEFAULT 0,
+    discount_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (coupon_id) REFERENCES coupons(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_user ON coupon_redemptions(coupon_id, user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uix_coupon_redemptions_order_id ON coupon_redemptions(order_id);
+
+-- The coupon applied to each cart
+ALTER TABLE carts ADD COLUMN coupon_id INTEGER NULL REFERENCES coupons(id);
+CREATE INDEX IF NOT EXISTS idx_carts_coupon_id ON carts(coupon_id);
@@ -0,0 +1,4 @@
+-- Saved carts cannot be told apart without their names
+UPDATE carts SET status = 'superseded' WHERE status = 'saved';
+
+ALTER TABLE carts DROP COLUMN name;
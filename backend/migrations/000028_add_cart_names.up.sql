@@ -0,0 +1,2 @@
+-- Users keep several named carts; all but the current one are 'saved'
+ALTER TABLE carts ADD COLUMN name VARCHAR(100) NOT NULL DEFAULT '';
@@ -127,7 +127,7 @@ function Navbar() {
 
   const cartItemCount = cartItems.reduce((total, item) => total + (item.quantity || 1), 0);
   const cartTotal = cartItems.reduce(
-    (total, item) => total + ((item.price || 0) * (item.quantity || 1)), 
+    (total, item) => total + ((Number(item.price?.formatted ?? item.price) || 0) * (item.quantity || 1)), 
     0
   ).toFixed(2);
 
@@ -324,7 +324,7 @@ function Navbar() {
                 
                 // Safely access item properties with defaults
                 const itemName = item.name || item.productName || 'Unnamed Product';
-                const itemPrice = Number(item.price) || 0;
+                const itemPrice = Number(item.price?.formatted ?? item.price) || 0;
                 const itemQuantity = Number(item.quantity) || 1;
                 const itemImage = item.image || '';
                 
@@ -18,6 +18,9 @@ import { useAuth } from '../contexts/AuthContext';
 import axios from 'axios';
 import { toast } from 'react-toastify';
 
+// Prices come from the API as { amount, currency, formatted }
+const toPrice = (price) => Number(price?.formatted ?? price) || 0;
+
 function ItemsList() {
   const [items, setItems] = useState([]);
   const [loading, setLoading] = useState(true);
@@ -34,7 +37,7 @@ function ItemsList() {
       try {
         const response = await axios.get('/api/items');
         console.log('Fetched items:', response.data);
-        setItems(response.data);
+        setItems(response.data.map(item => ({ ...item, price: toPrice(item.price) })));
       } catch (error) {
         console.error('Failed to fetch items:', error);
         toast.error('Failed to load products');
@@ -87,7 +90,7 @@ function ItemsList() {
             id: item.item_id || item.id,
             quantity: item.quantity,
             name: item.name,
-            price: item.price
+            price: toPrice(item.price)
           }))
         : [];
       